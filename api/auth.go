@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes granted to API keys. Admin implies the others and any address.
+const (
+	ScopeOnchainRead = "onchain:read"
+	ScopeLNRead      = "ln:read"
+	ScopeAdmin       = "admin"
+)
+
+var knownScopes = map[string]bool{ScopeOnchainRead: true, ScopeLNRead: true, ScopeAdmin: true}
+
+// APIKey is one stored credential. Only the SHA-256 of the secret is kept;
+// CertSHA256 lets an mTLS client certificate authenticate as the key.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"secret_sha256,omitempty"`
+	CertSHA256 string    `json:"cert_sha256,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	Addresses  []string  `json:"addresses,omitempty"` // allowlist; empty = any
+	Created    time.Time `json:"created"`
+}
+
+func (k APIKey) has(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether k may query target, an address or descriptor.
+func (k APIKey) allows(target string) bool {
+	if len(k.Addresses) == 0 || k.has(ScopeAdmin) {
+		return true
+	}
+	for _, a := range k.Addresses {
+		if a == target {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore holds API keys in a JSON file. The file is reloaded when it
+// changes, so keys added or revoked from the CLI apply to a running server.
+type KeyStore struct {
+	mu   sync.Mutex
+	path string
+	mod  time.Time
+	keys []APIKey
+}
+
+// OpenKeyStore loads path; a missing file is an empty store.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	ks := &KeyStore{path: path}
+	if err := ks.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *KeyStore) load() error {
+	fi, err := os.Stat(ks.path)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(ks.path)
+	if err != nil {
+		return err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("key store %s: %w", ks.path, err)
+	}
+	ks.keys, ks.mod = keys, fi.ModTime()
+	return nil
+}
+
+// refresh reloads the file if it changed. Called with ks.mu held.
+func (ks *KeyStore) refresh() {
+	fi, err := os.Stat(ks.path)
+	if err != nil {
+		if os.IsNotExist(err) && len(ks.keys) > 0 {
+			log.Printf("key store %s removed; no keys are valid", ks.path)
+			ks.keys, ks.mod = nil, time.Time{}
+		}
+		return
+	}
+	if fi.ModTime().Equal(ks.mod) {
+		return
+	}
+	if err := ks.load(); err != nil {
+		log.Printf("key store reload error (keeping previous keys): %v", err)
+	}
+}
+
+// save writes the store atomically with owner-only permissions. Called
+// with ks.mu held.
+func (ks *KeyStore) save() error {
+	b, err := json.MarshalIndent(ks.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ks.path), ".keys-*")
+	if err != nil {
+		return err
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil {
+		os.Remove(tmp.Name())
+		return errors.Join(werr, cerr)
+	}
+	if err := os.Rename(tmp.Name(), ks.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if fi, err := os.Stat(ks.path); err == nil {
+		ks.mod = fi.ModTime()
+	}
+	return nil
+}
+
+// Keys returns the stored keys without their secret hashes.
+func (ks *KeyStore) Keys() []APIKey {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.refresh()
+	out := make([]APIKey, len(ks.keys))
+	for i, k := range ks.keys {
+		k.SecretHash = ""
+		out[i] = k
+	}
+	return out
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CertFingerprint returns the hex SHA-256 of the first certificate in a PEM
+// file's contents.
+func CertFingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("client cert: no PEM certificate found")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Add creates a key and returns it with its secret, which is not stored and
+// cannot be shown again. With noSecret the key only works with the client
+// certificate certSHA256.
+func (ks *KeyStore) Add(name string, scopes, addresses []string, certSHA256 string, noSecret bool) (APIKey, string, error) {
+	if len(scopes) == 0 {
+		return APIKey{}, "", errors.New("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !knownScopes[s] {
+			return APIKey{}, "", fmt.Errorf("unknown scope %q (want %s, %s or %s)", s, ScopeOnchainRead, ScopeLNRead, ScopeAdmin)
+		}
+	}
+	if noSecret && certSHA256 == "" {
+		return APIKey{}, "", errors.New("a key without a secret needs a client certificate")
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	k := APIKey{ID: id, Name: name, CertSHA256: certSHA256, Scopes: scopes, Addresses: addresses, Created: time.Now().UTC()}
+	var secret string
+	if !noSecret {
+		r, err := randomHex(32)
+		if err != nil {
+			return APIKey{}, "", err
+		}
+		secret = "sck_" + r
+		k.SecretHash = hashSecret(secret)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.refresh()
+	ks.keys = append(ks.keys, k)
+	if err := ks.save(); err != nil {
+		ks.keys = ks.keys[:len(ks.keys)-1]
+		return APIKey{}, "", err
+	}
+	k.SecretHash = ""
+	return k, secret, nil
+}
+
+// Revoke deletes the key with id.
+func (ks *KeyStore) Revoke(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.refresh()
+	for i, k := range ks.keys {
+		if k.ID == id {
+			prev := ks.keys
+			ks.keys = append(append([]APIKey{}, prev[:i]...), prev[i+1:]...)
+			if err := ks.save(); err != nil {
+				ks.keys = prev
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no key with id %q", id)
+}
+
+// lookup finds the key whose secret or client certificate matches. Every
+// stored hash is compared so timing does not depend on which key matched.
+func (ks *KeyStore) lookup(secret string, certDER []byte) (APIKey, bool) {
+	var secretSum, certSum []byte
+	if secret != "" {
+		secretSum, _ = hex.DecodeString(hashSecret(secret))
+	}
+	if certDER != nil {
+		s := sha256.Sum256(certDER)
+		certSum = s[:]
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.refresh()
+	var found APIKey
+	ok := false
+	for _, k := range ks.keys {
+		if secretSum != nil && k.SecretHash != "" {
+			if h, err := hex.DecodeString(k.SecretHash); err == nil && subtle.ConstantTimeCompare(h, secretSum) == 1 {
+				found, ok = k, true
+			}
+		}
+		if certSum != nil && k.CertSHA256 != "" {
+			if h, err := hex.DecodeString(k.CertSHA256); err == nil && subtle.ConstantTimeCompare(h, certSum) == 1 && !ok {
+				found, ok = k, true
+			}
+		}
+	}
+	return found, ok
+}
+
+type authKeyCtx struct{}
+
+// authenticate resolves the caller's key from "Authorization: Bearer",
+// X-API-Key or an mTLS client certificate.
+func (s *Server) authenticate(r *http.Request) (APIKey, bool) {
+	secret := r.Header.Get("X-API-Key")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		secret = strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	var certDER []byte
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		certDER = r.TLS.PeerCertificates[0].Raw
+	}
+	if secret == "" && certDER == nil {
+		return APIKey{}, false
+	}
+	return s.cfg.Auth.lookup(secret, certDER)
+}
+
+// require lets only keys with scope reach h, passing the key on in the
+// request context. Without a key store the API is open.
+func (s *Server) require(scope string, h http.HandlerFunc) http.HandlerFunc {
+	if s.cfg.Auth == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sovereign-checker"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !k.has(scope) {
+			http.Error(w, "forbidden: key lacks scope "+scope, http.StatusForbidden)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), authKeyCtx{}, k)))
+	}
+}
+
+// allowedTarget reports whether the caller may query target; true when
+// auth is off.
+func allowedTarget(ctx context.Context, target string) bool {
+	k, ok := ctx.Value(authKeyCtx{}).(APIKey)
+	return !ok || k.allows(target)
+}
+
+// canReadLN reports whether the caller may see Lightning node data.
+func canReadLN(ctx context.Context) bool {
+	k, ok := ctx.Value(authKeyCtx{}).(APIKey)
+	return !ok || k.has(ScopeLNRead)
+}
+
+// handleAdminKeys lists the configured keys without their secret hashes.
+func (s *Server) handleAdminKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.cfg.Auth.Keys())
+}
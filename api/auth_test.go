@@ -0,0 +1,243 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// authServer returns a server whose key store lives in a temp file, and a
+// handler with one on-chain and one LN route that echo what the key allows.
+func authServer(t *testing.T) (*Server, http.Handler, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{cfg: Config{Auth: ks}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check", s.require(ScopeOnchainRead, func(w http.ResponseWriter, r *http.Request) {
+		if !allowedTarget(r.Context(), r.URL.Query().Get("address")) {
+			http.Error(w, "forbidden: address not allowed for this key", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, "ln=%t", canReadLN(r.Context()))
+	}))
+	mux.HandleFunc("/lnready", s.require(ScopeLNRead, func(w http.ResponseWriter, r *http.Request) {}))
+	return s, mux, path
+}
+
+type authReq struct {
+	path   string
+	bearer string
+	apiKey string
+	cert   []byte // DER of the presented client certificate
+}
+
+func (a authReq) do(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, a.path, nil)
+	if a.bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+a.bearer)
+	}
+	if a.apiKey != "" {
+		r.Header.Set("X-API-Key", a.apiKey)
+	}
+	if a.cert != nil {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: a.cert}}}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func certPEM(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestRequireKeys(t *testing.T) {
+	s, h, _ := authServer(t)
+	ks := s.cfg.Auth
+
+	_, onchain, err := ks.Add("wallet", []string{ScopeOnchainRead}, []string{"bc1qallowed"}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, both, err := ks.Add("ops", []string{ScopeOnchainRead, ScopeLNRead}, nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, err := ks.Add("root", []string{ScopeAdmin}, []string{"bc1qallowed"}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDER, otherDER := []byte("client certificate"), []byte("some other certificate")
+	fp, err := CertFingerprint(certPEM(clientDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, secret, err := ks.Add("mtls", []string{ScopeOnchainRead}, nil, fp, true); err != nil || secret != "" {
+		t.Fatalf("cert-only key: secret %q, err %v", secret, err)
+	}
+
+	tests := []struct {
+		name string
+		req  authReq
+		want int
+		body string
+	}{
+		{"no key", authReq{path: "/check?address=bc1qallowed"}, http.StatusUnauthorized, ""},
+		{"wrong key", authReq{path: "/check?address=bc1qallowed", bearer: "sck_wrong"}, http.StatusUnauthorized, ""},
+		{"bearer", authReq{path: "/check?address=bc1qallowed", bearer: onchain}, http.StatusOK, "ln=false"},
+		{"x-api-key", authReq{path: "/check?address=bc1qallowed", apiKey: onchain}, http.StatusOK, "ln=false"},
+		{"address not allowed", authReq{path: "/check?address=bc1qother", bearer: onchain}, http.StatusForbidden, ""},
+		{"wrong scope", authReq{path: "/lnready", bearer: onchain}, http.StatusForbidden, ""},
+		{"ln scope", authReq{path: "/lnready", bearer: both}, http.StatusOK, ""},
+		{"ln scope reads ln", authReq{path: "/check?address=bc1qany", bearer: both}, http.StatusOK, "ln=true"},
+		{"admin ignores allowlist and scopes", authReq{path: "/check?address=bc1qother", bearer: admin}, http.StatusOK, "ln=true"},
+		{"bound cert", authReq{path: "/check?address=bc1qany", cert: clientDER}, http.StatusOK, "ln=false"},
+		{"bound key without its cert", authReq{path: "/check?address=bc1qany", cert: otherDER}, http.StatusUnauthorized, ""},
+		{"secret with unknown cert", authReq{path: "/check?address=bc1qallowed", bearer: onchain, cert: otherDER}, http.StatusOK, "ln=false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.req.do(h)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body, tt.body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+
+	for _, k := range ks.Keys() {
+		if k.SecretHash != "" {
+			t.Errorf("Keys() leaked the secret hash of %s", k.ID)
+		}
+	}
+}
+
+func TestKeyStoreRevokeAndReload(t *testing.T) {
+	s, h, path := authServer(t)
+	k, secret, err := s.cfg.Auth.Add("wallet", []string{ScopeOnchainRead}, nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := authReq{path: "/check?address=bc1q", bearer: secret}
+	if w := check.do(h); w.Code != http.StatusOK {
+		t.Fatalf("fresh key: status %d", w.Code)
+	}
+
+	if err := s.cfg.Auth.Revoke(k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := check.do(h); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d", w.Code)
+	}
+	if err := s.cfg.Auth.Revoke(k.ID); err == nil {
+		t.Error("revoking an unknown key succeeded")
+	}
+
+	// A key added from the CLI, by another store on the same file, applies
+	// to the running server.
+	cli, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, err = cli.Add("cli", []string{ScopeOnchainRead}, nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	check.bearer = secret
+	if w := check.do(h); w.Code != http.StatusOK {
+		t.Fatalf("key added by the cli: status %d", w.Code)
+	}
+
+	// A corrupt file keeps the previous keys rather than opening up or locking out.
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future = future.Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if w := check.do(h); w.Code != http.StatusOK {
+		t.Errorf("corrupt key file dropped the loaded keys: status %d", w.Code)
+	}
+
+	// Deleting the file revokes everything; the API stays closed.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if w := check.do(h); w.Code != http.StatusUnauthorized {
+		t.Errorf("key file deleted: status %d", w.Code)
+	}
+	if w := (authReq{path: "/check?address=bc1q"}).do(h); w.Code != http.StatusUnauthorized {
+		t.Errorf("key file deleted, no key: status %d", w.Code)
+	}
+}
+
+func TestKeyStoreAddRejects(t *testing.T) {
+	s, _, _ := authServer(t)
+	ks := s.cfg.Auth
+	if _, _, err := ks.Add("none", nil, nil, "", false); err == nil {
+		t.Error("key without scopes accepted")
+	}
+	if _, _, err := ks.Add("typo", []string{"onchain:write"}, nil, "", false); err == nil {
+		t.Error("unknown scope accepted")
+	}
+	if _, _, err := ks.Add("nocert", []string{ScopeAdmin}, nil, "", true); err == nil {
+		t.Error("key with neither secret nor certificate accepted")
+	}
+	if _, err := CertFingerprint([]byte("not pem")); err == nil {
+		t.Error("fingerprint of non-PEM input")
+	}
+	if len(ks.Keys()) != 0 {
+		t.Errorf("rejected keys were stored: %v", ks.Keys())
+	}
+}
+
+func TestHandlerRouteScopes(t *testing.T) {
+	ks, err := OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, onchain, _ := ks.Add("wallet", []string{ScopeOnchainRead}, nil, "", false)
+	_, lnOnly, _ := ks.Add("node", []string{ScopeLNRead}, nil, "", false)
+	_, admin, _ := ks.Add("root", []string{ScopeAdmin}, nil, "", false)
+	h := NewServer(Config{Auth: ks}).Handler()
+
+	tests := []struct {
+		path, key string
+		want      int
+	}{
+		{"/check?address=bc1q", "", http.StatusUnauthorized},
+		{"/check?address=bc1q", lnOnly, http.StatusForbidden},
+		{"/report?address=bc1q", lnOnly, http.StatusForbidden},
+		{"/report/batch", lnOnly, http.StatusForbidden},
+		{"/lnready", onchain, http.StatusForbidden},
+		{"/lnreceive", onchain, http.StatusForbidden},
+		{"/admin/keys", onchain, http.StatusForbidden},
+		{"/admin/keys", admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := (authReq{path: tt.path, bearer: tt.key}).do(h); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.want)
+		}
+	}
+}
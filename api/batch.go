@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/score"
+)
+
+const (
+	// maxBatchItems and maxBatchBody bound one /report/batch request.
+	maxBatchItems = 1000
+	maxBatchBody  = 1 << 20
+)
+
+// BatchItem is one wallet in a batch: an address or an output descriptor
+// (bitcoind only), with an optional network overriding the default. A bare
+// JSON string is read as an address or descriptor.
+type BatchItem struct {
+	Address    string `json:"address,omitempty"`
+	Descriptor string `json:"descriptor,omitempty"`
+	Network    string `json:"network,omitempty"`
+}
+
+func (it *BatchItem) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*it = BatchItem{Address: s}
+		if btc.IsDescriptor(s) {
+			*it = BatchItem{Descriptor: s}
+		}
+		return nil
+	}
+	type plain BatchItem
+	return json.Unmarshal(b, (*plain)(it))
+}
+
+func (it BatchItem) target() string {
+	if it.Descriptor != "" {
+		return it.Descriptor
+	}
+	return it.Address
+}
+
+// BatchResult is one NDJSON line of a batch: the item's report or error.
+type BatchResult struct {
+	Index  int       `json:"index"`
+	Item   BatchItem `json:"item"`
+	Report *Report   `json:"report,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// BatchSummary is the last line of a batch: item counts and the portfolio
+// built from the successful reports.
+type BatchSummary struct {
+	Items     int             `json:"items"`
+	OK        int             `json:"ok"`
+	Failed    int             `json:"failed"`
+	Portfolio score.Portfolio `json:"portfolio"`
+	// Items whose plan recommends consolidating now
+	ConsolidateNow []string `json:"consolidate_now"`
+	ElapsedMs      int64    `json:"elapsed_ms"`
+}
+
+func parseNetwork(s string, def btc.Network) (btc.Network, error) {
+	switch s {
+	case "":
+		return def, nil
+	case "mainnet":
+		return btc.Mainnet, nil
+	case "testnet":
+		return btc.Testnet, nil
+	}
+	return "", fmt.Errorf("unknown network %q", s)
+}
+
+// RunBatch builds a report for each item with at most concurrency in
+// flight, passing results to emit as they finish (emit calls are
+// serialized). Lightning checks are left out: they describe the node, not
+// the wallets, and are available from /report.
+func (s *Server) RunBatch(ctx context.Context, items []BatchItem, concurrency int, emit func(BatchResult)) BatchSummary {
+	start := time.Now()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sum := BatchSummary{Items: len(items), ConsolidateNow: []string{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	// Indexed by item so the portfolio does not depend on finishing order
+	results := make([]*score.Result, len(items))
+	sem := make(chan struct{}, concurrency)
+	for i, it := range items {
+		wg.Add(1)
+		go func(i int, it BatchItem) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			res := BatchResult{Index: i, Item: it}
+			if err := s.batchItem(ctx, it, &res); err != nil {
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if rep := res.Report; rep != nil {
+				sum.OK++
+				results[i] = &rep.OnChain
+				if rep.Plan.Recommended {
+					sum.ConsolidateNow = append(sum.ConsolidateNow, it.target())
+				}
+			} else {
+				sum.Failed++
+			}
+			emit(res)
+		}(i, it)
+	}
+	wg.Wait()
+
+	var wallets []score.Result
+	for _, r := range results {
+		if r != nil {
+			wallets = append(wallets, *r)
+		}
+	}
+	sum.Portfolio = score.ComputePortfolio(wallets)
+	sum.ElapsedMs = time.Since(start).Milliseconds()
+	return sum
+}
+
+func (s *Server) batchItem(ctx context.Context, it BatchItem, res *BatchResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	target := it.target()
+	if target == "" {
+		return fmt.Errorf("missing address or descriptor")
+	}
+	if !allowedTarget(ctx, target) {
+		return fmt.Errorf("address not allowed for this key")
+	}
+	network, err := parseNetwork(it.Network, s.cfg.Network)
+	if err != nil {
+		return err
+	}
+	rep, err := s.forAddress(target).buildReport(ctx, target, network, 0, s.cfg, false)
+	if err != nil {
+		return err
+	}
+	res.Report = &rep
+	return nil
+}
+
+// handleReportBatch takes a JSON array of BatchItems and streams one
+// BatchResult per line as each finishes, then {"summary": ...}.
+func (s *Server) handleReportBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a JSON list of addresses", http.StatusMethodNotAllowed)
+		return
+	}
+	var items []BatchItem
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&items); err != nil {
+		http.Error(w, "bad batch body", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 || len(items) > maxBatchItems {
+		http.Error(w, fmt.Sprintf("batch needs 1 to %d items", maxBatchItems), http.StatusBadRequest)
+		return
+	}
+	concurrency := s.cfg.BatchConcurrency
+	if q := r.URL.Query().Get("concurrency"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 1 {
+			http.Error(w, "bad concurrency", http.StatusBadRequest)
+			return
+		}
+		if n < concurrency {
+			concurrency = n
+		}
+	}
+	s2 := *s
+	s2.cfg.Fresh = r.URL.Query().Get("fresh") == "1"
+
+	// A batch can outlast the server's WriteTimeout; each item is bounded
+	// by the backend budgets and the client can disconnect to cancel.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	write := func(v interface{}) {
+		if err := enc.Encode(v); err != nil {
+			log.Printf("batch write error (omitting): %v", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	sum := s2.RunBatch(r.Context(), items, concurrency, func(res BatchResult) { write(res) })
+	write(map[string]BatchSummary{"summary": sum})
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sovereign-checker/btc"
+)
+
+// cacheMaxAge bounds how long entries are kept, in memory and on disk,
+// whatever their TTL.
+const cacheMaxAge = 24 * time.Hour
+
+type cacheEntry struct {
+	Stored  time.Time       `json:"stored"`
+	TipHash string          `json:"tip_hash,omitempty"` // best block when stored; "" = TTL only
+	Value   json.RawMessage `json:"value"`
+}
+
+// Cache holds UTXO sets, fee estimates and Lightning reports between
+// requests. Values are stored as JSON so callers never share mutable state.
+// With a path, entries are persisted there between CLI runs.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]cacheEntry
+}
+
+// NewCache returns a memory cache, loading and persisting path when set.
+func NewCache(path string) *Cache {
+	c := &Cache{path: path, entries: map[string]cacheEntry{}}
+	if path == "" {
+		return c
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("cache load error (starting empty): %v", err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		log.Printf("cache load error (starting empty): %v", err)
+		c.entries = map[string]cacheEntry{}
+	}
+	return c
+}
+
+// CacheStatus says whether a value came from the cache and how old it is.
+type CacheStatus struct {
+	Hit    bool   `json:"hit"`
+	AgeSec int64  `json:"age_s"`
+	Reason string `json:"reason,omitempty"` // why it was refetched
+}
+
+// CacheReport is the cache status of each cached part of a report.
+type CacheReport struct {
+	UTXOs *CacheStatus `json:"utxos,omitempty"`
+	Fee   *CacheStatus `json:"fee_estimate,omitempty"`
+	LN    *CacheStatus `json:"ln,omitempty"`
+}
+
+// get decodes key into out when it is younger than ttl and, if tip is set,
+// was stored at the same best block.
+func (c *Cache) get(key string, ttl time.Duration, tip string, fresh bool, out interface{}) CacheStatus {
+	if fresh {
+		return CacheStatus{Reason: "refresh requested"}
+	}
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return CacheStatus{Reason: "not cached"}
+	}
+	age := time.Since(e.Stored)
+	st := CacheStatus{AgeSec: int64(age.Seconds())}
+	switch {
+	case age > ttl:
+		st.Reason = "expired"
+	case tip == "" && e.TipHash != "":
+		st.Reason = "best block unknown"
+	case e.TipHash != tip:
+		st.Reason = "new block"
+	default:
+		if err := json.Unmarshal(e.Value, out); err != nil {
+			st.Reason = "undecodable"
+			return st
+		}
+		st.Hit = true
+	}
+	return st
+}
+
+func (c *Cache) put(key, tip string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("cache store error (omitting): %v", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+	c.entries[key] = cacheEntry{Stored: time.Now(), TipHash: tip, Value: b}
+	if c.path != "" {
+		c.save()
+	}
+}
+
+// prune drops entries older than cacheMaxAge, so a long-running server
+// does not keep one per address ever queried. Called with c.mu held.
+func (c *Cache) prune() {
+	for k, e := range c.entries {
+		if time.Since(e.Stored) > cacheMaxAge {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// save writes the cache atomically with owner-only permissions: it holds
+// addresses and balances. Called with c.mu held.
+func (c *Cache) save() {
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		log.Printf("cache save error (omitting): %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".cache-*")
+	if err != nil {
+		log.Printf("cache save error (omitting): %v", err)
+		return
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil {
+		os.Remove(tmp.Name())
+		log.Printf("cache save error (omitting): %v %v", werr, cerr)
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("cache save error (omitting): %v", err)
+	}
+}
+
+// bestBlockHash returns the UTXO source's current tip for block-based
+// invalidation, or "" when it cannot be read. Tip lookups reveal nothing
+// about the address.
+func bestBlockHash(ctx context.Context, cfg Config, network btc.Network, fromNode bool) string {
+	var hash string
+	var err error
+	if fromNode {
+		rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		err = cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) error {
+			bi, err := rpc.GetBlockchainInfo(ctx)
+			hash = bi.BestBlockHash
+			return err
+		})
+	} else {
+		err = cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+			hash, err = btc.FetchTipHashExplorer(ctx, cfg.HTTPClient, cfg.explorer(network))
+			return err
+		})
+	}
+	if err != nil {
+		log.Printf("best block lookup error (cache bypassed): %v", err)
+		return ""
+	}
+	return hash
+}
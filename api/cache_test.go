@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/ln"
+)
+
+func TestCachePrunesInMemory(t *testing.T) {
+	c := NewCache("")
+	c.put("old", "", 1)
+	c.entries["old"] = cacheEntry{Stored: time.Now().Add(-cacheMaxAge - time.Minute), Value: c.entries["old"].Value}
+	c.put("new", "", 2)
+	if _, ok := c.entries["old"]; ok {
+		t.Errorf("entry older than %s kept without a cache file", cacheMaxAge)
+	}
+	var v int
+	if st := c.get("new", time.Minute, "", false, &v); !st.Hit || v != 2 {
+		t.Errorf("get new = %+v, %d", st, v)
+	}
+}
+
+func TestCollectLNKeyIncludesSCBMaxAge(t *testing.T) {
+	cfg := Config{Cache: NewCache(""), LNTTL: time.Minute, SCBFilePath: "/backup/channel.backup", SCBMaxAge: time.Hour}
+	b := &countingBackend{}
+	CollectLN(t.Context(), b, cfg, "mainnet", 10)
+	CollectLN(t.Context(), b, cfg, "mainnet", 10)
+	cfg.SCBMaxAge = 2 * time.Hour
+	CollectLN(t.Context(), b, cfg, "mainnet", 10)
+	if b.calls != 2 {
+		t.Errorf("getinfo calls = %d, want 2 (one cache hit, one miss on a new SCB max age)", b.calls)
+	}
+}
+
+// countingBackend is an empty node that counts getinfo calls.
+type countingBackend struct{ calls int }
+
+func (b *countingBackend) Name() string { return "fake" }
+func (b *countingBackend) GetInfo(context.Context) (ln.GetInfoResponse, error) {
+	b.calls++
+	return ln.GetInfoResponse{}, nil
+}
+func (b *countingBackend) ListChannels(context.Context) ([]ln.Channel, error) { return nil, nil }
+func (b *countingBackend) WalletBalance(context.Context) (ln.WalletBalance, error) {
+	return ln.WalletBalance{}, nil
+}
+func (b *countingBackend) ListUnspent(context.Context) ([]btc.UTXO, error) { return nil, nil }
+func (b *countingBackend) SecurityWarnings() []ln.Warning                  { return nil }
+func (b *countingBackend) Credential() *ln.Credential                      { return nil }
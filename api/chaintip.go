@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/ln"
+)
+
+// DataConfidence summarizes how far the report's inputs can be trusted.
+// It starts at 100 and is penalized when sources disagree.
+type DataConfidence struct {
+	Level   string   `json:"level"` // "HIGH", "MEDIUM" or "LOW"
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+func NewDataConfidence() DataConfidence {
+	return DataConfidence{Level: "HIGH", Score: 100, Reasons: []string{}}
+}
+
+func (d *DataConfidence) Penalize(points int, reason string) {
+	d.Score -= points
+	if d.Score < 0 {
+		d.Score = 0
+	}
+	d.Reasons = append(d.Reasons, reason)
+	switch {
+	case d.Score >= 80:
+		d.Level = "HIGH"
+	case d.Score >= 50:
+		d.Level = "MEDIUM"
+	default:
+		d.Level = "LOW"
+	}
+}
+
+// useBitcoind reports whether bitcoind is configured beyond the flag default.
+func (cfg Config) useBitcoind() bool {
+	return cfg.NodeOnly || cfg.RPCUser != ""
+}
+
+// explorer returns the Esplora endpoint for network under cfg's Tor policy.
+func (cfg Config) explorer(network btc.Network) btc.Explorer {
+	return btc.Explorer{Network: network, Onion: cfg.ExplorerOnion}
+}
+
+// CheckChainTips compares the best block across bitcoind, the explorer (unless
+// node-only) and the Lightning node, when each is available.
+func CheckChainTips(ctx context.Context, cfg Config, network btc.Network, lnReady *ln.Readiness) btc.TipConsistency {
+	var tips []btc.ChainTip
+	hashAt := map[string]func(int) (string, error){}
+
+	if cfg.useBitcoind() {
+		rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		tip := btc.ChainTip{Source: "bitcoind"}
+		var bi btc.BlockchainInfo
+		err := cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+			bi, err = rpc.GetBlockchainInfo(ctx)
+			return err
+		})
+		if err != nil {
+			tip.Error = err.Error()
+		} else {
+			tip.Height, tip.Hash, tip.Headers = bi.Blocks, bi.BestBlockHash, bi.Headers
+			hashAt["bitcoind"] = func(h int) (hash string, err error) {
+				err = cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+					hash, err = rpc.GetBlockHash(ctx, h)
+					return err
+				})
+				return hash, err
+			}
+		}
+		tips = append(tips, tip)
+	}
+
+	if !cfg.NodeOnly {
+		var tip btc.ChainTip
+		err := cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+			tip, err = btc.FetchTipExplorer(ctx, cfg.HTTPClient, cfg.explorer(network))
+			return err
+		})
+		if err != nil {
+			tip = btc.ChainTip{Source: "explorer", Error: err.Error()}
+		} else {
+			hashAt["explorer"] = func(h int) (hash string, err error) {
+				err = cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+					hash, err = btc.FetchBlockHashExplorer(ctx, cfg.HTTPClient, cfg.explorer(network), h)
+					return err
+				})
+				return hash, err
+			}
+		}
+		tips = append(tips, tip)
+	}
+
+	if lnReady != nil {
+		tips = append(tips, btc.ChainTip{
+			Source: lnReady.Backend,
+			Height: lnReady.Info.BlockHeight,
+			Hash:   lnReady.Info.BlockHash,
+		})
+	}
+
+	return btc.CompareTips(tips, hashAt)
+}
+
+// ApplyTipConsistency folds tip disagreements into the data confidence.
+func ApplyTipConsistency(conf *DataConfidence, tc btc.TipConsistency) {
+	if tc.ConfidencePenalty > 0 {
+		conf.Penalize(tc.ConfidencePenalty, "Chain sources disagree: "+strings.Join(tc.Warnings, " "))
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+// fakeEsplora answers the tip endpoints and records every host asked.
+func fakeEsplora(hosts *[]string, mu *sync.Mutex) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		*hosts = append(*hosts, r.URL.Scheme+"://"+r.URL.Host)
+		mu.Unlock()
+		body := "800000"
+		if strings.HasSuffix(r.URL.Path, "/block-height/800000") {
+			body = strings.Repeat("ab", 32)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})}
+}
+
+func TestExplorerPerConfig(t *testing.T) {
+	tests := []struct {
+		onion bool
+		want  string
+	}{
+		{false, "https://blockstream.info"},
+		{true, "http://explorerzydxu5ecjrkwceayqybizmpjjznk5izmitf2modhcusuqlid.onion"},
+	}
+	hosts := make([][]string, len(tests))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// Both servers share the process; neither may leak its choice to the other.
+	for i, tt := range tests {
+		cfg := Config{ExplorerOnion: tt.onion, HTTPClient: fakeEsplora(&hosts[i], &mu)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if tc := CheckChainTips(context.Background(), cfg, btc.Mainnet, nil); len(tc.Tips) != 1 || tc.Tips[0].Error != "" {
+					t.Errorf("tips = %+v", tc.Tips)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, tt := range tests {
+		if len(hosts[i]) == 0 {
+			t.Errorf("onion %t: no explorer requests", tt.onion)
+		}
+		for _, h := range hosts[i] {
+			if h != tt.want {
+				t.Errorf("onion %t asked %s, want %s", tt.onion, h, tt.want)
+				break
+			}
+		}
+	}
+}
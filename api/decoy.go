@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/netx"
+)
+
+// decoyPoolPages is how many 25-transaction pages of the tip block are
+// sampled for decoy addresses.
+const decoyPoolPages = 3
+
+// DecoyReport records what the decoy privacy mode cost for one query.
+type DecoyReport struct {
+	Requested     int      `json:"decoys_requested"`
+	Used          int      `json:"decoys_used"`
+	PoolSize      int      `json:"pool_size"`
+	AnonymitySet  int      `json:"anonymity_set"` // real + decoys
+	SameTypeAs    string   `json:"address_type"`
+	ExtraRequests int      `json:"extra_requests"`
+	DecoyErrors   int      `json:"decoy_errors"`
+	RealQueryMs   int64    `json:"real_query_ms"`
+	LatencyMs     int64    `json:"latency_ms"`  // wall time of pool fetch and all queries
+	OverheadMs    int64    `json:"overhead_ms"` // LatencyMs minus the real query alone
+	Isolated      bool     `json:"isolated_circuits"`
+	Notes         []string `json:"notes"`
+}
+
+// fetchWithDecoys queries address's UTXOs mixed with cfg.Decoys decoy
+// addresses from the tip block. Queries run in random order with random
+// start delays, each on its own Tor circuit; decoy results are discarded.
+func fetchWithDecoys(ctx context.Context, cfg Config, address string, network btc.Network) ([]btc.UTXO, *DecoyReport, error) {
+	start := time.Now()
+	rep := &DecoyReport{
+		Requested:  cfg.Decoys,
+		SameTypeAs: btc.AddressType(address),
+		Isolated:   cfg.Net.TorSocks5Addr != "",
+		Notes:      []string{},
+	}
+
+	var pool []btc.RecentAddress
+	poolClient := netx.IsolateQuery(cfg.HTTPClient, "decoy-pool")
+	err := cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+		pool, err = btc.FetchRecentAddressesExplorer(ctx, poolClient, cfg.explorer(network), decoyPoolPages)
+		return err
+	})
+	if err != nil {
+		rep.Notes = append(rep.Notes, "decoy pool unavailable, queried the real address alone: "+err.Error())
+	}
+	rep.PoolSize = len(pool)
+	rep.ExtraRequests = 2 + decoyPoolPages
+
+	decoys := btc.PickDecoys(pool, address, cfg.Decoys)
+	rep.Used = len(decoys)
+	rep.AnonymitySet = len(decoys) + 1
+	rep.ExtraRequests += len(decoys)
+	if len(decoys) < cfg.Decoys && err == nil {
+		rep.Notes = append(rep.Notes, "tip block had fewer usable addresses than requested decoys")
+	}
+	cfg.Ledger.Decoys(decoys...)
+
+	queries := append(decoys, address)
+	rand.Shuffle(len(queries), func(i, j int) { queries[i], queries[j] = queries[j], queries[i] })
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		utxos   []btc.UTXO
+		realErr error
+	)
+	for _, q := range queries {
+		wg.Add(1)
+		go func(q string) {
+			defer wg.Done()
+			if cfg.DecoyJitter > 0 {
+				timer := time.NewTimer(time.Duration(rand.Int63n(int64(cfg.DecoyJitter))))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+				case <-timer.C:
+				}
+			}
+			client := netx.IsolateQuery(cfg.HTTPClient, q)
+			t := time.Now()
+			var res []btc.UTXO
+			err := cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+				res, err = btc.FetchUTXOsExplorer(ctx, client, q, cfg.explorer(network))
+				return err
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if q == address {
+				utxos, realErr = res, err
+				rep.RealQueryMs = time.Since(t).Milliseconds()
+			} else if err != nil {
+				rep.DecoyErrors++
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	rep.LatencyMs = time.Since(start).Milliseconds()
+	rep.OverheadMs = rep.LatencyMs - rep.RealQueryMs
+	if !rep.Isolated {
+		rep.Notes = append(rep.Notes, "without Tor all queries come from the same IP; decoys only hide which address is ours among them")
+	}
+	rep.Notes = append(rep.Notes, "explorer merkle proof verification is skipped so the real txids are not looked up")
+	return utxos, rep, realErr
+}
@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/ln"
+	"sovereign-checker/netx"
+	"sovereign-checker/planner"
+	"sovereign-checker/score"
+)
+
+// LNWallet is the Lightning node's own on-chain wallet, scored like any other UTXO set.
+type LNWallet struct {
+	Balance       ln.WalletBalance `json:"balance"`
+	OnChain       score.Result     `json:"onchain"`
+	AnchorReserve ln.AnchorReserve `json:"anchor_reserve"`
+}
+
+// LNReport gathers everything fetched from the Lightning backend for one report. Any part may be
+// nil when the corresponding call failed.
+type LNReport struct {
+	Readiness  *ln.Readiness
+	ExitPlan   *ln.ExitPlan
+	Wallet     *LNWallet
+	Recovery   *ln.Recovery
+	Receive    *ln.ReceiveReadiness
+	Health     *ln.ChannelHealthReport
+	RoutingFee *ln.RoutingFeeEstimate
+	Channels   []ln.Channel
+	Cache      *CacheStatus `json:"-"`
+}
+
+// nodeClient returns cfg.LNDClient if set, otherwise a client for a node's
+// REST endpoint that verifies with tlsCfg and follows cfg.Net's Tor policy.
+// Either way requests are recorded in cfg.Ledger.
+func nodeClient(cfg Config, tlsCfg ln.TLSConfig) (*http.Client, error) {
+	if cfg.LNDClient != nil {
+		return netx.WithLedger(cfg.LNDClient, cfg.Ledger), nil
+	}
+	tc, err := tlsCfg.Build()
+	if err != nil {
+		return nil, err
+	}
+	nc := cfg.Net
+	nc.Timeout = 10 * time.Second
+	nc.TLSConfig = tc
+	c, err := netx.NewHTTPClient(nc)
+	if err != nil {
+		return nil, err
+	}
+	return netx.WithLedger(c, cfg.Ledger), nil
+}
+
+// appliedTLS is the TLS config the node client actually uses: an injected
+// cfg.LNDClient brings its own, so tlsCfg only holds for built clients.
+func appliedTLS(cfg Config, tlsCfg ln.TLSConfig) ln.TLSConfig {
+	if cfg.LNDClient != nil {
+		return ln.TLSConfig{CustomClient: true}
+	}
+	return tlsCfg
+}
+
+// NewLNBackend builds the Lightning backend selected in cfg. It returns nil
+// without error when the selected backend has no credentials configured.
+func NewLNBackend(cfg Config) (ln.Backend, error) {
+	switch cfg.LNBackend {
+	case "", "lnd":
+		tlsCfg := ln.TLSConfig{
+			CertPath:  cfg.LNDTLSCertPath,
+			PinSHA256: cfg.LNDCertPin,
+			Insecure:  cfg.LNDTLSInsecure,
+		}
+		if cfg.LNDConnectURI != "" {
+			lc, err := ln.ParseLNDConnect(cfg.LNDConnectURI)
+			if err != nil {
+				return nil, err
+			}
+			if len(lc.CertPEM) > 0 {
+				tlsCfg.CertPEM = lc.CertPEM
+			}
+			client, err := nodeClient(cfg, tlsCfg)
+			if err != nil {
+				return nil, err
+			}
+			c, err := ln.NewLNDClientFromMacaroon(lc.BaseURL, lc.Macaroon, client, appliedTLS(cfg, tlsCfg))
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+		if cfg.MacaroonPath == "" || cfg.LNDBaseURL == "" {
+			return nil, nil
+		}
+		client, err := nodeClient(cfg, tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ln.NewLNDClient(cfg.LNDBaseURL, cfg.MacaroonPath, client, appliedTLS(cfg, tlsCfg))
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case "cln":
+		if cfg.CLNSocketPath != "" {
+			return ln.NewCLNSocketClient(cfg.CLNSocketPath), nil
+		}
+		if cfg.CLNRestURL == "" || cfg.CLNRunePath == "" {
+			return nil, nil
+		}
+		tlsCfg := ln.TLSConfig{CertPath: cfg.CLNTLSCertPath, Insecure: cfg.LNDTLSInsecure}
+		client, err := nodeClient(cfg, tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ln.NewCLNRestClient(cfg.CLNRestURL, cfg.CLNRunePath, client, appliedTLS(cfg, tlsCfg))
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown ln backend: %s", cfg.LNBackend)
+	}
+}
+
+// CollectLN returns the Lightning report, from the cache when one younger
+// than cfg.LNTTL exists for the same node and parameters.
+func CollectLN(ctx context.Context, c ln.Backend, cfg Config, network btc.Network, feeRate uint64) LNReport {
+	if cfg.Cache == nil || cfg.LNTTL <= 0 {
+		return collectLN(ctx, c, cfg, network, feeRate)
+	}
+	node := sha256.Sum256([]byte(strings.Join([]string{c.Name(), cfg.LNDBaseURL, cfg.LNDConnectURI, cfg.CLNSocketPath, cfg.CLNRestURL}, "|")))
+	var paySize uint64
+	if cfg.PaymentsPerMonth > 0 {
+		paySize = cfg.PaymentSizeSats
+	}
+	key := fmt.Sprintf("ln|%x|%s|%d|%d|%d|%t|%d|%d|%s|%s|%d|%s", node[:8], network, cfg.ReceiveAmountSats, feeRate, cfg.StressFeeMultiplier,
+		cfg.ChannelHealth, cfg.HealthWindowDays, cfg.HealthIdleDays, cfg.SCBFilePath, cfg.SCBMaxAge, paySize, strings.Join(cfg.RoutingFeeDests, ","))
+	var out LNReport
+	st := cfg.Cache.get(key, cfg.LNTTL, "", cfg.Fresh, &out)
+	if !st.Hit {
+		out = collectLN(ctx, c, cfg, network, feeRate)
+		if out.Readiness != nil {
+			cfg.Cache.put(key, "", out)
+		}
+	}
+	out.Cache = &st
+	return out
+}
+
+// feeProbeDestinations is how many nodes past our peers the routing fee
+// for the payment profile is estimated to.
+const feeProbeDestinations = 3
+
+// collectLN runs every Lightning check within cfg.LNBudget. A node that times
+// out on getinfo is treated as hung and the remaining calls are skipped, so
+// it cannot stall the rest of the report.
+func collectLN(ctx context.Context, c ln.Backend, cfg Config, network btc.Network, feeRate uint64) LNReport {
+	var out LNReport
+	budget := cfg.LNBudget
+
+	var ready ln.Readiness
+	err := budget.Run(ctx, func(ctx context.Context) (err error) {
+		ready, err = ln.ReadinessFor(ctx, c)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s getinfo error (omitting): %v", c.Name(), err)
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return out
+		}
+	} else {
+		out.Readiness = &ready
+	}
+
+	var channels []ln.Channel
+	err = budget.Run(ctx, func(ctx context.Context) (err error) {
+		channels, err = c.ListChannels(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s listchannels error (omitting): %v", c.Name(), err)
+		return out
+	}
+	if out.Readiness != nil {
+		rr := ln.ComputeReceiveReadiness(out.Readiness.Info, channels, cfg.ReceiveAmountSats)
+		out.Receive = &rr
+	}
+
+	out.Channels = channels
+
+	if pp, ok := c.(ln.PaymentProber); ok && cfg.PaymentsPerMonth > 0 && cfg.PaymentSizeSats > 0 {
+		rctx, cancel := budget.WithTimeout(ctx)
+		dests := cfg.RoutingFeeDests
+		if nl, ok := c.(ln.NeighborLister); ok && len(dests) == 0 && out.Readiness != nil {
+			dests = ln.FeeProbeDestinations(rctx, nl, out.Readiness.Info.IdentityPubkey, channels, feeProbeDestinations)
+		}
+		est := ln.EstimateRoutingFee(rctx, pp, dests, cfg.PaymentSizeSats)
+		cancel()
+		out.RoutingFee = &est
+	}
+
+	plan := ln.ComputeExitPlan(channels, feeRate, ln.StressedFeeRate(feeRate, cfg.StressFeeMultiplier))
+	out.ExitPlan = &plan
+
+	if rc, ok := c.(ln.RecoveryChecker); ok {
+		rctx, cancel := budget.WithTimeout(ctx)
+		rec := ln.CheckRecovery(rctx, rc, channels, ln.RecoveryOptions{
+			BackupFilePath: cfg.SCBFilePath,
+			MaxBackupAge:   cfg.SCBMaxAge,
+		})
+		cancel()
+		out.Recovery = &rec
+		if out.Readiness != nil {
+			for _, w := range rec.Warnings {
+				out.Readiness.Warn(w)
+			}
+		}
+	}
+
+	if ri, ok := c.(ln.RoutingInspector); ok && cfg.ChannelHealth && out.Readiness != nil {
+		hctx, cancel := budget.WithTimeout(ctx)
+		h := ln.ChannelHealthFor(hctx, ri, out.Readiness.Info.IdentityPubkey, channels, ln.HealthOptions{
+			WindowDays:  cfg.HealthWindowDays,
+			IdleDays:    cfg.HealthIdleDays,
+			BlockHeight: out.Readiness.Info.BlockHeight,
+		})
+		cancel()
+		out.Health = &h
+	}
+
+	var bal ln.WalletBalance
+	err = budget.Run(ctx, func(ctx context.Context) (err error) {
+		bal, err = c.WalletBalance(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s walletbalance error (omitting): %v", c.Name(), err)
+		return out
+	}
+	var utxos []btc.UTXO
+	err = budget.Run(ctx, func(ctx context.Context) (err error) {
+		utxos, err = c.ListUnspent(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s listunspent error (omitting): %v", c.Name(), err)
+		return out
+	}
+	reserve := ln.ComputeAnchorReserve(utxos, plan)
+	out.Wallet = &LNWallet{
+		Balance: bal,
+		OnChain: score.Compute(score.Input{
+			Address:      c.Name() + "-wallet",
+			Network:      network,
+			Mode:         c.Name(),
+			UTXOs:        utxos,
+			FeeRateSatVB: feeRate,
+		}),
+		AnchorReserve: reserve,
+	}
+	if out.Readiness != nil {
+		if !reserve.CanCPFP {
+			out.Readiness.Penalize(20, "Node wallet cannot CPFP anchor channel force closes at the current fee rate")
+		} else if !reserve.CanCPFPStressed {
+			out.Readiness.Penalize(10, "Node wallet cannot CPFP anchor channel force closes at stressed fee rates")
+		}
+	}
+
+	return out
+}
+
+// ProbePayment decodes payReq and checks whether b's channels can pay it.
+// amountSats is only used for invoices without an amount. For invoices with
+// route hints LND sends a probe payment (see ln.LNDClient.EstimateRoute).
+func ProbePayment(ctx context.Context, budget netx.Budget, b ln.Backend, payReq string, amountSats uint64) (ln.PaymentProbe, error) {
+	inv, err := ln.DecodeInvoice(payReq)
+	if err != nil {
+		return ln.PaymentProbe{}, err
+	}
+	if inv.AmountMsat > 0 {
+		amountSats = (inv.AmountMsat + 999) / 1000
+	}
+
+	var channels []ln.Channel
+	err = budget.Run(ctx, func(ctx context.Context) (err error) {
+		channels, err = b.ListChannels(ctx)
+		return err
+	})
+	if err != nil {
+		return ln.PaymentProbe{}, err
+	}
+
+	var route *ln.RouteEstimate
+	if pp, ok := b.(ln.PaymentProber); ok && amountSats > 0 {
+		rctx, cancel := budget.WithTimeout(ctx)
+		est, err := pp.EstimateRoute(rctx, inv, payReq, amountSats)
+		cancel()
+		if err != nil {
+			log.Printf("%s route estimate error: %v", b.Name(), err)
+			est = ln.RouteEstimate{Found: false, FailureReason: err.Error()}
+		}
+		route = &est
+	}
+	return ln.ComputePaymentCapability(inv, amountSats, channels, route, time.Now()), nil
+}
+
+// ApplyPaymentProbe folds a probe into readiness: a node that cannot pay the
+// requested invoice is not ready for it.
+func ApplyPaymentProbe(ready *ln.Readiness, probe ln.PaymentProbe) {
+	ready.Payment = &probe
+	if !probe.Payable {
+		ready.Ready = false
+		ready.Reasons = append(ready.Reasons, "Cannot pay the requested invoice")
+	}
+}
+
+// CompareCosts projects cfg's payment profile on-chain vs over Lightning,
+// funding any new channel from utxos. Routing fees come from the node's
+// estimate in lnr, or cfg's base + ppm as a labelled fallback. Returns nil
+// when no profile is set.
+func CompareCosts(cfg Config, utxos []btc.UTXO, feeRate uint64, lnr LNReport) *planner.CostComparison {
+	if cfg.PaymentsPerMonth <= 0 || cfg.PaymentSizeSats == 0 {
+		return nil
+	}
+	var outbound uint64
+	for _, ch := range lnr.Channels {
+		outbound += ln.ChannelOutbound(ch)
+	}
+	in := planner.CostInputs{
+		Profile: planner.PaymentProfile{
+			PaymentsPerMonth: cfg.PaymentsPerMonth,
+			PaymentSizeSats:  cfg.PaymentSizeSats,
+			HorizonMonths:    cfg.HorizonMonths,
+		},
+		FeeRateSatVB:         feeRate,
+		UTXOs:                utxos,
+		ExistingOutboundSats: outbound,
+		RoutingFeeBaseMsat:   cfg.RoutingFeeBaseMsat,
+		RoutingFeePPM:        cfg.RoutingFeePPM,
+	}
+	if rf := lnr.RoutingFee; rf != nil && rf.Routed > 0 && rf.AmountSats == cfg.PaymentSizeSats {
+		in.RoutingFeeMsat, in.RoutingFeeSource = &rf.FeeMsat, rf.Source
+	}
+	c := planner.CompareLNOnChain(in)
+	return &c
+}
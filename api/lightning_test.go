@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sovereign-checker/ln"
+)
+
+func TestNewLNBackendTLSWarnings(t *testing.T) {
+	dir := t.TempDir()
+	mac := filepath.Join(dir, "readonly.macaroon")
+	if err := os.WriteFile(mac, []byte{2, 1}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pin := strings.Repeat("ab", 32)
+	base := Config{LNDBaseURL: "https://127.0.0.1:8080", MacaroonPath: mac, LNDCertPin: pin}
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		want   string // expected TLS warning; "" = none
+	}{
+		{"pinned client built from the config", nil, ""},
+		{"injected client ignores the pin", &http.Client{}, "unverified (custom client)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.LNDClient = tt.client
+			b, err := NewLNBackend(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, w := range b.SecurityWarnings() {
+				if strings.Contains(w.Message, "TLS") {
+					got = append(got, w.Message)
+				}
+			}
+			switch {
+			case tt.want == "" && len(got) > 0:
+				t.Errorf("unexpected TLS warnings %v", got)
+			case tt.want != "" && (len(got) != 1 || !strings.Contains(got[0], tt.want)):
+				t.Errorf("TLS warnings = %v, want %q", got, tt.want)
+			}
+			if lnd, ok := b.(*ln.LNDClient); ok && tt.client != nil && lnd.TLS.Verified() {
+				t.Error("injected client reported as verified")
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"sovereign-checker/btc"
+	"sovereign-checker/score"
+)
+
+// CheckNode builds the bitcoind health report, or nil when bitcoind is not
+// configured or unreachable.
+func CheckNode(ctx context.Context, cfg Config) *score.NodeHealth {
+	if !cfg.useBitcoind() {
+		return nil
+	}
+	rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+	var ni btc.NodeInfo
+	err := cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+		ni, err = rpc.FetchNodeInfo(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("bitcoind node info error (omitting): %v", err)
+		return nil
+	}
+	h := score.ComputeNode(ni, time.Now())
+	return &h
+}
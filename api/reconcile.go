@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"sovereign-checker/btc"
+)
+
+type Discrepancy struct {
+	TxID   string `json:"txid"`
+	Vout   int    `json:"vout"`
+	Kind   string `json:"kind"` // missing_on_node, missing_on_explorer, value_mismatch, confirmation_mismatch
+	Detail string `json:"detail"`
+}
+
+// Reconciliation compares the explorer's UTXO set for an address with the
+// node's chainstate (scantxoutset) and per-output gettxout lookups.
+type Reconciliation struct {
+	ExplorerUTXOs int           `json:"explorer_utxos"`
+	NodeUTXOs     int           `json:"node_utxos"`
+	Matched       int           `json:"matched"`
+	Consistent    bool          `json:"consistent"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	Notes         []string      `json:"notes"`
+}
+
+// ReconcileUTXOs runs only when both an explorer and bitcoind are in use;
+// otherwise it returns nil.
+func ReconcileUTXOs(ctx context.Context, cfg Config, address string, explorer []btc.UTXO) *Reconciliation {
+	if cfg.NodeOnly || !cfg.useBitcoind() {
+		return nil
+	}
+	rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+	rec := &Reconciliation{
+		ExplorerUTXOs: len(explorer),
+		Consistent:    true,
+		Discrepancies: []Discrepancy{},
+		Notes:         []string{},
+	}
+	add := func(u btc.UTXO, kind, detail string) {
+		rec.Consistent = false
+		rec.Discrepancies = append(rec.Discrepancies, Discrepancy{TxID: u.TxID, Vout: u.Vout, Kind: kind, Detail: detail})
+	}
+
+	for _, u := range explorer {
+		var out *btc.TxOut
+		err := cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+			out, err = rpc.GetTxOut(ctx, u.TxID, u.Vout, true)
+			return err
+		})
+		if err != nil {
+			log.Printf("bitcoind gettxout error (omitting reconciliation): %v", err)
+			return nil
+		}
+		if out == nil {
+			add(u, "missing_on_node", "node reports this output as spent or unknown")
+			continue
+		}
+		ok := true
+		if v := btcToSatsRounded(out.ValueBTC); v != u.ValueSats {
+			ok = false
+			add(u, "value_mismatch", fmt.Sprintf("explorer %d sats, node %d sats", u.ValueSats, v))
+		}
+		if u.Confirmed != (out.Confirmations > 0) {
+			ok = false
+			add(u, "confirmation_mismatch", fmt.Sprintf("explorer confirmed=%t, node confirmations=%d", u.Confirmed, out.Confirmations))
+		}
+		if ok {
+			rec.Matched++
+		}
+	}
+
+	var node []btc.UTXO
+	err := cfg.ScanBudget.Run(ctx, func(ctx context.Context) (err error) {
+		node, err = rpc.ScanTxOutSet(ctx, address)
+		return err
+	})
+	if err != nil {
+		rec.Notes = append(rec.Notes, "scantxoutset unavailable; outputs missing from the explorer were not checked: "+err.Error())
+		return rec
+	}
+	rec.NodeUTXOs = len(node)
+	seen := map[string]bool{}
+	for _, u := range explorer {
+		seen[fmt.Sprintf("%s:%d", u.TxID, u.Vout)] = true
+	}
+	for _, u := range node {
+		if !seen[fmt.Sprintf("%s:%d", u.TxID, u.Vout)] {
+			add(u, "missing_on_explorer", fmt.Sprintf("node has %d sats confirmed at height %d", u.ValueSats, u.BlockHeight))
+		}
+	}
+	rec.Notes = append(rec.Notes, "scantxoutset covers confirmed outputs only; mempool outputs were checked with gettxout.")
+	return rec
+}
+
+// btcToSatsRounded avoids the off-by-one that truncating float BTC amounts gives.
+func btcToSatsRounded(b float64) uint64 {
+	return uint64(b*100_000_000 + 0.5)
+}
+
+// ApplyReconciliation penalizes data confidence for each discrepancy, up to 40 points.
+func ApplyReconciliation(conf *DataConfidence, rec *Reconciliation) {
+	if rec == nil || rec.Consistent {
+		return
+	}
+	points := 10 * len(rec.Discrepancies)
+	if points > 40 {
+		points = 40
+	}
+	conf.Penalize(points, fmt.Sprintf("Explorer and node disagree on %d UTXO(s).", len(rec.Discrepancies)))
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"sovereign-checker/btc"
 	"sovereign-checker/ln"
+	"sovereign-checker/netx"
 	"sovereign-checker/planner"
 	"sovereign-checker/score"
 )
@@ -18,38 +23,150 @@ type Config struct {
 	Network         btc.Network
 	FeeRateFallback uint64
 	FeeLowSatVB     uint64
+	// Multiplier applied to the current fee rate for stressed LN exit costs
+	StressFeeMultiplier uint64
+	// Amount to check LN receive readiness against (0 = no amount check)
+	ReceiveAmountSats uint64
+
+	// Payment profile for the LN vs on-chain cost comparison (0 = skip)
+	PaymentsPerMonth   int
+	PaymentSizeSats    uint64
+	HorizonMonths      int
+	RoutingFeeBaseMsat uint64 // fallback when the node gives no route estimate
+	RoutingFeePPM      uint64
+	RoutingFeeDests    []string // nodes to estimate fees to; default: past our peers (LND)
 
 	// Shared HTTP client (may be Tor-routed)
 	HTTPClient *http.Client
+	// Outbound policy HTTPClient was built from; node REST clients reuse it
+	Net netx.ClientConfig
+	// Tor verification done at startup (nil without -tor)
+	Tor *netx.TorStatus
+	// Use the explorers' onion services; only set when traffic goes through Tor
+	ExplorerOnion bool
+	// API keys for server mode; nil leaves the API open
+	Auth *KeyStore
+	// Per-run record of outbound requests; set by forAddress / the CLI
+	Ledger *netx.Ledger
+	// Decoy addresses mixed into explorer UTXO queries (0 = off), started
+	// at random offsets within DecoyJitter
+	Decoys      int
+	DecoyJitter time.Duration
+
+	// Cache for UTXO sets (also dropped on a new block), fee estimates and
+	// LN reports; a zero TTL disables that part. Fresh skips lookups.
+	Cache   *Cache
+	UTXOTTL time.Duration
+	FeeTTL  time.Duration
+	LNTTL   time.Duration
+	Fresh   bool
+
+	// Most reports /report/batch builds at once
+	BatchConcurrency int
+
+	// Per-backend deadlines and retries
+	ExplorerBudget netx.Budget
+	BitcoindBudget netx.Budget
+	LNBudget       netx.Budget
+	// scantxoutset walks the whole UTXO set, minutes on mainnet, so scans
+	// get their own budget instead of BitcoindBudget
+	ScanBudget netx.Budget
+	// Server write deadline per response (0 = none); responses that may
+	// include a scan get ScanBudget on top
+	WriteTimeout time.Duration
 
 	// bitcoind (optional)
 	RPCURL  string
 	RPCUser string
 	RPCPass string
 
-	// LND (optional)
-	LNDEnabled     bool
+	// Lightning (optional). LNDEnabled gates LN checks in server mode for
+	// either backend; LNBackend selects "lnd" (default) or "cln".
+	LNDEnabled bool
+	LNBackend  string
+
+	// LND. LNDConnectURI, when set, supplies the URL, macaroon and cert.
 	LNDBaseURL     string
 	MacaroonPath   string
 	LNDClient      *http.Client
 	LNDTLSInsecure bool
+	LNDTLSCertPath string
+	LNDCertPin     string // hex SHA-256 of the leaf cert
+	LNDConnectURI  string
+
+	// Core Lightning: unix socket, or clnrest URL + rune file
+	CLNSocketPath  string
+	CLNRestURL     string
+	CLNRunePath    string
+	CLNTLSCertPath string
+
+	// Channel backup checks (LND): off-node copy of channel.backup and its max age
+	SCBFilePath string
+	SCBMaxAge   time.Duration
+
+	// Routing channel health (LND): forwarding window and idle threshold in days
+	ChannelHealth    bool
+	HealthWindowDays int
+	HealthIdleDays   int
 }
 
 type Server struct {
 	cfg Config
+	ln  ln.Backend // built once so connections and breaker state are shared
 }
 
-func NewServer(cfg Config) *Server { return &Server{cfg: cfg} }
+// NewServer builds the Lightning backend once when LN is enabled; requests
+// get ledgered views of it from lnBackend.
+func NewServer(cfg Config) *Server {
+	s := &Server{cfg: cfg}
+	if cfg.LNDEnabled {
+		bcfg := cfg
+		bcfg.Ledger = nil
+		b, err := NewLNBackend(bcfg)
+		if err != nil {
+			log.Printf("ln init error (omitting): %v", err)
+		}
+		s.ln = b
+	}
+	return s
+}
 
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/check", s.handleCheck)
-	mux.HandleFunc("/lnready", s.handleLNReady)
-	mux.HandleFunc("/report", s.handleReport) // NEW
+	mux.HandleFunc("/check", s.require(ScopeOnchainRead, s.handleCheck))
+	mux.HandleFunc("/lnready", s.require(ScopeLNRead, s.handleLNReady))
+	mux.HandleFunc("/lnreceive", s.require(ScopeLNRead, s.handleLNReceive))
+	mux.HandleFunc("/report", s.require(ScopeOnchainRead, s.handleReport)) // NEW
+	mux.HandleFunc("/report/batch", s.require(ScopeOnchainRead, s.handleReportBatch))
+	if s.cfg.Auth != nil {
+		mux.HandleFunc("/admin/keys", s.require(ScopeAdmin, s.handleAdminKeys))
+	}
 	return mux
 }
 
+// allowScan pushes the response's write deadline past a scantxoutset, which
+// can legitimately outlast the server's WriteTimeout. Only bitcoind scans.
+func (s *Server) allowScan(w http.ResponseWriter) {
+	if s.cfg.WriteTimeout <= 0 || !s.cfg.useBitcoind() {
+		return
+	}
+	var d time.Time // unbounded scans get no write deadline either
+	if s.cfg.ScanBudget.Timeout > 0 {
+		d = time.Now().Add(s.cfg.WriteTimeout + s.cfg.ScanBudget.Timeout)
+	}
+	_ = http.NewResponseController(w).SetWriteDeadline(d)
+}
+
+// forAddress returns a view of s whose outbound client is Tor-isolated for
+// addr and records every request in a fresh privacy ledger.
+func (s *Server) forAddress(addr string) *Server {
+	cfg := s.cfg
+	cfg.Ledger = &netx.Ledger{}
+	cfg.HTTPClient = netx.WithLedger(netx.Isolate(cfg.HTTPClient, addr), cfg.Ledger)
+	return &Server{cfg: cfg, ln: s.ln}
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -66,46 +183,199 @@ func (s *Server) resolveNetworkFromQuery(r *http.Request) btc.Network {
 	return s.cfg.Network
 }
 
-func (s *Server) fetchUTXOs(address string, network btc.Network) ([]btc.UTXO, uint64, string, error) {
-	mode := "explorer"
-	feeRate := s.cfg.FeeRateFallback
+// Fetched is an address's UTXO set with the fee rate and source mode it was
+// loaded with. Decoys is set when decoy queries hid the address; the cache
+// statuses when caching is on.
+type Fetched struct {
+	UTXOs     []btc.UTXO
+	FeeRate   uint64
+	Mode      string
+	Decoys    *DecoyReport
+	UTXOCache *CacheStatus
+	FeeCache  *CacheStatus
+}
+
+type cachedUTXOs struct {
+	UTXOs []btc.UTXO `json:"utxos"`
+	Mode  string     `json:"mode"`
+}
 
-	if s.cfg.NodeOnly {
-		mode = "nodeonly"
-		rpc := btc.NewBitcoindRPC(s.cfg.RPCURL, s.cfg.RPCUser, s.cfg.RPCPass, s.cfg.HTTPClient)
-		utxos, err := rpc.UTXOsForAddress(address)
-		if err != nil {
-			return nil, 0, mode, err
+// FetchUTXOs loads address's UTXOs from bitcoind (node-only) or the explorer
+// within the backend's budget. Output descriptors are scanned with bitcoind's
+// scantxoutset. Cached sets are reused until cfg.UTXOTTL passes or a new
+// block arrives.
+func FetchUTXOs(ctx context.Context, cfg Config, address string, network btc.Network) (Fetched, error) {
+	f := Fetched{Mode: "explorer"}
+	if a, ok := btc.DescriptorAddress(address); ok {
+		address = a
+	}
+	switch {
+	case btc.IsDescriptor(address):
+		if !cfg.useBitcoind() {
+			return f, errors.New("descriptors need bitcoind (-rpcuser); explorers only take addresses")
+		}
+		f.Mode = "descriptor"
+	case cfg.NodeOnly:
+		f.Mode = "nodeonly"
+	}
+	fromNode := f.Mode != "explorer"
+
+	useCache := cfg.Cache != nil && cfg.UTXOTTL > 0
+	key := "utxos|" + string(network) + "|" + f.Mode + "|" + address
+	var tip string
+	hit := false
+	if useCache {
+		tip = bestBlockHash(ctx, cfg, network, fromNode)
+		var v cachedUTXOs
+		st := cfg.Cache.get(key, cfg.UTXOTTL, tip, cfg.Fresh, &v)
+		f.UTXOCache = &st
+		if hit = st.Hit; hit {
+			f.UTXOs, f.Mode = v.UTXOs, v.Mode
+		}
+	}
+	if !hit {
+		mode := f.Mode
+		if err := fetchUTXOs(ctx, cfg, address, network, &f); err != nil {
+			return f, err
 		}
-		if est, err := rpc.EstimateSmartFee(6); err == nil && est.FeeRateBTCPerKB > 0 {
-			feeRate = planner.BTCPerKBToSatsPerVB(est.FeeRateBTCPerKB)
+		if useCache && tip != "" && f.Mode == mode {
+			cfg.Cache.put(key, tip, cachedUTXOs{UTXOs: f.UTXOs, Mode: f.Mode})
 		}
-		return utxos, feeRate, mode, nil
+	}
+	f.FeeRate, f.FeeCache = feeRate(ctx, cfg, fromNode)
+	return f, nil
+}
+
+func fetchUTXOs(ctx context.Context, cfg Config, address string, network btc.Network, f *Fetched) error {
+	switch f.Mode {
+	case "descriptor":
+		rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		return cfg.ScanBudget.Run(ctx, func(ctx context.Context) (err error) {
+			f.UTXOs, err = rpc.ScanDescriptor(ctx, address)
+			return err
+		})
+	case "nodeonly":
+		rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		return cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+			f.UTXOs, err = rpc.UTXOsForAddress(ctx, address)
+			return err
+		})
 	}
 
-	utxos, err := btc.FetchUTXOsExplorer(s.cfg.HTTPClient, address, network)
-	return utxos, feeRate, mode, err
+	var err error
+	if cfg.Decoys > 0 {
+		f.UTXOs, f.Decoys, err = fetchWithDecoys(ctx, cfg, address, network)
+	} else {
+		err = cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+			f.UTXOs, err = btc.FetchUTXOsExplorer(ctx, cfg.HTTPClient, address, cfg.explorer(network))
+			return err
+		})
+	}
+	if errors.Is(err, netx.ErrCircuitOpen) && cfg.useBitcoind() {
+		// Every explorer is failing; the node's chainstate has the confirmed UTXOs.
+		rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		var node []btc.UTXO
+		if nerr := cfg.ScanBudget.Run(ctx, func(ctx context.Context) (err error) {
+			node, err = rpc.ScanTxOutSet(ctx, address)
+			return err
+		}); nerr == nil {
+			cfg.Ledger.Fallback("explorer", "bitcoind", err.Error())
+			f.UTXOs, f.Mode = node, "bitcoind-fallback"
+			return nil
+		}
+	}
+	return err
 }
 
-func (s *Server) maybeLNReadiness() *ln.Readiness {
-	if !s.cfg.LNDEnabled || s.cfg.MacaroonPath == "" || s.cfg.LNDBaseURL == "" {
-		return nil
+// feeRate returns bitcoind's 6-block estimate when UTXOs come from the node,
+// cached for cfg.FeeTTL, and the configured fallback otherwise.
+func feeRate(ctx context.Context, cfg Config, fromNode bool) (uint64, *CacheStatus) {
+	if !fromNode {
+		return cfg.FeeRateFallback, nil
 	}
-	c, err := ln.NewLNDClient(s.cfg.LNDBaseURL, s.cfg.MacaroonPath, s.cfg.LNDClient, s.cfg.LNDTLSInsecure)
-	if err != nil {
-		log.Printf("lnd init error (omitting): %v", err)
-		return nil
+	useCache := cfg.Cache != nil && cfg.FeeTTL > 0
+	key := "fee|" + cfg.RPCURL
+	var rate uint64
+	var st *CacheStatus
+	if useCache {
+		s := cfg.Cache.get(key, cfg.FeeTTL, "", cfg.Fresh, &rate)
+		if st = &s; s.Hit {
+			return rate, st
+		}
 	}
-	info, err := c.GetInfo()
-	if err != nil {
-		log.Printf("lnd getinfo error (omitting): %v", err)
-		return nil
+	rpc := btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+	ectx, cancel := cfg.BitcoindBudget.WithTimeout(ctx)
+	defer cancel()
+	est, err := rpc.EstimateSmartFee(ectx, 6)
+	if err != nil || est.FeeRateBTCPerKB <= 0 {
+		return cfg.FeeRateFallback, st
+	}
+	rate = planner.BTCPerKBToSatsPerVB(est.FeeRateBTCPerKB)
+	if useCache {
+		cfg.Cache.put(key, "", rate)
+	}
+	return rate, st
+}
+
+// lnBackend returns the shared backend with its HTTP requests recorded in
+// this run's ledger, or nil when LN is off or unavailable.
+func (s *Server) lnBackend() ln.Backend {
+	if s.ln == nil || s.cfg.Ledger == nil {
+		return s.ln
+	}
+	record := func(c *http.Client) *http.Client { return netx.WithLedger(c, s.cfg.Ledger) }
+	switch b := s.ln.(type) {
+	case *ln.LNDClient:
+		return b.WithHTTPClient(record)
+	case *ln.CLNClient:
+		return b.WithHTTPClient(record)
+	}
+	return s.ln
+}
+
+// maybeLN collects the LN report with the request's receive amount and the
+// payment profile of profile, which sizes the routing fee estimate.
+func (s *Server) maybeLN(ctx context.Context, network btc.Network, feeRate, receiveSats uint64, profile Config) LNReport {
+	b := s.lnBackend()
+	if b == nil {
+		return LNReport{}
+	}
+	cfg := s.cfg
+	cfg.ReceiveAmountSats = receiveSats
+	cfg.PaymentsPerMonth, cfg.PaymentSizeSats = profile.PaymentsPerMonth, profile.PaymentSizeSats
+	return CollectLN(ctx, b, cfg, network, feeRate)
+}
+
+func (s *Server) receiveAmount(r *http.Request) (uint64, error) {
+	a := r.URL.Query().Get("receive_sats")
+	if a == "" {
+		return s.cfg.ReceiveAmountSats, nil
 	}
-	ready := ln.ComputeReadiness(info)
-	return &ready
+	return strconv.ParseUint(a, 10, 64)
 }
 
-func sovereigntySummary(onchain score.Result, plan planner.ConsolidationPlan, lnReady *ln.Readiness) string {
+// paymentProfile returns cfg with pay_count / pay_size_sats query overrides.
+func (s *Server) paymentProfile(r *http.Request) (Config, error) {
+	cfg := s.cfg
+	q := r.URL.Query()
+	if v := q.Get("pay_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.PaymentsPerMonth = n
+	}
+	if v := q.Get("pay_size_sats"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.PaymentSizeSats = n
+	}
+	return cfg, nil
+}
+
+func sovereigntySummary(onchain score.Result, plan planner.ConsolidationPlan, lnReady *ln.Readiness, lnRecv *ln.ReceiveReadiness, node *score.NodeHealth, conf DataConfidence) string {
 	planPart := "Plan: WAIT"
 	if plan.Recommended {
 		planPart = "Plan: CONSOLIDATE"
@@ -119,15 +389,29 @@ func sovereigntySummary(onchain score.Result, plan planner.ConsolidationPlan, ln
 			lnPart = fmt.Sprintf("LN: NOT READY (%d/100)", lnReady.Score)
 		}
 	}
+	if lnRecv != nil {
+		if lnRecv.Ready {
+			lnPart += fmt.Sprintf(" • LN recv: READY (%d/100)", lnRecv.Score)
+		} else {
+			lnPart += fmt.Sprintf(" • LN recv: NOT READY (%d/100)", lnRecv.Score)
+		}
+	}
+
+	nodePart := "Node: n/a"
+	if node != nil {
+		nodePart = fmt.Sprintf("Node: %d/100", node.Score)
+	}
 
 	return fmt.Sprintf(
-		"Score %d/100 • %d UTXOs (%d dust) • Fee %d sat/vB • %s • %s",
+		"Score %d/100 • %d UTXOs (%d dust) • Fee %d sat/vB • %s • %s • %s • Data: %s",
 		onchain.SovereigntyScore,
 		onchain.NumUTXOs,
 		onchain.DustUTXOs,
 		onchain.FeeRateSatVB,
 		planPart,
 		lnPart,
+		nodePart,
+		conf.Level,
 	)
 }
 
@@ -137,15 +421,23 @@ func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing address", http.StatusBadRequest)
 		return
 	}
+	if !allowedTarget(r.Context(), addr) {
+		http.Error(w, "forbidden: address not allowed for this key", http.StatusForbidden)
+		return
+	}
+	s = s.forAddress(addr)
+	s.cfg.Fresh = r.URL.Query().Get("fresh") == "1"
+	s.allowScan(w)
 
 	network := s.resolveNetworkFromQuery(r)
 
-	utxos, feeRate, mode, err := s.fetchUTXOs(addr, network)
+	fetched, err := FetchUTXOs(r.Context(), s.cfg, addr, network)
 	if err != nil {
 		log.Printf("fetch utxos error: %v", err)
 		http.Error(w, "failed to fetch utxos", http.StatusBadGateway)
 		return
 	}
+	utxos, feeRate, mode := fetched.UTXOs, fetched.FeeRate, fetched.Mode
 
 	onchain := score.Compute(score.Input{
 		Address:      addr,
@@ -173,19 +465,110 @@ func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleLNReady(w http.ResponseWriter, r *http.Request) {
 	if !s.cfg.LNDEnabled {
-		http.Error(w, "lnd not enabled", http.StatusBadRequest)
+		http.Error(w, "lightning not enabled", http.StatusBadRequest)
 		return
 	}
-	ready := s.maybeLNReadiness()
-	if ready == nil {
-		http.Error(w, "lnd not configured or unavailable", http.StatusBadGateway)
+	b := s.lnBackend()
+	if b == nil {
+		http.Error(w, "lightning backend not configured or unavailable", http.StatusBadGateway)
 		return
 	}
+	var ready ln.Readiness
+	err := s.cfg.LNBudget.Run(r.Context(), func(ctx context.Context) (err error) {
+		ready, err = ln.ReadinessFor(ctx, b)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s getinfo error: %v", b.Name(), err)
+		http.Error(w, "lightning backend not configured or unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Probing a private payee's invoice makes LND send a probe payment
+	if invoice := r.URL.Query().Get("invoice"); invoice != "" {
+		var amt uint64
+		if a := r.URL.Query().Get("amount_sats"); a != "" {
+			if amt, err = strconv.ParseUint(a, 10, 64); err != nil {
+				http.Error(w, "bad amount_sats", http.StatusBadRequest)
+				return
+			}
+		}
+		probe, err := ProbePayment(r.Context(), s.cfg.LNBudget, b, invoice, amt)
+		if err != nil {
+			http.Error(w, "invoice probe failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ApplyPaymentProbe(&ready, probe)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(ready)
 }
 
+func (s *Server) handleLNReceive(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.LNDEnabled {
+		http.Error(w, "lightning not enabled", http.StatusBadRequest)
+		return
+	}
+	amt, err := s.receiveAmount(r)
+	if err != nil {
+		http.Error(w, "bad receive_sats", http.StatusBadRequest)
+		return
+	}
+	b := s.lnBackend()
+	if b == nil {
+		http.Error(w, "lightning backend not configured or unavailable", http.StatusBadGateway)
+		return
+	}
+	var info ln.GetInfoResponse
+	err = s.cfg.LNBudget.Run(r.Context(), func(ctx context.Context) (err error) {
+		info, err = b.GetInfo(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s getinfo error: %v", b.Name(), err)
+		http.Error(w, "lightning backend not configured or unavailable", http.StatusBadGateway)
+		return
+	}
+	var channels []ln.Channel
+	err = s.cfg.LNBudget.Run(r.Context(), func(ctx context.Context) (err error) {
+		channels, err = b.ListChannels(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s listchannels error: %v", b.Name(), err)
+		http.Error(w, "lightning backend not configured or unavailable", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ln.ComputeReceiveReadiness(info, channels, amt))
+}
+
+// Report is the full /report response for one address.
+type Report struct {
+	SovereigntySummary string                    `json:"sovereignty_summary"`
+	DataConfidence     DataConfidence            `json:"data_confidence"`
+	ChainTips          btc.TipConsistency        `json:"chain_tips"`
+	NodeHealth         *score.NodeHealth         `json:"node_health,omitempty"`
+	Verification       *ExplorerVerification     `json:"explorer_verification,omitempty"`
+	Reconciliation     *Reconciliation           `json:"reconciliation,omitempty"`
+	Tor                *netx.TorStatus           `json:"tor,omitempty"`
+	Cache              CacheReport               `json:"cache"`
+	Decoys             *DecoyReport              `json:"decoys,omitempty"`
+	PrivacyLedger      netx.PrivacyLedger        `json:"privacy_ledger"`
+	RequestStats       netx.RequestStats         `json:"request_stats"`
+	OnChain            score.Result              `json:"onchain"`
+	Plan               planner.ConsolidationPlan `json:"consolidation_plan"`
+	ExitCosts          ln.ExitCosts              `json:"exit_costs"`
+	LN                 *ln.Readiness             `json:"ln_readiness,omitempty"`
+	LNWallet           *LNWallet                 `json:"ln_wallet,omitempty"`
+	LNRecovery         *ln.Recovery              `json:"ln_recovery,omitempty"`
+	LNReceive          *ln.ReceiveReadiness      `json:"ln_receive_readiness,omitempty"`
+	LNChannelHealth    *ln.ChannelHealthReport   `json:"ln_channel_health,omitempty"`
+	LNVsOnChain        *planner.CostComparison   `json:"ln_vs_onchain,omitempty"`
+}
+
 // NEW: /report combines on-chain + plan + optional LN + a judge-friendly summary
 func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
 	addr := r.URL.Query().Get("address")
@@ -193,15 +576,50 @@ func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing address", http.StatusBadRequest)
 		return
 	}
+	if !allowedTarget(r.Context(), addr) {
+		http.Error(w, "forbidden: address not allowed for this key", http.StatusForbidden)
+		return
+	}
+	s = s.forAddress(addr)
+	s.cfg.Fresh = r.URL.Query().Get("fresh") == "1"
+	s.allowScan(w)
 	network := s.resolveNetworkFromQuery(r)
 
-	utxos, feeRate, mode, err := s.fetchUTXOs(addr, network)
+	recvAmt, err := s.receiveAmount(r)
+	if err != nil {
+		http.Error(w, "bad receive_sats", http.StatusBadRequest)
+		return
+	}
+	profileCfg, err := s.paymentProfile(r)
+	if err != nil {
+		http.Error(w, "bad pay_count or pay_size_sats", http.StatusBadRequest)
+		return
+	}
+
+	// Keys without ln:read get the on-chain report only
+	report, err := s.buildReport(r.Context(), addr, network, recvAmt, profileCfg, canReadLN(r.Context()))
 	if err != nil {
 		log.Printf("fetch utxos error: %v", err)
 		http.Error(w, "failed to fetch utxos", http.StatusBadGateway)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// buildReport assembles the report for addr; s should come from forAddress.
+// profileCfg carries the payment profile for the LN cost comparison. The
+// only error is failing to load the UTXO set.
+func (s *Server) buildReport(ctx context.Context, addr string, network btc.Network, recvAmt uint64, profileCfg Config, withLN bool) (Report, error) {
+	fetched, err := FetchUTXOs(ctx, s.cfg, addr, network)
+	if err != nil {
+		return Report{}, err
+	}
+	utxos, feeRate, mode := fetched.UTXOs, fetched.FeeRate, fetched.Mode
+
+	verification := VerifyExplorerUTXOs(ctx, s.cfg, network, utxos)
+
 	onchain := score.Compute(score.Input{
 		Address:      addr,
 		Network:      network,
@@ -217,22 +635,43 @@ func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
 		FeeLowSatVB: s.cfg.FeeLowSatVB,
 	})
 
-	lnReady := s.maybeLNReadiness()
+	var lnr LNReport
+	if withLN {
+		lnr = s.maybeLN(ctx, network, feeRate, recvAmt, profileCfg)
+	}
 
-	type Report struct {
-		SovereigntySummary string                    `json:"sovereignty_summary"`
-		OnChain            score.Result              `json:"onchain"`
-		Plan               planner.ConsolidationPlan `json:"consolidation_plan"`
-		LN                 *ln.Readiness             `json:"ln_readiness,omitempty"`
+	tips := CheckChainTips(ctx, s.cfg, network, lnr.Readiness)
+	conf := NewDataConfidence()
+	ApplyTipConsistency(&conf, tips)
+	ApplyExplorerVerification(&conf, verification)
+	var reconciliation *Reconciliation
+	if mode == "explorer" {
+		reconciliation = ReconcileUTXOs(ctx, s.cfg, addr, utxos)
 	}
+	ApplyReconciliation(&conf, reconciliation)
+	node := CheckNode(ctx, s.cfg)
 
 	report := Report{
-		SovereigntySummary: sovereigntySummary(onchain, plan, lnReady),
+		SovereigntySummary: sovereigntySummary(onchain, plan, lnr.Readiness, lnr.Receive, node, conf),
+		DataConfidence:     conf,
+		ChainTips:          tips,
+		NodeHealth:         node,
+		Verification:       verification,
+		Reconciliation:     reconciliation,
+		Tor:                s.cfg.Tor,
+		Cache:              CacheReport{UTXOs: fetched.UTXOCache, Fee: fetched.FeeCache, LN: lnr.Cache},
+		Decoys:             fetched.Decoys,
 		OnChain:            onchain,
 		Plan:               plan,
-		LN:                 lnReady,
+		ExitCosts:          ln.NewExitCosts(onchain.EstimatedSweepFee, lnr.ExitPlan),
+		LN:                 lnr.Readiness,
+		LNWallet:           lnr.Wallet,
+		LNRecovery:         lnr.Recovery,
+		LNReceive:          lnr.Receive,
+		LNChannelHealth:    lnr.Health,
+		LNVsOnChain:        CompareCosts(profileCfg, utxos, feeRate, lnr),
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(report)
+	report.PrivacyLedger = s.cfg.Ledger.Report()
+	report.RequestStats = s.cfg.Ledger.Stats()
+	return report, nil
 }
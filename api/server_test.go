@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"sovereign-checker/netx"
+)
+
+// fakeBitcoind answers scantxoutset after scanDelay and estimatesmartfee at once.
+func fakeBitcoind(scanDelay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "scantxoutset":
+			time.Sleep(scanDelay)
+			w.Write([]byte(`{"result":{"success":true,"unspents":[{"txid":"aa","vout":0,"amount":0.001,"height":800000}]},"error":null,"id":"x"}`))
+		case "estimatesmartfee":
+			w.Write([]byte(`{"result":{"feerate":0.00002},"error":null,"id":"x"}`))
+		default:
+			w.Write([]byte(`{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":"x"}`))
+		}
+	}))
+}
+
+func TestSlowScanOutlastsWriteTimeout(t *testing.T) {
+	node := fakeBitcoind(300 * time.Millisecond)
+	defer node.Close()
+
+	const writeTimeout = 100 * time.Millisecond
+	s := NewServer(Config{
+		RPCURL:       node.URL,
+		RPCUser:      "u",
+		RPCPass:      "p",
+		HTTPClient:   node.Client(),
+		ScanBudget:   netx.Budget{Timeout: 5 * time.Second},
+		WriteTimeout: writeTimeout,
+	})
+	srv := httptest.NewUnstartedServer(s.Handler())
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/check?network=mainnet&address=" + url.QueryEscape("wpkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)"))
+	if err != nil {
+		t.Fatalf("slow scan response dropped: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		OnChain struct {
+			NumUTXOs int `json:"num_utxos"`
+		} `json:"onchain"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || out.OnChain.NumUTXOs != 1 {
+		t.Errorf("status %d, utxos %d", resp.StatusCode, out.OnChain.NumUTXOs)
+	}
+}
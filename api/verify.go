@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"sovereign-checker/btc"
+)
+
+const (
+	UTXOVerified     = "verified"
+	UTXOUnverified   = "unverified"
+	UTXOContradicted = "contradicted"
+)
+
+type UTXOCheck struct {
+	TxID   string `json:"txid"`
+	Vout   int    `json:"vout"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExplorerVerification records how explorer-reported UTXOs held up against
+// merkle proofs and the node's best chain.
+type ExplorerVerification struct {
+	HeaderSource string      `json:"header_source,omitempty"` // "bitcoind" or "explorer"
+	Verified     int         `json:"verified"`
+	Unverified   int         `json:"unverified"`
+	Contradicted int         `json:"contradicted"`
+	Skipped      string      `json:"skipped,omitempty"` // why no proofs were fetched
+	Checks       []UTXOCheck `json:"checks"`
+}
+
+// decoySkipReason explains why decoy mode leaves explorer UTXOs unverified.
+const decoySkipReason = "decoy mode: fetching merkle proofs would reveal the real txids the decoys hide"
+
+// VerifyExplorerUTXOs checks each confirmed explorer UTXO's merkle proof
+// against a block header. With bitcoind the header comes from the node's
+// best chain at the proof's height; without it the explorer's own header is
+// only checked for proof-of-work, so the UTXO stays unverified. With decoys
+// no proofs are fetched and every UTXO stays unverified. Each UTXO's
+// Verification field is set in place.
+func VerifyExplorerUTXOs(ctx context.Context, cfg Config, network btc.Network, utxos []btc.UTXO) *ExplorerVerification {
+	if len(utxos) == 0 || utxos[0].Source != "explorer" {
+		return nil
+	}
+	v := &ExplorerVerification{HeaderSource: "explorer", Checks: []UTXOCheck{}}
+	if cfg.Decoys > 0 {
+		v.HeaderSource = ""
+		v.Skipped = decoySkipReason
+		for i := range utxos {
+			utxos[i].Verification = UTXOUnverified
+			v.Checks = append(v.Checks, UTXOCheck{TxID: utxos[i].TxID, Vout: utxos[i].Vout, Status: UTXOUnverified, Reason: decoySkipReason})
+		}
+		v.Unverified = len(utxos)
+		return v
+	}
+	var rpc *btc.BitcoindRPC
+	if cfg.useBitcoind() {
+		rpc = btc.NewBitcoindRPC(cfg.RPCURL, cfg.RPCUser, cfg.RPCPass, cfg.HTTPClient)
+		v.HeaderSource = "bitcoind"
+	}
+
+	blocks := map[int]headerAt{}
+	blockAt := func(height int) headerAt {
+		if b, ok := blocks[height]; ok {
+			return b
+		}
+		var b headerAt
+		if rpc != nil {
+			b.err = cfg.BitcoindBudget.Run(ctx, func(ctx context.Context) (err error) {
+				if b.hash, err = rpc.GetBlockHash(ctx, height); err == nil {
+					b.header, err = rpc.GetBlockHeader(ctx, b.hash)
+				}
+				return err
+			})
+		} else {
+			b.err = cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+				if b.hash, err = btc.FetchBlockHashExplorer(ctx, cfg.HTTPClient, cfg.explorer(network), height); err == nil {
+					b.header, err = btc.FetchBlockHeaderExplorer(ctx, cfg.HTTPClient, cfg.explorer(network), b.hash)
+				}
+				return err
+			})
+		}
+		blocks[height] = b
+		return b
+	}
+
+	for i := range utxos {
+		u := &utxos[i]
+		status, reason := verifyUTXO(ctx, cfg, network, *u, rpc != nil, blockAt)
+		u.Verification = status
+		v.Checks = append(v.Checks, UTXOCheck{TxID: u.TxID, Vout: u.Vout, Status: status, Reason: reason})
+		switch status {
+		case UTXOVerified:
+			v.Verified++
+		case UTXOContradicted:
+			v.Contradicted++
+		default:
+			v.Unverified++
+		}
+	}
+	return v
+}
+
+type headerAt struct {
+	hash   string
+	header []byte
+	err    error
+}
+
+func verifyUTXO(ctx context.Context, cfg Config, network btc.Network, u btc.UTXO, local bool, blockAt func(int) headerAt) (string, string) {
+	if !u.Confirmed {
+		return UTXOUnverified, "unconfirmed; no merkle proof exists yet"
+	}
+	var proof btc.MerkleProof
+	err := cfg.ExplorerBudget.Run(ctx, func(ctx context.Context) (err error) {
+		proof, err = btc.FetchMerkleProofExplorer(ctx, cfg.HTTPClient, cfg.explorer(network), u.TxID)
+		return err
+	})
+	if err != nil {
+		return UTXOUnverified, err.Error()
+	}
+	if u.BlockHeight != 0 && proof.BlockHeight != u.BlockHeight {
+		return UTXOContradicted, fmt.Sprintf("explorer reports height %d but its proof is for height %d", u.BlockHeight, proof.BlockHeight)
+	}
+	b := blockAt(proof.BlockHeight)
+	if b.err != nil {
+		return UTXOUnverified, fmt.Sprintf("no header at height %d: %v", proof.BlockHeight, b.err)
+	}
+	if err := btc.CheckHeader(b.header, b.hash); err != nil {
+		return UTXOContradicted, err.Error()
+	}
+	if err := btc.VerifyMerkleProof(u.TxID, proof, b.header); err != nil {
+		return UTXOContradicted, err.Error()
+	}
+	if !local {
+		return UTXOUnverified, "merkle proof valid but header not checked against a local node"
+	}
+	return UTXOVerified, ""
+}
+
+// ApplyExplorerVerification penalizes data confidence for explorer UTXOs
+// that proofs contradicted or could not confirm.
+func ApplyExplorerVerification(conf *DataConfidence, v *ExplorerVerification) {
+	if v == nil {
+		return
+	}
+	if v.Contradicted > 0 {
+		conf.Penalize(40, fmt.Sprintf("%d explorer UTXO(s) contradicted by merkle proofs or the node's chain.", v.Contradicted))
+	}
+	if v.Unverified > 0 {
+		conf.Penalize(10, fmt.Sprintf("%d explorer UTXO(s) could not be verified.", v.Unverified))
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestVerifyExplorerUTXOsSkipsProofsWithDecoys(t *testing.T) {
+	cfg := Config{
+		Decoys: 3,
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			t.Errorf("decoy mode sent %s %s", r.Method, r.URL)
+			return nil, http.ErrUseLastResponse
+		})},
+	}
+	utxos := []btc.UTXO{
+		{TxID: "aa", Vout: 0, Confirmed: true, BlockHeight: 100, Source: "explorer"},
+		{TxID: "bb", Vout: 1, Source: "explorer"},
+	}
+	v := VerifyExplorerUTXOs(context.Background(), cfg, btc.Mainnet, utxos)
+	if v == nil || v.Skipped == "" || v.Unverified != 2 || len(v.Checks) != 2 {
+		t.Fatalf("verification = %+v", v)
+	}
+	for _, u := range utxos {
+		if u.Verification != UTXOUnverified {
+			t.Errorf("utxo %s verification = %q", u.TxID, u.Verification)
+		}
+	}
+}
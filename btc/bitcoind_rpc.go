@@ -2,11 +2,14 @@ package btc
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"sovereign-checker/netx"
 )
 
 type BitcoindRPC struct {
@@ -18,7 +21,7 @@ type BitcoindRPC struct {
 
 func NewBitcoindRPC(url, user, pass string, client *http.Client) *BitcoindRPC {
 	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+		client = netx.NewLocalClient(10 * time.Second)
 	}
 	return &BitcoindRPC{URL: url, User: user, Password: pass, Client: client}
 }
@@ -39,7 +42,7 @@ type rpcResp struct {
 	ID string `json:"id"`
 }
 
-func (r *BitcoindRPC) call(method string, params ...interface{}) (json.RawMessage, error) {
+func (r *BitcoindRPC) call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
 	body, _ := json.Marshal(rpcReq{
 		Jsonrpc: "1.0",
 		ID:      "sovereign-checker",
@@ -47,7 +50,7 @@ func (r *BitcoindRPC) call(method string, params ...interface{}) (json.RawMessag
 		Params:  params,
 	})
 
-	req, err := http.NewRequest("POST", r.URL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", r.URL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -82,8 +85,8 @@ type ListUnspentItem struct {
 	Solvable      bool    `json:"solvable"`
 }
 
-func (r *BitcoindRPC) ListUnspent(minConf, maxConf int, addresses []string) ([]ListUnspentItem, error) {
-	raw, err := r.call("listunspent", minConf, maxConf, addresses)
+func (r *BitcoindRPC) ListUnspent(ctx context.Context, minConf, maxConf int, addresses []string) ([]ListUnspentItem, error) {
+	raw, err := r.call(ctx, "listunspent", minConf, maxConf, addresses)
 	if err != nil {
 		return nil, err
 	}
@@ -100,8 +103,8 @@ type EstimateSmartFeeResult struct {
 	Errors          []string `json:"errors"`
 }
 
-func (r *BitcoindRPC) EstimateSmartFee(confTarget int) (EstimateSmartFeeResult, error) {
-	raw, err := r.call("estimatesmartfee", confTarget)
+func (r *BitcoindRPC) EstimateSmartFee(ctx context.Context, confTarget int) (EstimateSmartFeeResult, error) {
+	raw, err := r.call(ctx, "estimatesmartfee", confTarget)
 	if err != nil {
 		return EstimateSmartFeeResult{}, err
 	}
@@ -116,8 +119,8 @@ func btcToSats(b float64) uint64 {
 	return uint64(b * 100_000_000.0) // hackathon OK; float caveat
 }
 
-func (r *BitcoindRPC) UTXOsForAddress(address string) ([]UTXO, error) {
-	items, err := r.ListUnspent(0, 9999999, []string{address})
+func (r *BitcoindRPC) UTXOsForAddress(ctx context.Context, address string) ([]UTXO, error) {
+	items, err := r.ListUnspent(ctx, 0, 9999999, []string{address})
 	if err != nil {
 		return nil, err
 	}
@@ -134,3 +137,133 @@ func (r *BitcoindRPC) UTXOsForAddress(address string) ([]UTXO, error) {
 	}
 	return out, nil
 }
+
+type BlockchainInfo struct {
+	Chain                string  `json:"chain"`
+	Blocks               int     `json:"blocks"`
+	Headers              int     `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	Time                 int64   `json:"time"`
+	MedianTime           int64   `json:"mediantime"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+	Pruned               bool    `json:"pruned"`
+	PruneHeight          int     `json:"pruneheight,omitempty"`
+}
+
+func (r *BitcoindRPC) GetBlockchainInfo(ctx context.Context) (BlockchainInfo, error) {
+	raw, err := r.call(ctx, "getblockchaininfo")
+	if err != nil {
+		return BlockchainInfo{}, err
+	}
+	var res BlockchainInfo
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return BlockchainInfo{}, err
+	}
+	return res, nil
+}
+
+func (r *BitcoindRPC) GetBlockHash(ctx context.Context, height int) (string, error) {
+	raw, err := r.call(ctx, "getblockhash", height)
+	if err != nil {
+		return "", err
+	}
+	var hash string
+	err = json.Unmarshal(raw, &hash)
+	return hash, err
+}
+
+type TxOut struct {
+	BestBlock     string  `json:"bestblock"`
+	Confirmations int     `json:"confirmations"`
+	ValueBTC      float64 `json:"value"`
+	Coinbase      bool    `json:"coinbase"`
+}
+
+// GetTxOut returns nil when the output is spent or unknown to the node.
+func (r *BitcoindRPC) GetTxOut(ctx context.Context, txid string, vout int, includeMempool bool) (*TxOut, error) {
+	raw, err := r.call(ctx, "gettxout", txid, vout, includeMempool)
+	if err != nil {
+		return nil, err
+	}
+	if string(raw) == "null" || len(raw) == 0 {
+		return nil, nil
+	}
+	var res TxOut
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type scanTxOutSetResult struct {
+	Success  bool `json:"success"`
+	Height   int  `json:"height"`
+	Unspents []struct {
+		TxID      string  `json:"txid"`
+		Vout      int     `json:"vout"`
+		AmountBTC float64 `json:"amount"`
+		Height    int     `json:"height"`
+	} `json:"unspents"`
+}
+
+// ScanTxOutSet finds the address's confirmed UTXOs in the node's chainstate.
+// Unlike listunspent it does not need the address imported into a wallet.
+func (r *BitcoindRPC) ScanTxOutSet(ctx context.Context, address string) ([]UTXO, error) {
+	return r.ScanDescriptor(ctx, "addr("+address+")")
+}
+
+// bitcoind runs one scantxoutset at a time and rejects concurrent scans, so
+// scans queue on this semaphore.
+var scanSem = make(chan struct{}, 1)
+
+// scanAbortTimeout bounds the abort sent for a scan whose caller gave up.
+const scanAbortTimeout = 5 * time.Second
+
+// ScanDescriptor finds the confirmed UTXOs of an output descriptor (ranged
+// descriptors cover indexes 0-999) in the node's chainstate. Scans take
+// minutes on mainnet, so only ctx bounds them, not the client's timeout.
+// Waiting for another scan ends with ctx, and a scan cancelled by ctx is
+// aborted on the node so it does not keep the chainstate busy.
+func (r *BitcoindRPC) ScanDescriptor(ctx context.Context, desc string) ([]UTXO, error) {
+	select {
+	case scanSem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-scanSem }()
+	scan := *r
+	if r.Client.Timeout > 0 {
+		c := *r.Client
+		c.Timeout = 0
+		scan.Client = &c
+	}
+	raw, err := scan.call(ctx, "scantxoutset", "start", []string{desc})
+	if err != nil {
+		if ctx.Err() != nil {
+			actx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scanAbortTimeout)
+			_, _ = r.call(actx, "scantxoutset", "abort")
+			cancel()
+		}
+		return nil, err
+	}
+	var res scanTxOutSetResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	if !res.Success {
+		return nil, fmt.Errorf("scantxoutset did not complete")
+	}
+	out := make([]UTXO, 0, len(res.Unspents))
+	for _, u := range res.Unspents {
+		out = append(out, UTXO{
+			TxID:        u.TxID,
+			Vout:        u.Vout,
+			ValueSats:   btcToSats(u.AmountBTC),
+			Confirmed:   true,
+			BlockHeight: u.Height,
+			Source:      "bitcoind",
+		})
+	}
+	return out, nil
+}
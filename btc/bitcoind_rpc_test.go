@@ -0,0 +1,98 @@
+package btc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScanNode answers scantxoutset: "start" blocks until aborted or the
+// client goes away, "abort" releases it. It records the actions seen.
+type fakeScanNode struct {
+	mu      sync.Mutex
+	actions []string
+	abort   chan struct{}
+	started chan struct{}
+}
+
+func (f *fakeScanNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "scantxoutset" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	action, _ := req.Params[0].(string)
+	f.mu.Lock()
+	f.actions = append(f.actions, action)
+	f.mu.Unlock()
+	switch action {
+	case "start":
+		f.started <- struct{}{}
+		select {
+		case <-f.abort:
+			w.Write([]byte(`{"result":{"success":false},"error":null,"id":"x"}`))
+		case <-r.Context().Done():
+		}
+	case "abort":
+		close(f.abort)
+		w.Write([]byte(`{"result":true,"error":null,"id":"x"}`))
+	}
+}
+
+func (f *fakeScanNode) seen() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.actions...)
+}
+
+func TestScanDescriptorCancel(t *testing.T) {
+	node := &fakeScanNode{abort: make(chan struct{}), started: make(chan struct{}, 1)}
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+	rpc := NewBitcoindRPC(srv.URL, "u", "p", srv.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := rpc.ScanDescriptor(ctx, "addr(bc1qexample)")
+		done <- err
+	}()
+	<-node.started
+
+	// A second scan queues behind the first and gives up with its context.
+	wctx, wcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer wcancel()
+	if _, err := rpc.ScanDescriptor(wctx, "addr(bc1qother)"); err != context.DeadlineExceeded {
+		t.Errorf("queued scan error = %v, want deadline exceeded", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("cancelled scan returned no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled scan did not return")
+	}
+	if got := node.seen(); len(got) != 2 || got[0] != "start" || got[1] != "abort" {
+		t.Errorf("node saw %v, want [start abort]", got)
+	}
+
+	// The semaphore is free again.
+	sctx, scancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer scancel()
+	select {
+	case scanSem <- struct{}{}:
+		<-scanSem
+	case <-sctx.Done():
+		t.Error("scan semaphore still held after the cancelled scan")
+	}
+}
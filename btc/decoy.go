@@ -0,0 +1,124 @@
+package btc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// RecentAddress is an output address seen in a recent block, with its
+// Esplora script type (v0_p2wpkh, v0_p2wsh, v1_p2tr, p2pkh, p2sh).
+type RecentAddress struct {
+	Address string
+	Type    string
+}
+
+// AddressType guesses an address's Esplora script type from its encoding.
+func AddressType(addr string) string {
+	a := strings.ToLower(addr)
+	switch {
+	case strings.HasPrefix(a, "bc1p"), strings.HasPrefix(a, "tb1p"), strings.HasPrefix(a, "bcrt1p"):
+		return "v1_p2tr"
+	case strings.HasPrefix(a, "bc1q"), strings.HasPrefix(a, "tb1q"), strings.HasPrefix(a, "bcrt1q"):
+		if len(a) > 50 {
+			return "v0_p2wsh"
+		}
+		return "v0_p2wpkh"
+	case strings.HasPrefix(addr, "1"), strings.HasPrefix(addr, "m"), strings.HasPrefix(addr, "n"):
+		return "p2pkh"
+	case strings.HasPrefix(addr, "3"), strings.HasPrefix(addr, "2"):
+		return "p2sh"
+	}
+	return ""
+}
+
+type esploraBlock struct {
+	TxCount int `json:"tx_count"`
+}
+
+type esploraTx struct {
+	Vout []struct {
+		Address string `json:"scriptpubkey_address"`
+		Type    string `json:"scriptpubkey_type"`
+	} `json:"vout"`
+}
+
+func explorerGetJSON(ctx context.Context, client *http.Client, url string, out interface{}) error {
+	resp, err := explorerGet(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("explorer status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// FetchRecentAddressesExplorer samples output addresses from up to pages
+// random 25-transaction pages of the explorer's tip block. Block data is
+// public, so fetching it reveals nothing about the caller.
+func FetchRecentAddressesExplorer(ctx context.Context, client *http.Client, ex Explorer, pages int) ([]RecentAddress, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := explorerGetText(ctx, client, base+"/blocks/tip/hash")
+	if err != nil {
+		return nil, fmt.Errorf("fetch tip hash (explorer): %w", err)
+	}
+	var blk esploraBlock
+	if err := explorerGetJSON(ctx, client, base+"/block/"+hash, &blk); err != nil {
+		return nil, fmt.Errorf("fetch block (explorer): %w", err)
+	}
+
+	npages := (blk.TxCount + 24) / 25
+	seen := map[string]bool{}
+	var out []RecentAddress
+	for _, p := range rand.Perm(npages) {
+		if pages <= 0 {
+			break
+		}
+		pages--
+		var txs []esploraTx
+		if err := explorerGetJSON(ctx, client, fmt.Sprintf("%s/block/%s/txs/%d", base, hash, p*25), &txs); err != nil {
+			return nil, fmt.Errorf("fetch block txs (explorer): %w", err)
+		}
+		for _, tx := range txs {
+			for _, v := range tx.Vout {
+				if v.Address != "" && !seen[v.Address] {
+					seen[v.Address] = true
+					out = append(out, RecentAddress{Address: v.Address, Type: v.Type})
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// PickDecoys chooses k addresses from pool, preferring ones of the same
+// script type as real so the real query does not stand out.
+func PickDecoys(pool []RecentAddress, real string, k int) []string {
+	typ := AddressType(real)
+	var same, other []string
+	for _, a := range pool {
+		if a.Address == real {
+			continue
+		}
+		if a.Type == typ {
+			same = append(same, a.Address)
+		} else {
+			other = append(other, a.Address)
+		}
+	}
+	rand.Shuffle(len(same), func(i, j int) { same[i], same[j] = same[j], same[i] })
+	rand.Shuffle(len(other), func(i, j int) { other[i], other[j] = other[j], other[i] })
+	out := append(same, other...)
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
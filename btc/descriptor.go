@@ -0,0 +1,43 @@
+package btc
+
+import "strings"
+
+// IsDescriptor reports whether s is an output descriptor such as
+// wpkh([fp/84h/0h/0h]xpub.../0/*) rather than a bare address.
+func IsDescriptor(s string) bool {
+	return strings.Contains(s, "(")
+}
+
+// DescriptorAddress returns the address inside an addr(...) descriptor,
+// with any checksum suffix removed.
+func DescriptorAddress(desc string) (string, bool) {
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		desc = desc[:i]
+	}
+	desc = strings.TrimSpace(desc)
+	if !strings.HasPrefix(desc, "addr(") || !strings.HasSuffix(desc, ")") {
+		return "", false
+	}
+	return desc[len("addr(") : len(desc)-1], true
+}
+
+// DescriptorType returns the Esplora script type of a single-key or
+// multisig descriptor's outputs, or "" when it cannot tell.
+func DescriptorType(desc string) string {
+	if a, ok := DescriptorAddress(desc); ok {
+		return AddressType(a)
+	}
+	switch d := strings.TrimSpace(desc); {
+	case strings.HasPrefix(d, "tr("):
+		return "v1_p2tr"
+	case strings.HasPrefix(d, "wpkh("):
+		return "v0_p2wpkh"
+	case strings.HasPrefix(d, "wsh("):
+		return "v0_p2wsh"
+	case strings.HasPrefix(d, "sh("):
+		return "p2sh"
+	case strings.HasPrefix(d, "pkh("):
+		return "p2pkh"
+	}
+	return ""
+}
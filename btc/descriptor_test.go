@@ -0,0 +1,19 @@
+package btc
+
+import "testing"
+
+func TestDescriptorType(t *testing.T) {
+	for _, tt := range []struct{ desc, want string }{
+		{"wpkh([d34db33f/84h/0h/0h]xpub6Cat/0/*)#checksum", "v0_p2wpkh"},
+		{" tr(xpub6Cat/0/*)", "v1_p2tr"},
+		{"wsh(sortedmulti(2,xpubA/0/*,xpubB/0/*))", "v0_p2wsh"},
+		{"sh(wpkh(xpub6Cat/0/*))", "p2sh"},
+		{"pkh(xpub6Cat/0/*)", "p2pkh"},
+		{"addr(bc1pmzfrwwndsqmk5yh69yjr5lfgfg4ev8c0tsc06e)", "v1_p2tr"},
+		{"combo(xpub6Cat/0/*)", ""},
+	} {
+		if got := DescriptorType(tt.desc); got != tt.want {
+			t.Errorf("DescriptorType(%q) = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+// MerkleProof is Esplora's /tx/:txid/merkle-proof response. Hashes are in
+// display (reversed) byte order.
+type MerkleProof struct {
+	BlockHeight int      `json:"block_height"`
+	Merkle      []string `json:"merkle"`
+	Pos         int      `json:"pos"`
+}
+
+func FetchMerkleProofExplorer(ctx context.Context, client *http.Client, ex Explorer, txid string) (MerkleProof, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return MerkleProof{}, err
+	}
+	resp, err := explorerGet(ctx, client, fmt.Sprintf("%s/tx/%s/merkle-proof", base, txid))
+	if err != nil {
+		return MerkleProof{}, fmt.Errorf("fetch merkle proof (explorer): %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return MerkleProof{}, fmt.Errorf("explorer status %d for merkle proof", resp.StatusCode)
+	}
+	var p MerkleProof
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return MerkleProof{}, fmt.Errorf("decode merkle proof: %w", err)
+	}
+	return p, nil
+}
+
+func FetchBlockHeaderExplorer(ctx context.Context, client *http.Client, ex Explorer, blockHash string) ([]byte, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	s, err := explorerGetText(ctx, client, fmt.Sprintf("%s/block/%s/header", base, blockHash))
+	if err != nil {
+		return nil, fmt.Errorf("fetch block header (explorer): %w", err)
+	}
+	return hex.DecodeString(s)
+}
+
+// GetBlockHeader returns the raw 80-byte header for blockHash.
+func (r *BitcoindRPC) GetBlockHeader(ctx context.Context, blockHash string) ([]byte, error) {
+	raw, err := r.call(ctx, "getblockheader", blockHash, false)
+	if err != nil {
+		return nil, err
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(s)
+}
+
+func dsha256(b []byte) []byte {
+	h := sha256.Sum256(b)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
+
+func reversed(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+// decodeDisplayHash decodes a display-order hex hash into internal byte order.
+func decodeDisplayHash(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("bad hash %q", s)
+	}
+	return reversed(b), nil
+}
+
+// CheckHeader verifies that header hashes to blockHash and meets its own
+// proof-of-work target.
+func CheckHeader(header []byte, blockHash string) error {
+	if len(header) != 80 {
+		return errors.New("header must be 80 bytes")
+	}
+	h := dsha256(header)
+	if hex.EncodeToString(reversed(h)) != blockHash {
+		return errors.New("header does not hash to the block hash")
+	}
+	bits := uint32(header[72]) | uint32(header[73])<<8 | uint32(header[74])<<16 | uint32(header[75])<<24
+	exp := uint(bits >> 24)
+	target := big.NewInt(int64(bits & 0x007fffff))
+	if exp <= 3 {
+		target.Rsh(target, 8*(3-exp))
+	} else {
+		target.Lsh(target, 8*(exp-3))
+	}
+	if new(big.Int).SetBytes(reversed(h)).Cmp(target) > 0 {
+		return errors.New("header does not meet its proof-of-work target")
+	}
+	return nil
+}
+
+// VerifyMerkleProof checks that txid is committed to by header's merkle root
+// through the proof's path.
+func VerifyMerkleProof(txid string, p MerkleProof, header []byte) error {
+	if len(header) != 80 {
+		return errors.New("header must be 80 bytes")
+	}
+	h, err := decodeDisplayHash(txid)
+	if err != nil {
+		return err
+	}
+	pos := p.Pos
+	for _, s := range p.Merkle {
+		sib, err := decodeDisplayHash(s)
+		if err != nil {
+			return err
+		}
+		if pos&1 == 1 {
+			h = dsha256(append(sib, h...))
+		} else {
+			h = dsha256(append(h, sib...))
+		}
+		pos >>= 1
+	}
+	if !bytes.Equal(h, header[36:68]) {
+		return errors.New("merkle path does not lead to the header's merkle root")
+	}
+	return nil
+}
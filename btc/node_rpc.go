@@ -0,0 +1,112 @@
+package btc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type NetworkInfo struct {
+	Version          int     `json:"version"`
+	Subversion       string  `json:"subversion"`
+	Connections      int     `json:"connections"`
+	ConnectionsIn    int     `json:"connections_in"`
+	ConnectionsOut   int     `json:"connections_out"`
+	RelayFeeBTCPerKB float64 `json:"relayfee"`
+	IncrementalFee   float64 `json:"incrementalfee"`
+	Networks         []struct {
+		Name      string `json:"name"` // ipv4, ipv6, onion, i2p, cjdns
+		Reachable bool   `json:"reachable"`
+		Proxy     string `json:"proxy"`
+	} `json:"networks"`
+	LocalAddresses []struct {
+		Address string `json:"address"`
+		Port    int    `json:"port"`
+	} `json:"localaddresses"`
+	Warnings json.RawMessage `json:"warnings"` // string before v28, array after
+}
+
+type PeerInfo struct {
+	Inbound        bool   `json:"inbound"`
+	Network        string `json:"network"`
+	ConnectionType string `json:"connection_type"`
+	Subver         string `json:"subver"`
+}
+
+type MempoolInfo struct {
+	Loaded                 bool    `json:"loaded"`
+	Size                   int     `json:"size"`
+	Bytes                  int     `json:"bytes"`
+	MempoolMinFeeBTCPerKB  float64 `json:"mempoolminfee"`
+	MinRelayTxFeeBTCPerKB  float64 `json:"minrelaytxfee"`
+	IncrementalRelayFeeBTC float64 `json:"incrementalrelayfee"`
+	FullRBF                *bool   `json:"fullrbf"` // absent before v24
+}
+
+type IndexInfo struct {
+	Synced          bool `json:"synced"`
+	BestBlockHeight int  `json:"best_block_height"`
+}
+
+// NodeInfo bundles the RPCs the node health report is built from.
+type NodeInfo struct {
+	Blockchain BlockchainInfo
+	Network    NetworkInfo
+	Peers      []PeerInfo
+	Mempool    MempoolInfo
+	Indexes    map[string]IndexInfo // keyed by name, e.g. "txindex"
+}
+
+func (r *BitcoindRPC) callInto(ctx context.Context, out interface{}, method string, params ...interface{}) error {
+	raw, err := r.call(ctx, method, params...)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func (r *BitcoindRPC) GetNetworkInfo(ctx context.Context) (NetworkInfo, error) {
+	var res NetworkInfo
+	err := r.callInto(ctx, &res, "getnetworkinfo")
+	return res, err
+}
+
+func (r *BitcoindRPC) GetPeerInfo(ctx context.Context) ([]PeerInfo, error) {
+	var res []PeerInfo
+	err := r.callInto(ctx, &res, "getpeerinfo")
+	return res, err
+}
+
+func (r *BitcoindRPC) GetMempoolInfo(ctx context.Context) (MempoolInfo, error) {
+	var res MempoolInfo
+	err := r.callInto(ctx, &res, "getmempoolinfo")
+	return res, err
+}
+
+func (r *BitcoindRPC) GetIndexInfo(ctx context.Context) (map[string]IndexInfo, error) {
+	res := map[string]IndexInfo{}
+	err := r.callInto(ctx, &res, "getindexinfo")
+	return res, err
+}
+
+// FetchNodeInfo gathers everything for the node report. getindexinfo is
+// optional (v0.21+); a failure there leaves Indexes empty.
+func (r *BitcoindRPC) FetchNodeInfo(ctx context.Context) (NodeInfo, error) {
+	var ni NodeInfo
+	var err error
+	if ni.Blockchain, err = r.GetBlockchainInfo(ctx); err != nil {
+		return NodeInfo{}, err
+	}
+	if ni.Network, err = r.GetNetworkInfo(ctx); err != nil {
+		return NodeInfo{}, err
+	}
+	if ni.Peers, err = r.GetPeerInfo(ctx); err != nil {
+		return NodeInfo{}, err
+	}
+	if ni.Mempool, err = r.GetMempoolInfo(ctx); err != nil {
+		return NodeInfo{}, err
+	}
+	if ni.Indexes, err = r.GetIndexInfo(ctx); err != nil {
+		ni.Indexes = map[string]IndexInfo{}
+	}
+	return ni, nil
+}
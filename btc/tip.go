@@ -0,0 +1,179 @@
+package btc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChainTip is one source's view of the best block.
+type ChainTip struct {
+	Source  string `json:"source"` // "bitcoind", "explorer", "lnd", "cln"
+	Height  int    `json:"height"`
+	Hash    string `json:"hash,omitempty"`
+	Headers int    `json:"headers,omitempty"` // bitcoind only
+	Error   string `json:"error,omitempty"`
+}
+
+func explorerGetText(ctx context.Context, client *http.Client, url string) (string, error) {
+	resp, err := explorerGet(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("explorer status %d: %s", resp.StatusCode, string(body))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func FetchTipHashExplorer(ctx context.Context, client *http.Client, ex Explorer) (string, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return "", err
+	}
+	hash, err := explorerGetText(ctx, client, base+"/blocks/tip/hash")
+	if err != nil {
+		return "", fmt.Errorf("fetch tip hash (explorer): %w", err)
+	}
+	return hash, nil
+}
+
+// FetchTipExplorer reads the explorer's best block height, then the hash at
+// that height, so a block arriving between the two calls cannot pair hash
+// N+1 with height N.
+func FetchTipExplorer(ctx context.Context, client *http.Client, ex Explorer) (ChainTip, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return ChainTip{}, err
+	}
+	hs, err := explorerGetText(ctx, client, base+"/blocks/tip/height")
+	if err != nil {
+		return ChainTip{}, fmt.Errorf("fetch tip height (explorer): %w", err)
+	}
+	height, err := strconv.Atoi(hs)
+	if err != nil {
+		return ChainTip{}, fmt.Errorf("bad tip height %q", hs)
+	}
+	hash, err := FetchBlockHashExplorer(ctx, client, ex, height)
+	if err != nil {
+		return ChainTip{}, fmt.Errorf("fetch tip hash (explorer): %w", err)
+	}
+	return ChainTip{Source: "explorer", Height: height, Hash: hash}, nil
+}
+
+func FetchBlockHashExplorer(ctx context.Context, client *http.Client, ex Explorer, height int) (string, error) {
+	base, err := ex.baseURL()
+	if err != nil {
+		return "", err
+	}
+	return explorerGetText(ctx, client, fmt.Sprintf("%s/block-height/%d", base, height))
+}
+
+type TipConsistency struct {
+	Tips       []ChainTip     `json:"tips"`
+	BestHeight int            `json:"best_height"`
+	LagBlocks  map[string]int `json:"lag_blocks"`
+	Consistent bool           `json:"consistent"`
+	Split      bool           `json:"split"`
+	// Penalty to apply to data confidence; 0 when sources agree.
+	ConfidencePenalty int      `json:"confidence_penalty"`
+	Warnings          []string `json:"warnings"`
+	Notes             []string `json:"notes"`
+}
+
+// Sources more than this many blocks behind the best tip are flagged;
+// one block of lag is normal propagation delay.
+const maxTipLag = 1
+
+// CompareTips cross-checks tips from several sources. hashAt optionally maps a
+// source to a lookup of its block hash at a given height, used to tell a lagging
+// source (same chain) from a split (different chain).
+func CompareTips(tips []ChainTip, hashAt map[string]func(height int) (string, error)) TipConsistency {
+	c := TipConsistency{
+		Tips:       tips,
+		LagBlocks:  map[string]int{},
+		Consistent: true,
+		Warnings:   []string{},
+		Notes:      []string{},
+	}
+
+	var valid []ChainTip
+	for _, t := range tips {
+		if t.Error != "" {
+			c.Notes = append(c.Notes, t.Source+" tip unavailable: "+t.Error)
+			continue
+		}
+		valid = append(valid, t)
+		if t.Height > c.BestHeight {
+			c.BestHeight = t.Height
+		}
+	}
+	if len(valid) < 2 {
+		c.Notes = append(c.Notes, "Fewer than two chain sources; tips were not cross-checked.")
+		return c
+	}
+
+	ref := c.BestHeight
+	for _, t := range valid {
+		if t.Height < ref {
+			ref = t.Height
+		}
+	}
+
+	for _, t := range valid {
+		lag := c.BestHeight - t.Height
+		c.LagBlocks[t.Source] = lag
+		if lag > maxTipLag {
+			c.Consistent = false
+			if t.Source == "explorer" {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("Explorer is stale: %d blocks behind the best tip.", lag))
+			} else {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("%s is %d blocks behind the best tip.", t.Source, lag))
+			}
+			c.ConfidencePenalty += 10
+		}
+		if t.Headers > t.Height+maxTipLag {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s is still syncing: %d headers vs %d blocks.", t.Source, t.Headers, t.Height))
+		}
+	}
+
+	// Compare block hashes at the lowest common height.
+	bySource := map[string][]string{}
+	for _, t := range valid {
+		hash := ""
+		if t.Height == ref {
+			hash = t.Hash
+		} else if f := hashAt[t.Source]; f != nil {
+			h, err := f(ref)
+			if err != nil {
+				c.Notes = append(c.Notes, fmt.Sprintf("%s block hash at %d unavailable: %v", t.Source, ref, err))
+			}
+			hash = h
+		}
+		if hash != "" {
+			bySource[hash] = append(bySource[hash], t.Source)
+		}
+	}
+	if len(bySource) > 1 {
+		c.Consistent = false
+		c.Split = true
+		parts := make([]string, 0, len(bySource))
+		for hash, srcs := range bySource {
+			parts = append(parts, strings.Join(srcs, "+")+"="+hash)
+		}
+		sort.Strings(parts)
+		c.Warnings = append(c.Warnings, fmt.Sprintf("Chain split at height %d: %s.", ref, strings.Join(parts, ", ")))
+		c.ConfidencePenalty += 30
+	}
+
+	return c
+}
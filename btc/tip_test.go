@@ -0,0 +1,48 @@
+package btc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// advancingExplorer mines a block after every tip request.
+type advancingExplorer struct{ height int }
+
+func (e *advancingExplorer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body string
+	switch p := req.URL.Path; {
+	case strings.HasSuffix(p, "/blocks/tip/height"):
+		body = fmt.Sprint(e.height)
+		e.height++
+	case strings.HasSuffix(p, "/blocks/tip/hash"):
+		body = fmt.Sprintf("hash%d", e.height)
+		e.height++
+	case strings.Contains(p, "/block-height/"):
+		body = "hash" + p[strings.LastIndex(p, "/")+1:]
+	default:
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestFetchTipExplorerConsistentPair(t *testing.T) {
+	client := &http.Client{Transport: &advancingExplorer{height: 100}}
+	tip, err := FetchTipExplorer(context.Background(), client, Explorer{Network: Mainnet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Height != 100 || tip.Hash != "hash100" {
+		t.Errorf("tip = %d/%s, want 100/hash100", tip.Height, tip.Hash)
+	}
+
+	// The pair cross-checks cleanly against a node one block ahead.
+	c := CompareTips([]ChainTip{tip, {Source: "bitcoind", Height: 101, Hash: "hash101"}},
+		map[string]func(int) (string, error){"bitcoind": func(h int) (string, error) { return fmt.Sprintf("hash%d", h), nil }})
+	if c.Split || c.ConfidencePenalty != 0 {
+		t.Errorf("consistent tips reported as %+v", c)
+	}
+}
@@ -1,6 +1,7 @@
 package btc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -31,27 +32,64 @@ type UTXO struct {
 	Confirmed   bool   `json:"confirmed"`
 	BlockHeight int    `json:"block_height"`
 	Source      string `json:"source"` // "explorer" or "bitcoind"
+	// Set for explorer UTXOs: "verified", "unverified" or "contradicted"
+	Verification string `json:"verification,omitempty"`
 }
 
-func explorerBaseURL(network Network) (string, error) {
-	switch network {
+const (
+	blockstreamHost  = "blockstream.info"
+	blockstreamOnion = "explorerzydxu5ecjrkwceayqybizmpjjznk5izmitf2modhcusuqlid.onion"
+	mempoolHost      = "mempool.space"
+	mempoolOnion     = "mempoolhqx4isw62xs7abwphsq7ldayuidyx2v2oethdhhj6mlo2r6ad.onion"
+)
+
+// ExplorerFallbacks maps each explorer host to an Esplora-compatible mirror
+// serving the same paths, for use when the first host keeps failing.
+func ExplorerFallbacks() map[string]string {
+	return map[string]string{
+		blockstreamHost:  mempoolHost,
+		blockstreamOnion: mempoolOnion,
+	}
+}
+
+// Explorer is the Esplora endpoint for one network: Blockstream's clearnet
+// host, or its onion service when traffic goes through Tor.
+type Explorer struct {
+	Network Network
+	Onion   bool
+}
+
+func (e Explorer) baseURL() (string, error) {
+	host := "https://" + blockstreamHost
+	if e.Onion {
+		host = "http://" + blockstreamOnion
+	}
+	switch e.Network {
 	case Mainnet:
-		return "https://blockstream.info/api", nil
+		return host + "/api", nil
 	case Testnet:
-		return "https://blockstream.info/testnet/api", nil
+		return host + "/testnet/api", nil
 	default:
-		return "", fmt.Errorf("unsupported network: %s", network)
+		return "", fmt.Errorf("unsupported network: %s", e.Network)
+	}
+}
+
+func explorerGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
+	return client.Do(req)
 }
 
-func FetchUTXOsExplorer(client *http.Client, address string, network Network) ([]UTXO, error) {
-	base, err := explorerBaseURL(network)
+func FetchUTXOsExplorer(ctx context.Context, client *http.Client, address string, ex Explorer) ([]UTXO, error) {
+	base, err := ex.baseURL()
 	if err != nil {
 		return nil, err
 	}
 
 	url := fmt.Sprintf("%s/address/%s/utxo", base, address)
-	resp, err := client.Get(url)
+	resp, err := explorerGet(ctx, client, url)
 	if err != nil {
 		return nil, fmt.Errorf("fetch utxos (explorer): %w", err)
 	}
@@ -0,0 +1,39 @@
+package btc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type recordURL struct{ urls []string }
+
+func (r *recordURL) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.urls = append(r.urls, req.URL.String())
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`[]`)), Request: req}, nil
+}
+
+func TestExplorerEndpoint(t *testing.T) {
+	tests := []struct {
+		ex   Explorer
+		want string
+	}{
+		{Explorer{Network: Mainnet}, "https://blockstream.info/api/address/addr/utxo"},
+		{Explorer{Network: Testnet}, "https://blockstream.info/testnet/api/address/addr/utxo"},
+		{Explorer{Network: Mainnet, Onion: true}, "http://" + blockstreamOnion + "/api/address/addr/utxo"},
+	}
+	for _, tt := range tests {
+		rec := &recordURL{}
+		if _, err := FetchUTXOsExplorer(context.Background(), &http.Client{Transport: rec}, "addr", tt.ex); err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.urls) != 1 || rec.urls[0] != tt.want {
+			t.Errorf("%+v requested %v, want %s", tt.ex, rec.urls, tt.want)
+		}
+	}
+	if _, err := FetchUTXOsExplorer(context.Background(), &http.Client{Transport: &recordURL{}}, "addr", Explorer{Network: "regtest"}); err == nil {
+		t.Error("unsupported network accepted")
+	}
+}
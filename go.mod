@@ -2,4 +2,7 @@ module sovereign-checker
 
 go 1.24.0
 
-require golang.org/x/net v0.44.0
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.0
+	golang.org/x/net v0.44.0
+)
@@ -0,0 +1,74 @@
+package ln
+
+import (
+	"context"
+
+	"sovereign-checker/btc"
+)
+
+// Backend is a Lightning node implementation the readiness checks can run against.
+type Backend interface {
+	Name() string
+	GetInfo(ctx context.Context) (GetInfoResponse, error)
+	ListChannels(ctx context.Context) ([]Channel, error)
+	WalletBalance(ctx context.Context) (WalletBalance, error)
+	ListUnspent(ctx context.Context) ([]btc.UTXO, error)
+	// SecurityWarnings reports problems with how the backend is reached or
+	// authenticated, independent of node state.
+	SecurityWarnings() []Warning
+	// Credential describes what the configured credential can do, if known.
+	Credential() *Credential
+}
+
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+type Warning struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+var (
+	_ Backend = (*LNDClient)(nil)
+	_ Backend = (*CLNClient)(nil)
+)
+
+// ReadinessFor fetches node info from b and scores it.
+func ReadinessFor(ctx context.Context, b Backend) (Readiness, error) {
+	info, err := b.GetInfo(ctx)
+	if err != nil {
+		return Readiness{}, err
+	}
+	ready := ComputeReadiness(info)
+	ready.Backend = b.Name()
+	ready.Credential = b.Credential()
+	for _, w := range b.SecurityWarnings() {
+		ready.Warn(w)
+	}
+	return ready, nil
+}
+
+func tlsWarnings(name string, t TLSConfig) []Warning {
+	switch {
+	case t.CustomClient:
+		return []Warning{{
+			Severity: SeverityMedium,
+			Message:  name + " TLS is unverified (custom client): the injected HTTP client's certificate checks are unknown.",
+		}}
+	case t.Verified():
+		return nil
+	case t.Insecure:
+		return []Warning{{
+			Severity: SeverityHigh,
+			Message:  name + " TLS verification is disabled; credentials are exposed to anyone who can intercept the connection. Pass the node's tls.cert or a fingerprint pin.",
+		}}
+	default:
+		return []Warning{{
+			Severity: SeverityMedium,
+			Message:  name + " TLS certificate is not pinned; relying on system roots.",
+		}}
+	}
+}
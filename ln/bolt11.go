@@ -0,0 +1,289 @@
+package ln
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+type RouteHintHop struct {
+	NodeID          string `json:"node_id"`
+	ShortChannelID  uint64 `json:"short_channel_id"`
+	FeeBaseMsat     uint32 `json:"fee_base_msat"`
+	FeeProportional uint32 `json:"fee_proportional_millionths"`
+	CLTVExpiryDelta uint16 `json:"cltv_expiry_delta"`
+}
+
+// Invoice is a decoded BOLT11 payment request.
+type Invoice struct {
+	Network         string           `json:"network"`
+	AmountMsat      uint64           `json:"amount_msat"` // 0 for "any amount" invoices
+	Timestamp       time.Time        `json:"timestamp"`
+	ExpirySeconds   uint64           `json:"expiry_seconds"`
+	PaymentHash     string           `json:"payment_hash"`
+	PaymentSecret   string           `json:"payment_secret,omitempty"`
+	Description     string           `json:"description,omitempty"`
+	DescriptionHash string           `json:"description_hash,omitempty"`
+	Payee           string           `json:"payee"`
+	MinFinalCLTV    uint64           `json:"min_final_cltv_expiry"`
+	RouteHints      [][]RouteHintHop `json:"route_hints,omitempty"`
+}
+
+func (inv Invoice) ExpiresAt() time.Time {
+	return inv.Timestamp.Add(time.Duration(inv.ExpirySeconds) * time.Second)
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// bech32Decode decodes s without the 90-character limit BOLT11 ignores and
+// returns the HRP and the 5-bit data groups without the checksum.
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("bech32: mixed case")
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("bech32: bad separator position")
+	}
+	hrp := s[:pos]
+	data := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return "", nil, fmt.Errorf("bech32: invalid character %q", c)
+		}
+		data = append(data, byte(i))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), data...)) != 1 {
+		return "", nil, errors.New("bech32: bad checksum")
+	}
+	return hrp, data[:len(data)-6], nil
+}
+
+// convertBits regroups fromBits-wide values into toBits-wide values.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) []byte {
+	var acc uint32
+	var bits uint
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	maxv := uint32(1)<<toBits - 1
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad && bits > 0 {
+		out = append(out, byte(acc<<(toBits-bits)&maxv))
+	}
+	return out
+}
+
+func groupsToUint(g []byte) uint64 {
+	var v uint64
+	for _, b := range g {
+		v = v<<5 | uint64(b)
+	}
+	return v
+}
+
+func parseInvoiceHRP(hrp string) (network string, amountMsat uint64, err error) {
+	if !strings.HasPrefix(hrp, "ln") {
+		return "", 0, errors.New("bolt11: hrp must start with ln")
+	}
+	rest := hrp[2:]
+	for _, pfx := range []struct{ p, net string }{
+		{"bcrt", "regtest"}, {"tbs", "signet"}, {"bc", "mainnet"}, {"tb", "testnet"}, {"sb", "simnet"},
+	} {
+		if strings.HasPrefix(rest, pfx.p) {
+			network, rest = pfx.net, rest[len(pfx.p):]
+			break
+		}
+	}
+	if network == "" {
+		return "", 0, fmt.Errorf("bolt11: unknown currency prefix in %q", hrp)
+	}
+	if rest == "" {
+		return network, 0, nil
+	}
+
+	mult := rest[len(rest)-1]
+	digits := rest
+	if mult >= 'a' && mult <= 'z' {
+		digits = rest[:len(rest)-1]
+	} else {
+		mult = 0
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("bolt11: bad amount %q", rest)
+	}
+	var perUnit uint64
+	switch mult {
+	case 0:
+		perUnit = 100_000_000_000
+	case 'm':
+		perUnit = 100_000_000
+	case 'u':
+		perUnit = 100_000
+	case 'n':
+		perUnit = 100
+	case 'p':
+		if n%10 != 0 {
+			return "", 0, errors.New("bolt11: sub-millisatoshi amount")
+		}
+		return network, n / 10, nil
+	default:
+		return "", 0, fmt.Errorf("bolt11: unknown multiplier %q", mult)
+	}
+	// Amounts end up in int64 msat fields, so reject anything larger
+	if n > math.MaxInt64/perUnit {
+		return "", 0, fmt.Errorf("bolt11: amount %q out of range", rest)
+	}
+	amountMsat = n * perUnit
+	return network, amountMsat, nil
+}
+
+// DecodeInvoice decodes a BOLT11 payment request and recovers (or checks) the
+// payee key from its signature.
+func DecodeInvoice(payReq string) (Invoice, error) {
+	payReq = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(payReq), "lightning:"), "LIGHTNING:")
+	hrp, data, err := bech32Decode(payReq)
+	if err != nil {
+		return Invoice{}, err
+	}
+	// 7 groups of timestamp + 104 groups of signature at minimum.
+	if len(data) < 7+104 {
+		return Invoice{}, errors.New("bolt11: too short")
+	}
+
+	var inv Invoice
+	if inv.Network, inv.AmountMsat, err = parseInvoiceHRP(hrp); err != nil {
+		return Invoice{}, err
+	}
+	inv.Timestamp = time.Unix(int64(groupsToUint(data[:7])), 0).UTC()
+	inv.ExpirySeconds = 3600
+	inv.MinFinalCLTV = 18
+
+	sigGroups := data[len(data)-104:]
+	fields := data[7 : len(data)-104]
+	for len(fields) >= 3 {
+		tag := fields[0]
+		l := int(fields[1])<<5 | int(fields[2])
+		if len(fields) < 3+l {
+			return Invoice{}, errors.New("bolt11: truncated tagged field")
+		}
+		v := fields[3 : 3+l]
+		fields = fields[3+l:]
+
+		switch bech32Charset[tag] {
+		case 'p':
+			if l == 52 {
+				inv.PaymentHash = hex.EncodeToString(convertBits(v, 5, 8, false))
+			}
+		case 's':
+			if l == 52 {
+				inv.PaymentSecret = hex.EncodeToString(convertBits(v, 5, 8, false))
+			}
+		case 'd':
+			inv.Description = string(convertBits(v, 5, 8, false))
+		case 'h':
+			if l == 52 {
+				inv.DescriptionHash = hex.EncodeToString(convertBits(v, 5, 8, false))
+			}
+		case 'n':
+			if l == 53 {
+				inv.Payee = hex.EncodeToString(convertBits(v, 5, 8, false))
+			}
+		case 'x':
+			inv.ExpirySeconds = groupsToUint(v)
+		case 'c':
+			inv.MinFinalCLTV = groupsToUint(v)
+		case 'r':
+			inv.RouteHints = append(inv.RouteHints, decodeRouteHint(convertBits(v, 5, 8, false)))
+		}
+	}
+	if inv.PaymentHash == "" {
+		return Invoice{}, errors.New("bolt11: missing payment hash")
+	}
+
+	sig := convertBits(sigGroups, 5, 8, false)
+	if len(sig) != 65 {
+		return Invoice{}, errors.New("bolt11: bad signature length")
+	}
+	msg := append([]byte(hrp), convertBits(data[:len(data)-104], 5, 8, true)...)
+	hash := sha256.Sum256(msg)
+	if sig[64] > 3 {
+		return Invoice{}, errors.New("bolt11: bad recovery id")
+	}
+	// RecoverCompact takes the recovery code first: 27 + recid, +4 for a
+	// compressed key.
+	compact := append([]byte{27 + 4 + sig[64]}, sig[:64]...)
+	pub, _, err := ecdsa.RecoverCompact(compact, hash[:])
+	if err != nil {
+		return Invoice{}, fmt.Errorf("bolt11: %w", err)
+	}
+	recovered := hex.EncodeToString(pub.SerializeCompressed())
+	if inv.Payee != "" && inv.Payee != recovered {
+		return Invoice{}, errors.New("bolt11: signature does not match payee")
+	}
+	inv.Payee = recovered
+
+	return inv, nil
+}
+
+func decodeRouteHint(b []byte) []RouteHintHop {
+	var hops []RouteHintHop
+	for len(b) >= 51 {
+		h := b[:51]
+		b = b[51:]
+		var scid uint64
+		for _, x := range h[33:41] {
+			scid = scid<<8 | uint64(x)
+		}
+		hops = append(hops, RouteHintHop{
+			NodeID:          hex.EncodeToString(h[:33]),
+			ShortChannelID:  scid,
+			FeeBaseMsat:     uint32(h[41])<<24 | uint32(h[42])<<16 | uint32(h[43])<<8 | uint32(h[44]),
+			FeeProportional: uint32(h[45])<<24 | uint32(h[46])<<16 | uint32(h[47])<<8 | uint32(h[48]),
+			CLTVExpiryDelta: uint16(h[49])<<8 | uint16(h[50]),
+		})
+	}
+	return hops
+}
@@ -0,0 +1,65 @@
+package ln
+
+import "context"
+
+type PendingHTLC struct {
+	Incoming         bool   `json:"incoming"`
+	AmountSats       int64  `json:"amount,string"`
+	HashLock         string `json:"hash_lock"`
+	ExpirationHeight uint32 `json:"expiration_height"`
+	HTLCIndex        uint64 `json:"htlc_index,string"`
+}
+
+type ChannelConstraints struct {
+	CSVDelay         uint32 `json:"csv_delay"`
+	ChanReserveSat   int64  `json:"chan_reserve_sat,string"`
+	DustLimitSat     int64  `json:"dust_limit_sat,string"`
+	MaxAcceptedHTLCs uint32 `json:"max_accepted_htlcs"`
+}
+
+type Channel struct {
+	Active            bool               `json:"active"`
+	RemotePubkey      string             `json:"remote_pubkey"`
+	ChannelPoint      string             `json:"channel_point"`
+	ChanID            string             `json:"chan_id"`
+	CapacitySats      int64              `json:"capacity,string"`
+	LocalBalanceSats  int64              `json:"local_balance,string"`
+	RemoteBalanceSats int64              `json:"remote_balance,string"`
+	CommitFeeSats     int64              `json:"commit_fee,string"`
+	CommitWeight      int64              `json:"commit_weight,string"`
+	FeePerKw          int64              `json:"fee_per_kw,string"`
+	UnsettledBalance  int64              `json:"unsettled_balance,string"`
+	PendingHTLCs      []PendingHTLC      `json:"pending_htlcs"`
+	CSVDelay          uint32             `json:"csv_delay"`
+	Private           bool               `json:"private"`
+	Initiator         bool               `json:"initiator"`
+	LocalChanReserve  int64              `json:"local_chan_reserve_sat,string"`
+	RemoteChanReserve int64              `json:"remote_chan_reserve_sat,string"`
+	CommitmentType    string             `json:"commitment_type"`
+	PeerAlias         string             `json:"peer_alias"`
+	LocalConstraints  ChannelConstraints `json:"local_constraints"`
+	Uptime            int64              `json:"uptime,string"`   // seconds the peer was online
+	Lifetime          int64              `json:"lifetime,string"` // seconds monitored
+}
+
+type listChannelsResponse struct {
+	Channels []Channel `json:"channels"`
+}
+
+func (c *LNDClient) ListChannels(ctx context.Context) ([]Channel, error) {
+	var res listChannelsResponse
+	if err := c.get(ctx, "/v1/channels", &res); err != nil {
+		return nil, err
+	}
+	return res.Channels, nil
+}
+
+// IsAnchor reports whether the commitment carries anchor outputs and can be
+// fee-bumped with CPFP at close time.
+func (ch Channel) IsAnchor() bool {
+	switch ch.CommitmentType {
+	case "ANCHORS", "SCRIPT_ENFORCED_LEASE", "SIMPLE_TAPROOT":
+		return true
+	}
+	return false
+}
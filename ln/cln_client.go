@@ -0,0 +1,350 @@
+package ln
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"sovereign-checker/btc"
+)
+
+// clnTransport carries a single Core Lightning RPC call.
+type clnTransport interface {
+	call(ctx context.Context, method string, params, out interface{}) error
+}
+
+// CLNClient talks to Core Lightning either over the lightning-rpc unix socket
+// or over clnrest with rune authentication.
+type CLNClient struct {
+	t   clnTransport
+	tls *TLSConfig // nil for the unix socket
+}
+
+func NewCLNSocketClient(socketPath string) *CLNClient {
+	return &CLNClient{t: &clnSocket{Path: socketPath, Timeout: 10 * time.Second}}
+}
+
+func NewCLNRestClient(baseURL, runePath string, client *http.Client, tlsCfg TLSConfig) (*CLNClient, error) {
+	rb, err := os.ReadFile(runePath)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		tc, err := tlsCfg.Build()
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{TLSClientConfig: tc}}
+	}
+	return &CLNClient{
+		t: &clnRest{
+			BaseURL: strings.TrimRight(baseURL, "/"),
+			Rune:    strings.TrimSpace(string(rb)),
+			Client:  client,
+		},
+		tls: &tlsCfg,
+	}, nil
+}
+
+// WithHTTPClient returns a copy of c whose clnrest requests go through
+// wrap(client). Socket clients are returned unchanged.
+func (c *CLNClient) WithHTTPClient(wrap func(*http.Client) *http.Client) *CLNClient {
+	r, ok := c.t.(*clnRest)
+	if !ok {
+		return c
+	}
+	rr := *r
+	rr.Client = wrap(r.Client)
+	return &CLNClient{t: &rr, tls: c.tls}
+}
+
+func (c *CLNClient) Name() string { return "cln" }
+
+func (c *CLNClient) Credential() *Credential {
+	if c.tls == nil {
+		return &Credential{Type: "unix-socket", Kind: "admin"}
+	}
+	return &Credential{Type: "rune"}
+}
+
+func (c *CLNClient) SecurityWarnings() []Warning {
+	if c.tls == nil {
+		return nil
+	}
+	return tlsWarnings("clnrest", *c.tls)
+}
+
+type clnSocket struct {
+	Path    string
+	Timeout time.Duration
+}
+
+type clnRPCReq struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type clnRPCResp struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *clnSocket) call(ctx context.Context, method string, params, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.Path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if err := json.NewEncoder(conn).Encode(clnRPCReq{Jsonrpc: "2.0", ID: 1, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	var resp clnRPCResp
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("cln rpc error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+type clnRest struct {
+	BaseURL string
+	Rune    string
+	Client  *http.Client
+}
+
+func (r *clnRest) call(ctx context.Context, method string, params, out interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", r.BaseURL+"/v1/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Rune", r.Rune)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("clnrest http %d: %s", resp.StatusCode, string(b))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type clnGetInfo struct {
+	ID                    string `json:"id"`
+	Alias                 string `json:"alias"`
+	Blockheight           int    `json:"blockheight"`
+	Version               string `json:"version"`
+	NumPeers              int    `json:"num_peers"`
+	NumActiveChannels     int    `json:"num_active_channels"`
+	WarningBitcoindSync   string `json:"warning_bitcoind_sync"`
+	WarningLightningdSync string `json:"warning_lightningd_sync"`
+}
+
+func (c *CLNClient) GetInfo(ctx context.Context) (GetInfoResponse, error) {
+	var gi clnGetInfo
+	if err := c.t.call(ctx, "getinfo", nil, &gi); err != nil {
+		return GetInfoResponse{}, err
+	}
+	synced := gi.WarningBitcoindSync == "" && gi.WarningLightningdSync == ""
+	return GetInfoResponse{
+		IdentityPubkey:    gi.ID,
+		Alias:             gi.Alias,
+		BlockHeight:       gi.Blockheight,
+		Version:           gi.Version,
+		NumActiveChannels: gi.NumActiveChannels,
+		NumPeers:          gi.NumPeers,
+		SyncedToChain:     synced,
+		SyncedToGraph:     synced, // CLN has no separate graph sync flag
+	}, nil
+}
+
+type clnHTLC struct {
+	Direction  string `json:"direction"` // "in" or "out"
+	ID         uint64 `json:"id"`
+	AmountMsat int64  `json:"amount_msat"`
+	Expiry     uint32 `json:"expiry"`
+	Hash       string `json:"payment_hash"`
+}
+
+type clnPeerChannel struct {
+	PeerID           string    `json:"peer_id"`
+	PeerConnected    bool      `json:"peer_connected"`
+	State            string    `json:"state"`
+	ShortChannelID   string    `json:"short_channel_id"`
+	FundingTxID      string    `json:"funding_txid"`
+	FundingOutnum    int       `json:"funding_outnum"`
+	Private          bool      `json:"private"`
+	Opener           string    `json:"opener"`
+	Features         []string  `json:"features"`
+	TotalMsat        int64     `json:"total_msat"`
+	ToUsMsat         int64     `json:"to_us_msat"`
+	OurReserveMsat   int64     `json:"our_reserve_msat"`
+	TheirReserveMsat int64     `json:"their_reserve_msat"`
+	LastTxFeeMsat    int64     `json:"last_tx_fee_msat"`
+	OurToSelfDelay   uint32    `json:"our_to_self_delay"`
+	MaxAcceptedHTLCs uint32    `json:"max_accepted_htlcs"`
+	HTLCs            []clnHTLC `json:"htlcs"`
+	Feerate          struct {
+		PerKw int64 `json:"perkw"`
+	} `json:"feerate"`
+}
+
+func clnCommitmentType(features []string) string {
+	t := "LEGACY"
+	for _, f := range features {
+		switch f {
+		case "option_anchors_zero_fee_htlc_tx", "option_anchor_outputs", "option_anchors":
+			return "ANCHORS"
+		case "option_static_remotekey":
+			t = "STATIC_REMOTE_KEY"
+		}
+	}
+	return t
+}
+
+func (c *CLNClient) ListChannels(ctx context.Context) ([]Channel, error) {
+	var res struct {
+		Channels []clnPeerChannel `json:"channels"`
+	}
+	if err := c.t.call(ctx, "listpeerchannels", nil, &res); err != nil {
+		return nil, err
+	}
+
+	out := make([]Channel, 0, len(res.Channels))
+	for _, pc := range res.Channels {
+		if pc.State != "CHANNELD_NORMAL" && pc.State != "CHANNELD_AWAITING_SPLICE" {
+			continue
+		}
+		htlcs := make([]PendingHTLC, 0, len(pc.HTLCs))
+		for _, h := range pc.HTLCs {
+			htlcs = append(htlcs, PendingHTLC{
+				Incoming:         h.Direction == "in",
+				AmountSats:       h.AmountMsat / 1000,
+				HashLock:         h.Hash,
+				ExpirationHeight: h.Expiry,
+				HTLCIndex:        h.ID,
+			})
+		}
+		out = append(out, Channel{
+			Active:            pc.PeerConnected,
+			RemotePubkey:      pc.PeerID,
+			ChannelPoint:      fmt.Sprintf("%s:%d", pc.FundingTxID, pc.FundingOutnum),
+			ChanID:            pc.ShortChannelID,
+			CapacitySats:      pc.TotalMsat / 1000,
+			LocalBalanceSats:  pc.ToUsMsat / 1000,
+			RemoteBalanceSats: (pc.TotalMsat - pc.ToUsMsat) / 1000,
+			CommitFeeSats:     pc.LastTxFeeMsat / 1000,
+			FeePerKw:          pc.Feerate.PerKw,
+			PendingHTLCs:      htlcs,
+			CSVDelay:          pc.OurToSelfDelay,
+			Private:           pc.Private,
+			Initiator:         pc.Opener == "local",
+			LocalChanReserve:  pc.OurReserveMsat / 1000,
+			RemoteChanReserve: pc.TheirReserveMsat / 1000,
+			CommitmentType:    clnCommitmentType(pc.Features),
+			LocalConstraints:  ChannelConstraints{CSVDelay: pc.OurToSelfDelay, MaxAcceptedHTLCs: pc.MaxAcceptedHTLCs},
+		})
+	}
+	return out, nil
+}
+
+type clnFundsOutput struct {
+	TxID       string `json:"txid"`
+	Output     int    `json:"output"`
+	AmountMsat int64  `json:"amount_msat"`
+	Status     string `json:"status"`
+	Reserved   bool   `json:"reserved"`
+	Height     int    `json:"blockheight"`
+}
+
+func (c *CLNClient) listFunds(ctx context.Context) ([]clnFundsOutput, error) {
+	var res struct {
+		Outputs []clnFundsOutput `json:"outputs"`
+	}
+	if err := c.t.call(ctx, "listfunds", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Outputs, nil
+}
+
+func (c *CLNClient) WalletBalance(ctx context.Context) (WalletBalance, error) {
+	outs, err := c.listFunds(ctx)
+	if err != nil {
+		return WalletBalance{}, err
+	}
+	var bal WalletBalance
+	for _, o := range outs {
+		sats := o.AmountMsat / 1000
+		switch {
+		case o.Status == "spent":
+			continue
+		case o.Reserved:
+			bal.LockedBalanceSats += sats
+		case o.Status == "confirmed":
+			bal.ConfirmedBalanceSats += sats
+		default:
+			bal.UnconfirmedBalanceSats += sats
+		}
+		bal.TotalBalanceSats += sats
+	}
+	return bal, nil
+}
+
+func (c *CLNClient) ListUnspent(ctx context.Context) ([]btc.UTXO, error) {
+	outs, err := c.listFunds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]btc.UTXO, 0, len(outs))
+	for _, o := range outs {
+		if o.Status == "spent" {
+			continue
+		}
+		res = append(res, btc.UTXO{
+			TxID:        o.TxID,
+			Vout:        o.Output,
+			ValueSats:   positive(o.AmountMsat / 1000),
+			Confirmed:   o.Status == "confirmed",
+			BlockHeight: o.Height,
+			Source:      "cln",
+		})
+	}
+	return res, nil
+}
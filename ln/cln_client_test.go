@@ -0,0 +1,202 @@
+package ln
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+// clnResults are canned replies in the shape lightningd returns them.
+var clnResults = map[string]string{
+	"getinfo": `{"id":"02cln","alias":"carol","blockheight":850000,"version":"v24.08",
+		"num_peers":3,"num_active_channels":2,"warning_bitcoind_sync":"","warning_lightningd_sync":""}`,
+	"listpeerchannels": `{"channels":[
+		{"peer_id":"03aa","peer_connected":true,"state":"CHANNELD_NORMAL","short_channel_id":"850000x1x0",
+		 "funding_txid":"ab01","funding_outnum":1,"opener":"local","features":["option_static_remotekey","option_anchors_zero_fee_htlc_tx"],
+		 "total_msat":1000000000,"to_us_msat":600000500,"our_reserve_msat":10000000,"their_reserve_msat":10000000,
+		 "last_tx_fee_msat":2500000,"our_to_self_delay":144,"max_accepted_htlcs":483,"feerate":{"perkw":253},
+		 "htlcs":[{"direction":"in","id":7,"amount_msat":50000000,"expiry":850100,"payment_hash":"ff00"}]},
+		{"peer_id":"03bb","peer_connected":false,"state":"CHANNELD_NORMAL","short_channel_id":"850001x2x1",
+		 "funding_txid":"ab02","funding_outnum":0,"opener":"remote","private":true,"features":["option_static_remotekey"],
+		 "total_msat":500000000,"to_us_msat":0,"our_to_self_delay":720,"feerate":{"perkw":1000}},
+		{"peer_id":"03cc","state":"ONCHAIN","short_channel_id":"849000x3x0","total_msat":200000000}]}`,
+	"listfunds": `{"outputs":[
+		{"txid":"cc01","output":0,"amount_msat":100000000,"status":"confirmed","blockheight":849000},
+		{"txid":"cc02","output":1,"amount_msat":20000000,"status":"unconfirmed"},
+		{"txid":"cc03","output":0,"amount_msat":30000000,"status":"confirmed","reserved":true,"blockheight":849500},
+		{"txid":"cc04","output":2,"amount_msat":40000000,"status":"spent"}]}`,
+}
+
+// fakeCLNSocket serves JSON-RPC on a unix socket like lightning-rpc and
+// records the methods called. The method "fail" returns an RPC error.
+func fakeCLNSocket(t *testing.T) (string, *[]string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "cln") // short path: unix socket names are limited
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "lightning-rpc")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var methods []string
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var req clnRPCReq
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				conn.Close()
+				continue
+			}
+			methods = append(methods, req.Method)
+			if res, ok := clnResults[req.Method]; ok {
+				conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + res + `}`))
+			} else {
+				conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Unknown command '` + req.Method + `'"}}`))
+			}
+			conn.Close()
+		}
+	}()
+	return path, &methods
+}
+
+// fakeCLNRest serves clnrest's POST /v1/<method>, checking the rune.
+func fakeCLNRest(t *testing.T) *CLNClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Rune") != "test-rune" {
+			http.Error(w, `{"code":1501,"message":"Not authorized: Not a valid rune"}`, http.StatusUnauthorized)
+			return
+		}
+		res, ok := clnResults[strings.TrimPrefix(r.URL.Path, "/v1/")]
+		if r.Method != "POST" || !ok {
+			http.Error(w, `{"code":-32601,"message":"Unknown command"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(res))
+	}))
+	t.Cleanup(srv.Close)
+	runePath := filepath.Join(t.TempDir(), "rune")
+	if err := os.WriteFile(runePath, []byte("test-rune\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCLNRestClient(srv.URL+"/", runePath, srv.Client(), TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCLNClientMappings(t *testing.T) {
+	sock, _ := fakeCLNSocket(t)
+	clients := map[string]*CLNClient{
+		"socket": NewCLNSocketClient(sock),
+		"rest":   fakeCLNRest(t),
+	}
+	for name, c := range clients {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			info, err := c.GetInfo(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantInfo := GetInfoResponse{IdentityPubkey: "02cln", Alias: "carol", BlockHeight: 850000, Version: "v24.08",
+				NumActiveChannels: 2, NumPeers: 3, SyncedToChain: true, SyncedToGraph: true}
+			if !reflect.DeepEqual(info, wantInfo) {
+				t.Errorf("getinfo = %+v\nwant %+v", info, wantInfo)
+			}
+
+			chans, err := c.ListChannels(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantChans := []Channel{
+				{
+					Active: true, RemotePubkey: "03aa", ChannelPoint: "ab01:1", ChanID: "850000x1x0",
+					CapacitySats: 1_000_000, LocalBalanceSats: 600_000, RemoteBalanceSats: 399_999,
+					CommitFeeSats: 2500, FeePerKw: 253, CSVDelay: 144, Initiator: true,
+					PendingHTLCs:     []PendingHTLC{{Incoming: true, AmountSats: 50_000, HashLock: "ff00", ExpirationHeight: 850100, HTLCIndex: 7}},
+					LocalChanReserve: 10_000, RemoteChanReserve: 10_000, CommitmentType: "ANCHORS",
+					LocalConstraints: ChannelConstraints{CSVDelay: 144, MaxAcceptedHTLCs: 483},
+				},
+				{
+					RemotePubkey: "03bb", ChannelPoint: "ab02:0", ChanID: "850001x2x1",
+					CapacitySats: 500_000, RemoteBalanceSats: 500_000, FeePerKw: 1000, CSVDelay: 720, Private: true,
+					PendingHTLCs: []PendingHTLC{}, CommitmentType: "STATIC_REMOTE_KEY",
+					LocalConstraints: ChannelConstraints{CSVDelay: 720},
+				},
+			}
+			if !reflect.DeepEqual(chans, wantChans) {
+				t.Errorf("listpeerchannels = %+v\nwant %+v", chans, wantChans)
+			}
+
+			bal, err := c.WalletBalance(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantBal := WalletBalance{TotalBalanceSats: 150_000, ConfirmedBalanceSats: 100_000, UnconfirmedBalanceSats: 20_000, LockedBalanceSats: 30_000}
+			if bal != wantBal {
+				t.Errorf("wallet balance = %+v, want %+v", bal, wantBal)
+			}
+
+			utxos, err := c.ListUnspent(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantUTXOs := []btc.UTXO{
+				{TxID: "cc01", Vout: 0, ValueSats: 100_000, Confirmed: true, BlockHeight: 849000, Source: "cln"},
+				{TxID: "cc02", Vout: 1, ValueSats: 20_000, Source: "cln"},
+				{TxID: "cc03", Vout: 0, ValueSats: 30_000, Confirmed: true, BlockHeight: 849500, Source: "cln"},
+			}
+			if !reflect.DeepEqual(utxos, wantUTXOs) {
+				t.Errorf("listfunds = %+v\nwant %+v", utxos, wantUTXOs)
+			}
+		})
+	}
+}
+
+func TestCLNClientErrors(t *testing.T) {
+	ctx := context.Background()
+
+	sock, methods := fakeCLNSocket(t)
+	var out struct{}
+	err := NewCLNSocketClient(sock).t.call(ctx, "fail", nil, &out)
+	if err == nil || err.Error() != "cln rpc error -32601: Unknown command 'fail'" {
+		t.Errorf("socket rpc error = %v", err)
+	}
+	if len(*methods) != 1 || (*methods)[0] != "fail" {
+		t.Errorf("methods called = %v", *methods)
+	}
+
+	rest := fakeCLNRest(t)
+	if err := rest.t.call(ctx, "fail", nil, &out); err == nil || !strings.HasPrefix(err.Error(), "clnrest http 500:") {
+		t.Errorf("clnrest error = %v, want http 500", err)
+	}
+	rest.t.(*clnRest).Rune = "wrong"
+	if _, err := rest.GetInfo(ctx); err == nil || !strings.Contains(err.Error(), "clnrest http 401") {
+		t.Errorf("clnrest bad rune = %v, want http 401", err)
+	}
+
+	if _, err := NewCLNSocketClient(filepath.Join(t.TempDir(), "missing")).GetInfo(ctx); err == nil {
+		t.Errorf("missing socket: no error")
+	}
+}
@@ -0,0 +1,178 @@
+package ln
+
+import "fmt"
+
+// Rough vbyte sizes for unilateral close transactions (BOLT 3 weights / 4, rounded up).
+const (
+	legacyCommitVBytes    = 181 // 724 WU
+	anchorCommitVBytes    = 281 // 1124 WU
+	htlcOutputVBytes      = 43  // 172 WU per HTLC output on the commitment
+	anchorCPFPVBytes      = 180 // anchor input + one wallet input + change
+	toLocalSweepVBytes    = 122 // CSV-delayed to_local sweep to a single output
+	htlcTimeoutVBytes     = 166 // second-level HTLC-timeout tx
+	htlcSuccessVBytes     = 176 // second-level HTLC-success tx
+	anchorHTLCExtraVBytes = 99  // wallet input + change to fund zero-fee anchor HTLC txs
+)
+
+type ChannelExit struct {
+	ChannelPoint       string `json:"channel_point"`
+	CommitmentType     string `json:"commitment_type"`
+	Anchor             bool   `json:"anchor"`
+	LocalBalanceSats   uint64 `json:"local_balance_sats"`
+	CSVDelayBlocks     uint32 `json:"csv_delay_blocks"`
+	PendingHTLCs       int    `json:"pending_htlcs"`
+	MaxHTLCExpiry      uint32 `json:"max_htlc_expiry_height,omitempty"`
+	CommitVBytes       uint64 `json:"commit_vbytes"`
+	CommitFeeSats      uint64 `json:"commit_fee_sats"`
+	CommitFeeRateSatVB uint64 `json:"commit_fee_rate_sat_vb"`
+	CPFPFeeSats        uint64 `json:"cpfp_fee_sats"`
+	StressedCPFPSats   uint64 `json:"stressed_cpfp_fee_sats"`
+	SweepFeeSats       uint64 `json:"sweep_fee_sats"`
+	HTLCFeeSats        uint64 `json:"htlc_fee_sats"`
+	TotalFeeSats       uint64 `json:"total_fee_sats"`
+	StressedFeeSats    uint64 `json:"stressed_total_fee_sats"`
+}
+
+type ExitPlan struct {
+	FeeRateSatVB          uint64        `json:"fee_rate_sat_vb"`
+	StressedFeeRateSatVB  uint64        `json:"stressed_fee_rate_sat_vb"`
+	NumChannels           int           `json:"num_channels"`
+	NumAnchorChannels     int           `json:"num_anchor_channels"`
+	TotalLocalBalanceSats uint64        `json:"total_local_balance_sats"`
+	TotalFeeSats          uint64        `json:"total_fee_sats"`
+	StressedTotalFeeSats  uint64        `json:"stressed_total_fee_sats"`
+	MaxCSVDelayBlocks     uint32        `json:"max_csv_delay_blocks"`
+	Channels              []ChannelExit `json:"channels"`
+	Warnings              []string      `json:"warnings"`
+}
+
+// ExitCosts puts the on-chain sweep fee and the LN unilateral exit fee side by side.
+type ExitCosts struct {
+	OnChainSweepFeeSats      uint64    `json:"onchain_sweep_fee_sats"`
+	LNForceCloseFeeSats      *uint64   `json:"ln_force_close_fee_sats,omitempty"`
+	LNForceCloseStressedSats *uint64   `json:"ln_force_close_stressed_fee_sats,omitempty"`
+	LNExitPlan               *ExitPlan `json:"ln_exit_plan,omitempty"`
+}
+
+// NewExitCosts pairs the on-chain sweep fee with plan, which may be nil when
+// there is no Lightning node.
+func NewExitCosts(onchainSweepFee uint64, plan *ExitPlan) ExitCosts {
+	ec := ExitCosts{OnChainSweepFeeSats: onchainSweepFee, LNExitPlan: plan}
+	if plan != nil {
+		ec.LNForceCloseFeeSats = &plan.TotalFeeSats
+		ec.LNForceCloseStressedSats = &plan.StressedTotalFeeSats
+	}
+	return ec
+}
+
+// StressedFeeRate scales feeRate by multiplier (default 5x) for worst-case exit planning.
+func StressedFeeRate(feeRate, multiplier uint64) uint64 {
+	if multiplier == 0 {
+		multiplier = 5
+	}
+	return feeRate * multiplier
+}
+
+func positive(v int64) uint64 {
+	if v < 0 {
+		return 0
+	}
+	return uint64(v)
+}
+
+// exitFees returns the CPFP, to_local sweep and HTLC resolution fees needed to
+// force close ch at feeRate.
+func exitFees(ch Channel, commitVB, feeRate uint64) (cpfp, sweep, htlc uint64) {
+	if ch.IsAnchor() {
+		pkg := feeRate * (commitVB + anchorCPFPVBytes)
+		if commitFee := positive(ch.CommitFeeSats); pkg > commitFee {
+			cpfp = pkg - commitFee
+		}
+	}
+	if ch.LocalBalanceSats > 0 {
+		sweep = feeRate * toLocalSweepVBytes
+	}
+	for _, h := range ch.PendingHTLCs {
+		vb := uint64(htlcTimeoutVBytes)
+		if h.Incoming {
+			vb = htlcSuccessVBytes
+		}
+		if ch.IsAnchor() {
+			vb += anchorHTLCExtraVBytes
+		}
+		htlc += feeRate * (vb + toLocalSweepVBytes)
+	}
+	return cpfp, sweep, htlc
+}
+
+// ComputeExitPlan estimates what it costs to force close every channel without
+// the peers' cooperation, at the current and a stressed fee rate.
+func ComputeExitPlan(channels []Channel, feeRate, stressedFeeRate uint64) ExitPlan {
+	plan := ExitPlan{
+		FeeRateSatVB:         feeRate,
+		StressedFeeRateSatVB: stressedFeeRate,
+		NumChannels:          len(channels),
+		Channels:             []ChannelExit{},
+		Warnings:             []string{},
+	}
+
+	for _, ch := range channels {
+		anchor := ch.IsAnchor()
+		base := uint64(legacyCommitVBytes)
+		if anchor {
+			base = anchorCommitVBytes
+			plan.NumAnchorChannels++
+		}
+		commitVB := base + uint64(len(ch.PendingHTLCs))*htlcOutputVBytes
+
+		cpfp, sweep, htlc := exitFees(ch, commitVB, feeRate)
+		sCPFP, sSweep, sHTLC := exitFees(ch, commitVB, stressedFeeRate)
+
+		var maxExpiry uint32
+		for _, h := range ch.PendingHTLCs {
+			if h.ExpirationHeight > maxExpiry {
+				maxExpiry = h.ExpirationHeight
+			}
+		}
+
+		exit := ChannelExit{
+			ChannelPoint:       ch.ChannelPoint,
+			CommitmentType:     ch.CommitmentType,
+			Anchor:             anchor,
+			LocalBalanceSats:   positive(ch.LocalBalanceSats),
+			CSVDelayBlocks:     ch.CSVDelay,
+			PendingHTLCs:       len(ch.PendingHTLCs),
+			MaxHTLCExpiry:      maxExpiry,
+			CommitVBytes:       commitVB,
+			CommitFeeSats:      positive(ch.CommitFeeSats),
+			CommitFeeRateSatVB: positive(ch.FeePerKw) * 4 / 1000,
+			CPFPFeeSats:        cpfp,
+			StressedCPFPSats:   sCPFP,
+			SweepFeeSats:       sweep,
+			HTLCFeeSats:        htlc,
+			TotalFeeSats:       cpfp + sweep + htlc,
+			StressedFeeSats:    sCPFP + sSweep + sHTLC,
+		}
+		plan.Channels = append(plan.Channels, exit)
+
+		plan.TotalLocalBalanceSats += exit.LocalBalanceSats
+		plan.TotalFeeSats += exit.TotalFeeSats
+		plan.StressedTotalFeeSats += exit.StressedFeeSats
+		if ch.CSVDelay > plan.MaxCSVDelayBlocks {
+			plan.MaxCSVDelayBlocks = ch.CSVDelay
+		}
+
+		if !anchor && exit.CommitFeeRateSatVB < feeRate {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"Channel %s: legacy commitment pays %d sat/vB (< current %d) and cannot be CPFP'd; a force close may not confirm.",
+				ch.ChannelPoint, exit.CommitFeeRateSatVB, feeRate))
+		}
+		if exit.LocalBalanceSats > 0 && exit.StressedFeeSats >= exit.LocalBalanceSats {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"Channel %s: force close at stressed fees costs more than the local balance.",
+				ch.ChannelPoint))
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,70 @@
+package ln
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeExitPlan(t *testing.T) {
+	channels := []Channel{
+		{ChannelPoint: "anchor:0", CommitmentType: "ANCHORS", LocalBalanceSats: 500_000, CommitFeeSats: 2810, CSVDelay: 144},
+		{ChannelPoint: "legacy:1", CommitmentType: "STATIC_REMOTE_KEY", LocalBalanceSats: 100_000, FeePerKw: 1000, CSVDelay: 2016,
+			PendingHTLCs: []PendingHTLC{{Incoming: true, AmountSats: 5000, ExpirationHeight: 800_040}}},
+		{ChannelPoint: "small:2", CommitmentType: "SIMPLE_TAPROOT", LocalBalanceSats: 20_000},
+	}
+	plan := ComputeExitPlan(channels, 10, StressedFeeRate(10, 0))
+
+	tests := []struct {
+		commitVB, cpfp, sweep, htlc, total, stressed uint64
+	}{
+		// 281 vB anchor commitment: CPFP 10*(281+180)-2810, sweep 10*122
+		{281, 1800, 1220, 0, 3020, 26340},
+		// 181+43 vB legacy commitment, no CPFP; HTLC-success 10*(176+122)
+		{224, 0, 1220, 2980, 4200, 21000},
+		{281, 4610, 1220, 0, 5830, 29150},
+	}
+	for i, tt := range tests {
+		c := plan.Channels[i]
+		if c.CommitVBytes != tt.commitVB || c.CPFPFeeSats != tt.cpfp || c.SweepFeeSats != tt.sweep || c.HTLCFeeSats != tt.htlc ||
+			c.TotalFeeSats != tt.total || c.StressedFeeSats != tt.stressed {
+			t.Errorf("%s = %+v, want %+v", c.ChannelPoint, c, tt)
+		}
+	}
+	if plan.StressedFeeRateSatVB != 50 || plan.NumAnchorChannels != 2 || plan.MaxCSVDelayBlocks != 2016 ||
+		plan.TotalLocalBalanceSats != 620_000 || plan.TotalFeeSats != 13_050 || plan.StressedTotalFeeSats != 76_490 {
+		t.Errorf("plan totals = %+v", plan)
+	}
+	if plan.Channels[1].MaxHTLCExpiry != 800_040 || plan.Channels[1].CommitFeeRateSatVB != 4 {
+		t.Errorf("legacy channel = %+v", plan.Channels[1])
+	}
+	warnings := strings.Join(plan.Warnings, "\n")
+	if len(plan.Warnings) != 2 || !strings.Contains(warnings, "legacy:1: legacy commitment pays 4 sat/vB") ||
+		!strings.Contains(warnings, "small:2: force close at stressed fees costs more") {
+		t.Errorf("warnings = %v", plan.Warnings)
+	}
+}
+
+func TestStressedFeeRate(t *testing.T) {
+	for _, tt := range []struct{ rate, mult, want uint64 }{
+		{10, 0, 50},
+		{10, 1, 10},
+		{7, 3, 21},
+		{0, 5, 0},
+	} {
+		if got := StressedFeeRate(tt.rate, tt.mult); got != tt.want {
+			t.Errorf("StressedFeeRate(%d, %d) = %d, want %d", tt.rate, tt.mult, got, tt.want)
+		}
+	}
+}
+
+func TestNewExitCosts(t *testing.T) {
+	if ec := NewExitCosts(1500, nil); ec.OnChainSweepFeeSats != 1500 || ec.LNForceCloseFeeSats != nil || ec.LNExitPlan != nil {
+		t.Errorf("without LN = %+v", ec)
+	}
+	plan := ComputeExitPlan([]Channel{{CommitmentType: "ANCHORS", LocalBalanceSats: 100_000}}, 2, 10)
+	ec := NewExitCosts(1500, &plan)
+	if ec.LNForceCloseFeeSats == nil || *ec.LNForceCloseFeeSats != plan.TotalFeeSats ||
+		ec.LNForceCloseStressedSats == nil || *ec.LNForceCloseStressedSats != plan.StressedTotalFeeSats {
+		t.Errorf("with LN = %+v", ec)
+	}
+}
@@ -0,0 +1,319 @@
+package ln
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RoutingInspector is implemented by backends that expose the channel graph,
+// fee policies and forwarding history (currently LND).
+type RoutingInspector interface {
+	ChannelEdge(ctx context.Context, chanID string) (ChannelEdge, error)
+	FeeReport(ctx context.Context) (FeeReport, error)
+	ForwardingHistory(ctx context.Context, start, end time.Time) ([]ForwardingEvent, error)
+}
+
+var _ RoutingInspector = (*LNDClient)(nil)
+
+type RoutingPolicy struct {
+	TimeLockDelta    uint32 `json:"time_lock_delta"`
+	FeeBaseMsat      int64  `json:"fee_base_msat,string"`
+	FeeRateMilliMsat int64  `json:"fee_rate_milli_msat,string"` // ppm
+	Disabled         bool   `json:"disabled"`
+	LastUpdate       int64  `json:"last_update"`
+}
+
+type ChannelEdge struct {
+	ChannelID   string         `json:"channel_id"`
+	ChanPoint   string         `json:"chan_point"`
+	Node1Pub    string         `json:"node1_pub"`
+	Node2Pub    string         `json:"node2_pub"`
+	Node1Policy *RoutingPolicy `json:"node1_policy"`
+	Node2Policy *RoutingPolicy `json:"node2_policy"`
+}
+
+// Policies returns (ours, theirs) given our node pubkey.
+func (e ChannelEdge) Policies(self string) (*RoutingPolicy, *RoutingPolicy) {
+	if e.Node1Pub == self {
+		return e.Node1Policy, e.Node2Policy
+	}
+	return e.Node2Policy, e.Node1Policy
+}
+
+func (c *LNDClient) ChannelEdge(ctx context.Context, chanID string) (ChannelEdge, error) {
+	var res ChannelEdge
+	err := c.get(ctx, "/v1/graph/edge/"+chanID, &res)
+	return res, err
+}
+
+type FeeReport struct {
+	ChannelFees []struct {
+		ChanID       string `json:"chan_id"`
+		ChannelPoint string `json:"channel_point"`
+		BaseFeeMsat  int64  `json:"base_fee_msat,string"`
+		FeePerMil    int64  `json:"fee_per_mil,string"`
+	} `json:"channel_fees"`
+	DayFeeSum   int64 `json:"day_fee_sum,string"`
+	WeekFeeSum  int64 `json:"week_fee_sum,string"`
+	MonthFeeSum int64 `json:"month_fee_sum,string"`
+}
+
+func (c *LNDClient) FeeReport(ctx context.Context) (FeeReport, error) {
+	var res FeeReport
+	err := c.get(ctx, "/v1/fees", &res)
+	return res, err
+}
+
+type ForwardingEvent struct {
+	TimestampNs int64  `json:"timestamp_ns,string"`
+	ChanIDIn    string `json:"chan_id_in"`
+	ChanIDOut   string `json:"chan_id_out"`
+	AmtOutMsat  int64  `json:"amt_out_msat,string"`
+	FeeMsat     int64  `json:"fee_msat,string"`
+}
+
+type forwardingHistoryRequest struct {
+	StartTime    int64  `json:"start_time,string"`
+	EndTime      int64  `json:"end_time,string"`
+	IndexOffset  uint32 `json:"index_offset"`
+	NumMaxEvents uint32 `json:"num_max_events"`
+}
+
+type forwardingHistoryResponse struct {
+	ForwardingEvents []ForwardingEvent `json:"forwarding_events"`
+	LastOffsetIndex  uint32            `json:"last_offset_index"`
+}
+
+const forwardingPageSize = 10_000
+
+func (c *LNDClient) ForwardingHistory(ctx context.Context, start, end time.Time) ([]ForwardingEvent, error) {
+	var all []ForwardingEvent
+	req := forwardingHistoryRequest{
+		StartTime:    start.Unix(),
+		EndTime:      end.Unix(),
+		NumMaxEvents: forwardingPageSize,
+	}
+	for {
+		var res forwardingHistoryResponse
+		if err := c.post(ctx, "/v1/switch", req, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.ForwardingEvents...)
+		if len(res.ForwardingEvents) < forwardingPageSize {
+			return all, nil
+		}
+		req.IndexOffset = res.LastOffsetIndex
+	}
+}
+
+type ChannelHealth struct {
+	ChanID         string   `json:"chan_id"`
+	ChannelPoint   string   `json:"channel_point"`
+	Peer           string   `json:"peer"`
+	PeerAlias      string   `json:"peer_alias,omitempty"`
+	Active         bool     `json:"active"`
+	UptimePct      float64  `json:"uptime_pct"`
+	LocalDisabled  bool     `json:"local_disabled"`
+	RemoteDisabled bool     `json:"remote_disabled"`
+	PolicyAgeDays  int      `json:"policy_age_days"`
+	FeeBaseMsat    int64    `json:"fee_base_msat"`
+	FeePPM         int64    `json:"fee_ppm"`
+	ForwardsIn     int      `json:"forwards_in"`
+	ForwardsOut    int      `json:"forwards_out"`
+	RevenueMsat    uint64   `json:"revenue_msat"`
+	AgeDays        int      `json:"age_days"`
+	LastForward    string   `json:"last_forward,omitempty"`
+	CloseCandidate bool     `json:"close_candidate"`
+	Flags          []string `json:"flags"`
+}
+
+type ChannelHealthReport struct {
+	WindowDays       int             `json:"window_days"`
+	IdleDays         int             `json:"idle_days"`
+	MedianFeePPM     int64           `json:"median_fee_ppm"`
+	MedianBaseMsat   int64           `json:"median_base_msat"`
+	MedianSource     string          `json:"median_source"`
+	TotalRevenueMsat uint64          `json:"total_revenue_msat"`
+	DayFeeSumSats    int64           `json:"day_fee_sum_sats"`
+	WeekFeeSumSats   int64           `json:"week_fee_sum_sats"`
+	MonthFeeSumSats  int64           `json:"month_fee_sum_sats"`
+	Channels         []ChannelHealth `json:"channels"`
+	CloseCandidates  []string        `json:"close_candidates"`
+	Warnings         []string        `json:"warnings"`
+}
+
+type HealthOptions struct {
+	WindowDays int // forwarding revenue window, default 30
+	IdleDays   int // no forwards for this long flags a close candidate, default 30
+	Now        time.Time
+	// Current block height, to date channels by their funding block; 0
+	// falls back to the node-reported channel lifetime
+	BlockHeight int
+}
+
+// Policies older than this are pruned from peers' graphs per BOLT 7.
+const stalePolicyDays = 14
+
+// channelAgeDays estimates how long ch has been open: from the funding block
+// in its short channel id when the tip is known, else from the lifetime LND
+// reports, which restarts with the node and so errs young.
+func channelAgeDays(ch Channel, tipHeight int) int {
+	if id, err := strconv.ParseUint(ch.ChanID, 10, 64); err == nil && tipHeight > 0 {
+		if open := int(id >> 40); open > 0 && open <= tipHeight {
+			return (tipHeight - open) / 144
+		}
+	}
+	return int(ch.Lifetime / 86400)
+}
+
+func median(v []int64) int64 {
+	if len(v) == 0 {
+		return 0
+	}
+	s := append([]int64(nil), v...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/2]
+}
+
+// ChannelHealthFor builds a routing-node health report. Fee medians come from
+// the peers' policies on our channels, a proxy for the surrounding network.
+func ChannelHealthFor(ctx context.Context, ri RoutingInspector, self string, channels []Channel, opts HealthOptions) ChannelHealthReport {
+	if opts.WindowDays <= 0 {
+		opts.WindowDays = 30
+	}
+	if opts.IdleDays <= 0 {
+		opts.IdleDays = 30
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	rep := ChannelHealthReport{
+		WindowDays:      opts.WindowDays,
+		IdleDays:        opts.IdleDays,
+		MedianSource:    "peer_policies",
+		Channels:        []ChannelHealth{},
+		CloseCandidates: []string{},
+		Warnings:        []string{},
+	}
+
+	if fr, err := ri.FeeReport(ctx); err != nil {
+		rep.Warnings = append(rep.Warnings, "fee report unavailable: "+err.Error())
+	} else {
+		rep.DayFeeSumSats, rep.WeekFeeSumSats, rep.MonthFeeSumSats = fr.DayFeeSum, fr.WeekFeeSum, fr.MonthFeeSum
+	}
+
+	lookback := opts.WindowDays
+	if opts.IdleDays > lookback {
+		lookback = opts.IdleDays
+	}
+	events, histErr := ri.ForwardingHistory(ctx, opts.Now.AddDate(0, 0, -lookback), opts.Now)
+	if histErr != nil {
+		rep.Warnings = append(rep.Warnings, "forwarding history unavailable: "+histErr.Error())
+	}
+
+	var peerPPM, peerBase []int64
+	for _, ch := range channels {
+		h := ChannelHealth{
+			ChanID:       ch.ChanID,
+			ChannelPoint: ch.ChannelPoint,
+			Peer:         ch.RemotePubkey,
+			PeerAlias:    ch.PeerAlias,
+			Active:       ch.Active,
+			AgeDays:      channelAgeDays(ch, opts.BlockHeight),
+			Flags:        []string{},
+		}
+		if ch.Lifetime > 0 {
+			h.UptimePct = float64(ch.Uptime) / float64(ch.Lifetime) * 100
+		}
+		if !ch.Active {
+			h.Flags = append(h.Flags, "peer offline")
+		} else if ch.Lifetime > 0 && h.UptimePct < 90 {
+			h.Flags = append(h.Flags, fmt.Sprintf("peer uptime %.0f%%", h.UptimePct))
+		}
+
+		edge, err := ri.ChannelEdge(ctx, ch.ChanID)
+		if err != nil {
+			h.Flags = append(h.Flags, "edge not in graph")
+		} else {
+			ours, theirs := edge.Policies(self)
+			if ours != nil {
+				h.LocalDisabled = ours.Disabled
+				h.FeeBaseMsat = ours.FeeBaseMsat
+				h.FeePPM = ours.FeeRateMilliMsat
+				if ours.LastUpdate > 0 {
+					h.PolicyAgeDays = int(opts.Now.Sub(time.Unix(ours.LastUpdate, 0)).Hours() / 24)
+				}
+			}
+			if theirs != nil {
+				h.RemoteDisabled = theirs.Disabled
+				peerPPM = append(peerPPM, theirs.FeeRateMilliMsat)
+				peerBase = append(peerBase, theirs.FeeBaseMsat)
+				if theirs.LastUpdate > 0 && opts.Now.Sub(time.Unix(theirs.LastUpdate, 0)) > stalePolicyDays*24*time.Hour {
+					h.Flags = append(h.Flags, "peer policy stale")
+				}
+			}
+			if h.LocalDisabled {
+				h.Flags = append(h.Flags, "disabled by us")
+			}
+			if h.RemoteDisabled {
+				h.Flags = append(h.Flags, "disabled by peer")
+			}
+			if h.PolicyAgeDays > stalePolicyDays {
+				h.Flags = append(h.Flags, "our policy stale")
+			}
+		}
+
+		rep.Channels = append(rep.Channels, h)
+	}
+	// Indexes, not pointers: rep.Channels is final only now
+	byChan := make(map[string]int, len(rep.Channels))
+	for i, h := range rep.Channels {
+		byChan[h.ChanID] = i
+	}
+
+	rep.MedianFeePPM = median(peerPPM)
+	rep.MedianBaseMsat = median(peerBase)
+
+	windowStart := opts.Now.AddDate(0, 0, -opts.WindowDays).UnixNano()
+	idleStart := opts.Now.AddDate(0, 0, -opts.IdleDays).UnixNano()
+	last := map[string]int64{}
+	for _, ev := range events {
+		inWindow := ev.TimestampNs >= windowStart
+		if i, ok := byChan[ev.ChanIDIn]; ok && inWindow {
+			rep.Channels[i].ForwardsIn++
+		}
+		if i, ok := byChan[ev.ChanIDOut]; ok && inWindow {
+			rep.Channels[i].ForwardsOut++
+			rep.Channels[i].RevenueMsat += positive(ev.FeeMsat)
+		}
+		for _, id := range []string{ev.ChanIDIn, ev.ChanIDOut} {
+			if ev.TimestampNs > last[id] {
+				last[id] = ev.TimestampNs
+			}
+		}
+	}
+
+	for i := range rep.Channels {
+		h := &rep.Channels[i]
+		rep.TotalRevenueMsat += h.RevenueMsat
+		if ts, ok := last[h.ChanID]; ok {
+			h.LastForward = time.Unix(0, ts).UTC().Format(time.RFC3339)
+		}
+		if rep.MedianFeePPM > 0 && (h.FeePPM > 3*rep.MedianFeePPM || h.FeePPM*3 < rep.MedianFeePPM) {
+			h.Flags = append(h.Flags, "fee "+strconv.FormatInt(h.FeePPM, 10)+" ppm far from median "+
+				strconv.FormatInt(rep.MedianFeePPM, 10)+" ppm")
+		}
+		// A channel younger than the idle threshold has not had the chance to forward
+		if histErr == nil && last[h.ChanID] < idleStart && h.AgeDays >= opts.IdleDays {
+			h.CloseCandidate = true
+			h.Flags = append(h.Flags, fmt.Sprintf("no forwards in %d days", opts.IdleDays))
+			rep.CloseCandidates = append(rep.CloseCandidates, h.ChanID)
+		}
+	}
+
+	return rep
+}
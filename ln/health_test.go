@@ -0,0 +1,222 @@
+package ln
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeRouting struct {
+	edges    map[string]ChannelEdge
+	events   []ForwardingEvent
+	fees     FeeReport
+	histErr  error
+	feeErr   error
+	gotStart time.Time
+}
+
+func (f *fakeRouting) ChannelEdge(_ context.Context, chanID string) (ChannelEdge, error) {
+	e, ok := f.edges[chanID]
+	if !ok {
+		return ChannelEdge{}, errors.New("edge not found")
+	}
+	return e, nil
+}
+
+func (f *fakeRouting) FeeReport(context.Context) (FeeReport, error) { return f.fees, f.feeErr }
+
+func (f *fakeRouting) ForwardingHistory(_ context.Context, start, _ time.Time) ([]ForwardingEvent, error) {
+	f.gotStart = start
+	return f.events, f.histErr
+}
+
+const selfPub = "02self"
+
+func edge(id string, ourPPM, theirPPM int64, updated time.Time) ChannelEdge {
+	return ChannelEdge{
+		ChannelID:   id,
+		Node1Pub:    selfPub,
+		Node2Pub:    "03peer" + id,
+		Node1Policy: &RoutingPolicy{FeeBaseMsat: 1000, FeeRateMilliMsat: ourPPM, LastUpdate: updated.Unix()},
+		Node2Policy: &RoutingPolicy{FeeBaseMsat: 1000, FeeRateMilliMsat: theirPPM, LastUpdate: updated.Unix()},
+	}
+}
+
+func fwd(at time.Time, in, out string, feeMsat int64) ForwardingEvent {
+	return ForwardingEvent{TimestampNs: at.UnixNano(), ChanIDIn: in, ChanIDOut: out, FeeMsat: feeMsat}
+}
+
+func TestChannelHealthFor(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	var channels []Channel
+	edges := map[string]ChannelEdge{}
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		channels = append(channels, Channel{ChanID: id, Active: true, RemotePubkey: "03peer" + id, Uptime: 90 * 86400, Lifetime: 90 * 86400})
+		edges[id] = edge(id, 100, 100, days(1))
+	}
+	edges["5"] = edge("5", 5000, 100, days(1))
+
+	tests := []struct {
+		name          string
+		events        []ForwardingEvent
+		histErr       error
+		opts          HealthOptions
+		wantIn        map[string]int
+		wantOut       map[string]int
+		wantRevenue   map[string]uint64
+		wantCandidate []string
+	}{
+		{
+			name: "forwards on every channel count towards each one",
+			events: []ForwardingEvent{
+				fwd(days(2), "1", "2", 1500),
+				fwd(days(3), "2", "3", 2500),
+				fwd(days(4), "3", "4", 3500),
+				fwd(days(5), "4", "5", 4500),
+				fwd(days(6), "5", "1", 5500),
+			},
+			wantIn:        map[string]int{"1": 1, "2": 1, "3": 1, "4": 1, "5": 1},
+			wantOut:       map[string]int{"1": 1, "2": 1, "3": 1, "4": 1, "5": 1},
+			wantRevenue:   map[string]uint64{"1": 5500, "2": 1500, "3": 2500, "4": 3500, "5": 4500},
+			wantCandidate: []string{},
+		},
+		{
+			name: "idle channels are close candidates, busy ones are not",
+			events: []ForwardingEvent{
+				fwd(days(1), "1", "2", 1000),
+				fwd(days(2), "1", "2", 1000),
+			},
+			wantIn:        map[string]int{"1": 2},
+			wantOut:       map[string]int{"2": 2},
+			wantRevenue:   map[string]uint64{"2": 2000},
+			wantCandidate: []string{"3", "4", "5"},
+		},
+		{
+			name:          "idle check uses the last forward, not the revenue window",
+			events:        []ForwardingEvent{fwd(days(20), "3", "4", 700)},
+			opts:          HealthOptions{WindowDays: 7, IdleDays: 30},
+			wantRevenue:   map[string]uint64{},
+			wantCandidate: []string{"1", "2", "5"},
+		},
+		{
+			name:          "forward older than the idle threshold",
+			events:        []ForwardingEvent{fwd(days(40), "3", "4", 700)},
+			opts:          HealthOptions{WindowDays: 60, IdleDays: 30},
+			wantIn:        map[string]int{"3": 1},
+			wantOut:       map[string]int{"4": 1},
+			wantRevenue:   map[string]uint64{"4": 700},
+			wantCandidate: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:          "no history, no close candidates",
+			histErr:       errors.New("permission denied"),
+			wantCandidate: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ri := &fakeRouting{edges: edges, events: tt.events, histErr: tt.histErr}
+			opts := tt.opts
+			opts.Now = now
+			rep := ChannelHealthFor(context.Background(), ri, selfPub, channels, opts)
+
+			if len(rep.Channels) != len(channels) {
+				t.Fatalf("got %d channels, want %d", len(rep.Channels), len(channels))
+			}
+			var total uint64
+			for _, h := range rep.Channels {
+				if h.ForwardsIn != tt.wantIn[h.ChanID] || h.ForwardsOut != tt.wantOut[h.ChanID] {
+					t.Errorf("chan %s forwards in/out = %d/%d, want %d/%d", h.ChanID, h.ForwardsIn, h.ForwardsOut, tt.wantIn[h.ChanID], tt.wantOut[h.ChanID])
+				}
+				if h.RevenueMsat != tt.wantRevenue[h.ChanID] {
+					t.Errorf("chan %s revenue = %d, want %d", h.ChanID, h.RevenueMsat, tt.wantRevenue[h.ChanID])
+				}
+				total += h.RevenueMsat
+			}
+			if rep.TotalRevenueMsat != total {
+				t.Errorf("total revenue = %d, want %d", rep.TotalRevenueMsat, total)
+			}
+			if len(rep.CloseCandidates) != len(tt.wantCandidate) {
+				t.Fatalf("close candidates = %v, want %v", rep.CloseCandidates, tt.wantCandidate)
+			}
+			for i, id := range tt.wantCandidate {
+				if rep.CloseCandidates[i] != id {
+					t.Errorf("close candidates = %v, want %v", rep.CloseCandidates, tt.wantCandidate)
+				}
+			}
+			if tt.histErr != nil && len(rep.Warnings) == 0 {
+				t.Errorf("expected a warning for the history error")
+			}
+		})
+	}
+}
+
+func TestChannelHealthForFreshChannels(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	scid := func(height uint64) string { return strconv.FormatUint(height<<40|1<<16, 10) }
+	channels := []Channel{
+		{ChanID: scid(800000), Active: true, Lifetime: 3600}, // funded ~70 days ago, node restarted an hour ago
+		{ChanID: scid(809990), Active: true, Lifetime: 3600}, // funded 10 blocks ago
+		{ChanID: "7", Active: true, Lifetime: 2 * 86400},     // no usable scid: lifetime says 2 days
+		{ChanID: "8", Active: true, Lifetime: 60 * 86400},    // lifetime says 60 days
+	}
+	ri := &fakeRouting{edges: map[string]ChannelEdge{}}
+	rep := ChannelHealthFor(context.Background(), ri, selfPub, channels, HealthOptions{Now: now, IdleDays: 30, BlockHeight: 810000})
+
+	want := []string{scid(800000), "8"}
+	if len(rep.CloseCandidates) != len(want) || rep.CloseCandidates[0] != want[0] || rep.CloseCandidates[1] != want[1] {
+		t.Errorf("close candidates = %v, want %v", rep.CloseCandidates, want)
+	}
+	if age := rep.Channels[0].AgeDays; age != 69 {
+		t.Errorf("age from scid = %d days, want 69", age)
+	}
+}
+
+func TestChannelHealthForPolicies(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	channels := []Channel{
+		{ChanID: "1", Active: true, Uptime: 100, Lifetime: 100},
+		{ChanID: "2", Active: false, Uptime: 50, Lifetime: 100},
+		{ChanID: "3", Active: true, Uptime: 50, Lifetime: 100},
+		{ChanID: "4", Active: true, Uptime: 100, Lifetime: 100},
+	}
+	stale := edge("3", 100, 100, now.AddDate(0, 0, -30))
+	disabled := edge("1", 1000, 100, now)
+	disabled.Node2Policy.Disabled = true
+	ri := &fakeRouting{edges: map[string]ChannelEdge{
+		"1": disabled,
+		"2": edge("2", 100, 200, now),
+		"3": stale,
+	}}
+
+	rep := ChannelHealthFor(context.Background(), ri, selfPub, channels, HealthOptions{Now: now, WindowDays: 7, IdleDays: 14})
+	if !ri.gotStart.Equal(now.AddDate(0, 0, -14)) {
+		t.Errorf("history start = %v, want the longer of window and idle days", ri.gotStart)
+	}
+	if rep.MedianFeePPM != 100 {
+		t.Errorf("median ppm = %d, want 100", rep.MedianFeePPM)
+	}
+
+	want := map[string][]string{
+		"1": {"disabled by peer", "fee 1000 ppm far from median 100 ppm"},
+		"2": {"peer offline"},
+		"3": {"peer uptime 50%", "peer policy stale", "our policy stale"},
+		"4": {"edge not in graph"},
+	}
+	for _, h := range rep.Channels {
+		for _, flag := range want[h.ChanID] {
+			found := false
+			for _, f := range h.Flags {
+				found = found || f == flag
+			}
+			if !found {
+				t.Errorf("chan %s flags %v, missing %q", h.ChanID, h.Flags, flag)
+			}
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package ln
 
 import (
-	"crypto/tls"
+	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -15,29 +16,90 @@ type LNDClient struct {
 	BaseURL string
 	MacHex  string
 	Client  *http.Client
+	TLS     TLSConfig
+	Mac     *Macaroon // nil when the macaroon could not be decoded
 }
 
-func NewLNDClient(baseURL, macaroonPath string, client *http.Client, tlsInsecure bool) (*LNDClient, error) {
+func NewLNDClient(baseURL, macaroonPath string, client *http.Client, tlsCfg TLSConfig) (*LNDClient, error) {
 	macBytes, err := os.ReadFile(macaroonPath)
 	if err != nil {
 		return nil, err
 	}
-	macHex := hex.EncodeToString(macBytes)
+	return NewLNDClientFromMacaroon(baseURL, macBytes, client, tlsCfg)
+}
 
+// NewLNDClientFromMacaroon is NewLNDClient for a macaroon already in memory,
+// e.g. from an lndconnect URI. A non-nil client is used as-is and tlsCfg only
+// informs SecurityWarnings.
+func NewLNDClientFromMacaroon(baseURL string, macBytes []byte, client *http.Client, tlsCfg TLSConfig) (*LNDClient, error) {
 	if client == nil {
-		tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsInsecure}} // dev only
-		client = &http.Client{Timeout: 10 * time.Second, Transport: tr}
+		tc, err := tlsCfg.Build()
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{TLSClientConfig: tc}}
+	}
+
+	c := &LNDClient{BaseURL: baseURL, MacHex: hex.EncodeToString(macBytes), Client: client, TLS: tlsCfg}
+	if m, err := DecodeMacaroon(macBytes); err == nil {
+		c.Mac = &m
 	}
+	return c, nil
+}
+
+// WithHTTPClient returns a copy of c whose requests go through
+// wrap(c.Client), e.g. to record them for one report.
+func (c *LNDClient) WithHTTPClient(wrap func(*http.Client) *http.Client) *LNDClient {
+	cc := *c
+	cc.Client = wrap(c.Client)
+	return &cc
+}
+
+func (c *LNDClient) Name() string { return "lnd" }
+
+func (c *LNDClient) Credential() *Credential {
+	if c.Mac == nil {
+		return &Credential{Type: "macaroon", Kind: "unknown"}
+	}
+	return c.Mac.Credential()
+}
+
+func (c *LNDClient) SecurityWarnings() []Warning {
+	warnings := tlsWarnings("LND", c.TLS)
+	if c.Mac == nil {
+		return append(warnings, Warning{
+			Severity: SeverityLow,
+			Message:  "Could not decode the LND macaroon; its permissions are unknown.",
+		})
+	}
+	return append(warnings, c.Mac.Warnings()...)
+}
 
-	return &LNDClient{BaseURL: baseURL, MacHex: macHex, Client: client}, nil
+func (c *LNDClient) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, "GET", path, nil, out)
 }
 
-func (c *LNDClient) get(path string, out interface{}) error {
-	req, err := http.NewRequest("GET", c.BaseURL+path, nil)
+func (c *LNDClient) post(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, "POST", path, body, out)
+}
+
+func (c *LNDClient) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var rdr io.Reader
+	if body != nil {
+		rdr = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rdr)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Grpc-Metadata-macaroon", c.MacHex)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -56,6 +118,7 @@ type GetInfoResponse struct {
 	IdentityPubkey    string `json:"identity_pubkey"`
 	Alias             string `json:"alias"`
 	BlockHeight       int    `json:"block_height"`
+	BlockHash         string `json:"block_hash"`
 	Version           string `json:"version"`
 	NumActiveChannels int    `json:"num_active_channels"`
 	NumPeers          int    `json:"num_peers"`
@@ -63,17 +126,42 @@ type GetInfoResponse struct {
 	SyncedToGraph     bool   `json:"synced_to_graph"`
 }
 
-func (c *LNDClient) GetInfo() (GetInfoResponse, error) {
+func (c *LNDClient) GetInfo(ctx context.Context) (GetInfoResponse, error) {
 	var res GetInfoResponse
-	err := c.get("/v1/getinfo", &res)
+	err := c.get(ctx, "/v1/getinfo", &res)
 	return res, err
 }
 
 type Readiness struct {
-	Ready   bool            `json:"ready"`
-	Score   int             `json:"score"`
-	Reasons []string        `json:"reasons"`
-	Info    GetInfoResponse `json:"info"`
+	Backend    string          `json:"backend,omitempty"`
+	Ready      bool            `json:"ready"`
+	Score      int             `json:"score"`
+	Reasons    []string        `json:"reasons"`
+	Warnings   []Warning       `json:"warnings,omitempty"`
+	Credential *Credential     `json:"credential,omitempty"`
+	Payment    *PaymentProbe   `json:"payment,omitempty"`
+	Info       GetInfoResponse `json:"info"`
+}
+
+// Penalize records an additional readiness problem found by a later check and
+// lowers the score, keeping it within 0..100.
+func (r *Readiness) Penalize(points int, reason string) {
+	r.Score -= points
+	if r.Score < 0 {
+		r.Score = 0
+	}
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// Warn records a graded warning; high and medium severities also cost score.
+func (r *Readiness) Warn(w Warning) {
+	r.Warnings = append(r.Warnings, w)
+	switch w.Severity {
+	case SeverityHigh:
+		r.Penalize(20, w.Message)
+	case SeverityMedium:
+		r.Penalize(10, w.Message)
+	}
 }
 
 func ComputeReadiness(info GetInfoResponse) Readiness {
@@ -0,0 +1,264 @@
+package ln
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type MacaroonPermission struct {
+	Entity string `json:"entity"`
+	Action string `json:"action"`
+}
+
+func (p MacaroonPermission) String() string { return p.Entity + ":" + p.Action }
+
+// RequiredPermissions is the minimal permission set for every LND call this
+// tool makes. Keep it in sync when adding LND endpoints.
+var RequiredPermissions = []MacaroonPermission{
+	{Entity: "info", Action: "read"},     // /v1/getinfo, /v1/graph/routes, /v1/graph/edge
+	{Entity: "offchain", Action: "read"}, // /v1/channels, /v1/channels/backup, /v2/watchtower/client, /v2/router/route/estimatefee, /v1/fees, /v1/switch
+	{Entity: "onchain", Action: "read"},  // /v1/balance/blockchain, /v2/wallet/utxos
+}
+
+// BakeMacaroonCommand returns the lncli invocation that bakes a macaroon with
+// exactly RequiredPermissions.
+func BakeMacaroonCommand() string {
+	perms := make([]string, 0, len(RequiredPermissions))
+	for _, p := range RequiredPermissions {
+		perms = append(perms, p.String())
+	}
+	return "lncli bakemacaroon --save_to=sovereign-checker.macaroon " + strings.Join(perms, " ")
+}
+
+type Credential struct {
+	Type        string   `json:"type"`           // "macaroon", "rune" or "unix-socket"
+	Kind        string   `json:"kind,omitempty"` // "admin", "invoice", "readonly" or "custom"
+	Permissions []string `json:"permissions,omitempty"`
+	Caveats     []string `json:"caveats,omitempty"`
+}
+
+type Macaroon struct {
+	Location    string
+	Permissions []MacaroonPermission
+	Caveats     []string
+}
+
+// DecodeMacaroon parses a binary v2 macaroon and LND's protobuf identifier
+// to recover its permissions and first-party caveats. The signature is not
+// checked; only the node can do that.
+func DecodeMacaroon(b []byte) (Macaroon, error) {
+	if len(b) == 0 || b[0] != 2 {
+		return Macaroon{}, errors.New("macaroon: only binary v2 format is supported")
+	}
+	r := &macReader{b: b[1:]}
+
+	var m Macaroon
+	var id []byte
+	for {
+		typ, data, err := r.field()
+		if err != nil {
+			return Macaroon{}, err
+		}
+		if typ == 0 {
+			break
+		}
+		switch typ {
+		case 1:
+			m.Location = string(data)
+		case 2:
+			id = data
+		}
+	}
+
+	for {
+		typ, data, err := r.field()
+		if err != nil {
+			return Macaroon{}, err
+		}
+		if typ == 0 {
+			break // end of caveats
+		}
+		var cid []byte
+		for typ != 0 {
+			if typ == 2 {
+				cid = data
+			}
+			if typ, data, err = r.field(); err != nil {
+				return Macaroon{}, err
+			}
+		}
+		m.Caveats = append(m.Caveats, string(cid))
+	}
+	// A file cut off after the caveats would otherwise decode cleanly
+	if typ, sig, err := r.field(); err != nil || typ != 6 || len(sig) != 32 {
+		return Macaroon{}, errors.New("macaroon: missing signature")
+	}
+
+	perms, err := decodeLNDMacaroonID(id)
+	if err != nil {
+		return Macaroon{}, err
+	}
+	if len(perms) == 0 {
+		return Macaroon{}, errors.New("macaroon: identifier grants no permissions")
+	}
+	m.Permissions = perms
+	return m, nil
+}
+
+type macReader struct {
+	b []byte
+}
+
+func (r *macReader) field() (byte, []byte, error) {
+	if len(r.b) == 0 {
+		return 0, nil, errors.New("macaroon: truncated")
+	}
+	typ := r.b[0]
+	r.b = r.b[1:]
+	if typ == 0 {
+		return 0, nil, nil
+	}
+	n, k := binary.Uvarint(r.b)
+	if k <= 0 || uint64(len(r.b)-k) < n {
+		return 0, nil, errors.New("macaroon: bad field length")
+	}
+	data := r.b[k : k+int(n)]
+	r.b = r.b[k+int(n):]
+	return typ, data, nil
+}
+
+// decodeLNDMacaroonID reads LND's identifier: a version byte followed by a
+// protobuf MacaroonId{nonce=1, storageId=2, ops=3 repeated Op{entity=1, actions=2}}.
+func decodeLNDMacaroonID(id []byte) ([]MacaroonPermission, error) {
+	if len(id) < 1 || id[0] != 3 {
+		return nil, errors.New("macaroon: not an LND identifier")
+	}
+	var perms []MacaroonPermission
+	err := protoEach(id[1:], func(field uint64, op []byte) error {
+		if field != 3 {
+			return nil
+		}
+		var entity string
+		var actions []string
+		err := protoEach(op, func(f uint64, v []byte) error {
+			switch f {
+			case 1:
+				entity = string(v)
+			case 2:
+				actions = append(actions, string(v))
+			}
+			return nil
+		})
+		for _, a := range actions {
+			perms = append(perms, MacaroonPermission{Entity: entity, Action: a})
+		}
+		return err
+	})
+	return perms, err
+}
+
+// protoEach walks the length-delimited fields of a protobuf message.
+func protoEach(b []byte, fn func(field uint64, v []byte) error) error {
+	for len(b) > 0 {
+		tag, k := binary.Uvarint(b)
+		if k <= 0 {
+			return errors.New("macaroon: bad protobuf tag")
+		}
+		b = b[k:]
+		if tag&7 != 2 {
+			return fmt.Errorf("macaroon: unexpected protobuf wire type %d", tag&7)
+		}
+		n, k := binary.Uvarint(b)
+		if k <= 0 || uint64(len(b)-k) < n {
+			return errors.New("macaroon: bad protobuf length")
+		}
+		if err := fn(tag>>3, b[k:k+int(n)]); err != nil {
+			return err
+		}
+		b = b[k+int(n):]
+	}
+	return nil
+}
+
+func (m Macaroon) has(entity, action string) bool {
+	for _, p := range m.Permissions {
+		if p.Entity == entity && p.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind classifies the macaroon the way lnd names its default macaroons.
+func (m Macaroon) Kind() string {
+	writes := 0
+	for _, p := range m.Permissions {
+		if p.Action != "read" {
+			writes++
+		}
+	}
+	switch {
+	case writes == 0:
+		return "readonly"
+	case m.has("macaroon", "generate") || m.has("offchain", "write") || m.has("onchain", "write"):
+		return "admin"
+	case m.has("invoices", "write"):
+		return "invoice"
+	default:
+		return "custom"
+	}
+}
+
+func (m Macaroon) Credential() *Credential {
+	perms := make([]string, 0, len(m.Permissions))
+	for _, p := range m.Permissions {
+		perms = append(perms, p.String())
+	}
+	sort.Strings(perms)
+	return &Credential{Type: "macaroon", Kind: m.Kind(), Permissions: perms, Caveats: m.Caveats}
+}
+
+// Warnings flags macaroons broader than RequiredPermissions and read
+// permissions the checks need but cannot use.
+func (m Macaroon) Warnings() []Warning {
+	var out []Warning
+
+	var writes []string
+	for _, p := range m.Permissions {
+		if p.Action != "read" {
+			writes = append(writes, p.String())
+		}
+	}
+	sort.Strings(writes)
+
+	switch kind := m.Kind(); kind {
+	case "admin", "invoice":
+		out = append(out, Warning{
+			Severity: SeverityHigh,
+			Message: fmt.Sprintf("An %s macaroon is used for read-only checks (grants %s); bake a least-privilege one with: %s",
+				kind, strings.Join(writes, ", "), BakeMacaroonCommand()),
+		})
+	case "custom":
+		out = append(out, Warning{
+			Severity: SeverityMedium,
+			Message:  "Macaroon grants write permissions this tool does not need: " + strings.Join(writes, ", "),
+		})
+	}
+
+	var missing []string
+	for _, p := range RequiredPermissions {
+		if !m.has(p.Entity, p.Action) {
+			missing = append(missing, p.String())
+		}
+	}
+	if len(missing) > 0 {
+		out = append(out, Warning{
+			Severity: SeverityLow,
+			Message:  "Macaroon lacks " + strings.Join(missing, ", ") + "; some checks will fail.",
+		})
+	}
+	return out
+}
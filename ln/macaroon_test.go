@@ -0,0 +1,108 @@
+package ln
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Binary v2 macaroons in lnd's format: location "lnd", a version 3
+// identifier with a 16-byte nonce, storage id "0" and lnd's default
+// admin, readonly and invoice permission sets, signed with a test root key.
+const (
+	adminMacaroonHex           = "0201036c6e6402f801030a10010101010101010101010101010101011201301a160a0761646472657373120472656164120577726974651a130a04696e666f120472656164120577726974651a170a08696e766f69636573120472656164120577726974651a210a086d616361726f6f6e120867656e6572617465120472656164120577726974651a160a076d657373616765120472656164120577726974651a170a086f6666636861696e120472656164120577726974651a160a076f6e636861696e120472656164120577726974651a140a057065657273120472656164120577726974651a180a067369676e6572120867656e657261746512047265616400000620d4db4d336094140c84a70eff95de1d5cbab1f53113d78e0ca18a1424213cd807"
+	readonlyMacaroonHex        = "0201036c6e6402ac01030a10020202020202020202020202020202021201301a0f0a07616464726573731204726561641a0c0a04696e666f1204726561641a100a08696e766f696365731204726561641a100a086d616361726f6f6e1204726561641a0f0a076d6573736167651204726561641a100a086f6666636861696e1204726561641a0f0a076f6e636861696e1204726561641a0d0a0570656572731204726561641a0e0a067369676e6572120472656164000006205b4a91b050d16689a98517b7374158ea32a860c05cf3a2cb9d551c3329b70af3"
+	invoiceMacaroonHex         = "0201036c6e640258030a10030303030303030303030303030303031201301a160a0761646472657373120472656164120577726974651a170a08696e766f69636573120472656164120577726974651a0f0a076f6e636861696e12047265616400000620b8ccaa3263b0215b9a5701f4b1eb888c5bcfb82da6cb249469ac55a2ed8dca38"
+	readonlyTimeoutMacaroonHex = "0201036c6e6402ac01030a10040404040404040404040404040404041201301a0f0a07616464726573731204726561641a0c0a04696e666f1204726561641a100a08696e766f696365731204726561641a100a086d616361726f6f6e1204726561641a0f0a076d6573736167651204726561641a100a086f6666636861696e1204726561641a0f0a076f6e636861696e1204726561641a0d0a0570656572731204726561641a0e0a067369676e657212047265616400021d6c6e642d637573746f6d2074696d656f7574203138393334353630303000000620d9344ebf296dd09c0b5878ae09ef14f3a3e07cd3b53837d89915c718293b9da6"
+	bakedMacaroonHex           = "0201036c6e640247030a10050505050505050505050505050505051201301a0c0a04696e666f1204726561641a100a086f6666636861696e1204726561641a0f0a076f6e636861696e12047265616400000620442114f541fac302fd783a419952a51e779dc02278486c7f7ae45bd1c8b53eea"
+	noOpsMacaroonHex           = "0201036c6e640216030a10060606060606060606060606060606061201300000062051d317c4011c7c73a0b4bc173748f91bc5a147bd2270dd8f68987bb3a600da5e"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeMacaroon(t *testing.T) {
+	tests := []struct {
+		name      string
+		hex       string
+		kind      string
+		perms     int
+		caveats   []string
+		severity  []string // warning severities, in order
+		mentioned string   // text the first warning must contain
+	}{
+		{"admin", adminMacaroonHex, "admin", 19, nil, []string{SeverityHigh}, "macaroon:generate"},
+		{"readonly", readonlyMacaroonHex, "readonly", 9, nil, nil, ""},
+		{"invoice", invoiceMacaroonHex, "invoice", 5, nil, []string{SeverityHigh, SeverityLow}, "invoices:write"},
+		{"readonly with timeout caveat", readonlyTimeoutMacaroonHex, "readonly", 9, []string{"lnd-custom timeout 1893456000"}, nil, ""},
+		{"baked least privilege", bakedMacaroonHex, "readonly", 3, nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DecodeMacaroon(mustHex(t, tt.hex))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Location != "lnd" {
+				t.Errorf("location = %q", m.Location)
+			}
+			if got := m.Kind(); got != tt.kind {
+				t.Errorf("kind = %s, want %s", got, tt.kind)
+			}
+			if len(m.Permissions) != tt.perms {
+				t.Errorf("%d permissions, want %d: %v", len(m.Permissions), tt.perms, m.Permissions)
+			}
+			if strings.Join(m.Caveats, "|") != strings.Join(tt.caveats, "|") {
+				t.Errorf("caveats = %q, want %q", m.Caveats, tt.caveats)
+			}
+			ws := m.Warnings()
+			if len(ws) != len(tt.severity) {
+				t.Fatalf("warnings = %+v, want severities %v", ws, tt.severity)
+			}
+			for i, w := range ws {
+				if w.Severity != tt.severity[i] {
+					t.Errorf("warning %d severity = %s, want %s", i, w.Severity, tt.severity[i])
+				}
+			}
+			if tt.mentioned != "" && !strings.Contains(ws[0].Message, tt.mentioned) {
+				t.Errorf("warning %q does not mention %s", ws[0].Message, tt.mentioned)
+			}
+			if c := m.Credential(); c.Kind != tt.kind || len(c.Permissions) != tt.perms {
+				t.Errorf("credential = %+v", c)
+			}
+		})
+	}
+}
+
+func TestDecodeMacaroonRejects(t *testing.T) {
+	admin, _ := hex.DecodeString(adminMacaroonHex)
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("not a macaroon")},
+		{"hex text instead of bytes", []byte(adminMacaroonHex)},
+		{"v1 base64", []byte("MDAxY2xvY2F0aW9uIGxuZAo=")},
+		{"truncated identifier", admin[:40]},
+		{"no signature", admin[:len(admin)-34]},
+		{"short signature", admin[:len(admin)-1]},
+		{"no permissions", mustHex(t, noOpsMacaroonHex)},
+		{"not an lnd identifier", []byte{2, 2, 3, 'a', 'b', 'c', 0, 0, 6, 32}},
+		{"overlong length", []byte{2, 2, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DecodeMacaroon(tt.b)
+			if err == nil {
+				t.Errorf("decoded as %s with %v", m.Kind(), m.Permissions)
+			}
+		})
+	}
+}
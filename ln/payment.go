@@ -0,0 +1,199 @@
+package ln
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PaymentProber is implemented by backends that can estimate a route to a
+// destination (currently LND).
+type PaymentProber interface {
+	EstimateRoute(ctx context.Context, inv Invoice, payReq string, amountSats uint64) (RouteEstimate, error)
+}
+
+var _ PaymentProber = (*LNDClient)(nil)
+
+type RouteHop struct {
+	ChanID       string `json:"chan_id"`
+	CapacitySats int64  `json:"chan_capacity,string"`
+	PubKey       string `json:"pub_key"`
+	Expiry       uint32 `json:"expiry"`
+}
+
+type RouteEstimate struct {
+	Source        string     `json:"source"` // "queryroutes" or "estimatefee"
+	Found         bool       `json:"found"`
+	FeeMsat       uint64     `json:"fee_msat"`
+	TotalTimeLock uint32     `json:"total_time_lock,omitempty"`
+	Hops          []RouteHop `json:"hops,omitempty"`
+	SuccessProb   float64    `json:"success_prob,omitempty"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+}
+
+type queryRoutesResponse struct {
+	Routes []struct {
+		TotalTimeLock uint32     `json:"total_time_lock"`
+		TotalFeesMsat int64      `json:"total_fees_msat,string"`
+		Hops          []RouteHop `json:"hops"`
+	} `json:"routes"`
+	SuccessProb float64 `json:"success_prob"`
+}
+
+type estimateFeeRequest struct {
+	Dest           string `json:"dest,omitempty"` // base64 pubkey
+	AmtSat         int64  `json:"amt_sat,string,omitempty"`
+	PaymentRequest string `json:"payment_request,omitempty"`
+}
+
+type estimateFeeResponse struct {
+	RoutingFeeMsat int64  `json:"routing_fee_msat,string"`
+	TimeLockDelay  int64  `json:"time_lock_delay,string"`
+	FailureReason  string `json:"failure_reason"`
+}
+
+// EstimateRoute asks QueryRoutes for a route to the payee. Invoices with
+// route hints (private payees) go through EstimateRouteFee with the full
+// payment request instead. Note that lnd answers that by sending a probe
+// payment: an HTLC with a random hash that the payee rejects. No funds move,
+// but the probe briefly locks liquidity along the route and is visible to
+// every hop on it.
+func (c *LNDClient) EstimateRoute(ctx context.Context, inv Invoice, payReq string, amountSats uint64) (RouteEstimate, error) {
+	if len(inv.RouteHints) == 0 {
+		var qr queryRoutesResponse
+		path := fmt.Sprintf("/v1/graph/routes/%s/%d?final_cltv_delta=%d&use_mission_control=true",
+			url.PathEscape(inv.Payee), amountSats, inv.MinFinalCLTV)
+		if err := c.get(ctx, path, &qr); err == nil && len(qr.Routes) > 0 {
+			r := qr.Routes[0]
+			return RouteEstimate{
+				Source:        "queryroutes",
+				Found:         true,
+				FeeMsat:       positive(r.TotalFeesMsat),
+				TotalTimeLock: r.TotalTimeLock,
+				Hops:          r.Hops,
+				SuccessProb:   qr.SuccessProb,
+			}, nil
+		}
+	}
+
+	req := estimateFeeRequest{}
+	if inv.AmountMsat > 0 {
+		req.PaymentRequest = payReq
+	} else {
+		pub, err := hex.DecodeString(inv.Payee)
+		if err != nil {
+			return RouteEstimate{}, err
+		}
+		req.Dest = base64.StdEncoding.EncodeToString(pub)
+		req.AmtSat = int64(amountSats)
+	}
+	var res estimateFeeResponse
+	if err := c.post(ctx, "/v2/router/route/estimatefee", req, &res); err != nil {
+		return RouteEstimate{}, err
+	}
+	found := res.FailureReason == "" || res.FailureReason == "FAILURE_REASON_NONE"
+	est := RouteEstimate{Source: "estimatefee", Found: found, FailureReason: res.FailureReason}
+	if found {
+		est.FeeMsat = positive(res.RoutingFeeMsat)
+		est.TotalTimeLock = uint32(res.TimeLockDelay)
+	}
+	return est, nil
+}
+
+// PaymentProbe keeps the two limits on a payment apart. Local: what our
+// channels can send, in total (with MPP) and over one channel (a single
+// path). Remote: the smallest channel capacity past our first hop on the
+// estimated route; capacity bounds the liquidity there but is not it.
+type PaymentProbe struct {
+	Invoice            Invoice        `json:"invoice"`
+	AmountSats         uint64         `json:"amount_sats"`
+	Expired            bool           `json:"expired"`
+	ExpiresAt          time.Time      `json:"expires_at"`
+	Payable            bool           `json:"payable"`
+	Route              *RouteEstimate `json:"route,omitempty"`
+	FeeEstimateSats    uint64         `json:"fee_estimate_sats"`
+	TotalOutboundSats  uint64         `json:"total_outbound_sats"`
+	MaxChannelOutbound uint64         `json:"max_channel_outbound_sats"`
+	MaxOutboundChannel string         `json:"max_outbound_channel,omitempty"`
+	// Smallest remote hop on the route, by capacity
+	RemoteHopChannel      string   `json:"remote_hop_channel,omitempty"`
+	RemoteHopCapacitySats uint64   `json:"remote_hop_capacity_sats,omitempty"`
+	Reasons               []string `json:"reasons"`
+}
+
+// ChannelOutbound is what an active channel can send after its local reserve.
+func ChannelOutbound(ch Channel) uint64 {
+	if !ch.Active {
+		return 0
+	}
+	return positive(ch.LocalBalanceSats - ch.LocalChanReserve)
+}
+
+// ComputePaymentCapability decides whether channels can pay inv for
+// amountSats given an optional route estimate from the backend.
+func ComputePaymentCapability(inv Invoice, amountSats uint64, channels []Channel, route *RouteEstimate, now time.Time) PaymentProbe {
+	p := PaymentProbe{
+		Invoice:    inv,
+		AmountSats: amountSats,
+		ExpiresAt:  inv.ExpiresAt(),
+		Route:      route,
+		Reasons:    []string{},
+	}
+	p.Expired = now.After(p.ExpiresAt)
+	if p.Expired {
+		p.Reasons = append(p.Reasons, "Invoice has expired.")
+	}
+	if amountSats == 0 {
+		p.Reasons = append(p.Reasons, "Invoice has no amount; pass one to probe.")
+	}
+
+	for _, ch := range channels {
+		out := ChannelOutbound(ch)
+		p.TotalOutboundSats += out
+		if out > p.MaxChannelOutbound {
+			p.MaxChannelOutbound = out
+			p.MaxOutboundChannel = ch.ChanID
+		}
+	}
+
+	fits := true
+	switch {
+	case len(channels) == 0:
+		fits = false
+		p.Reasons = append(p.Reasons, "No channels to pay from.")
+	case amountSats > p.TotalOutboundSats:
+		fits = false
+		p.Reasons = append(p.Reasons, fmt.Sprintf("Amount %d sats exceeds total outbound liquidity %d sats.", amountSats, p.TotalOutboundSats))
+	case amountSats > p.MaxChannelOutbound:
+		p.Reasons = append(p.Reasons, fmt.Sprintf("Amount exceeds the largest single channel (%d sats); payment needs MPP.", p.MaxChannelOutbound))
+	}
+
+	if route != nil {
+		p.FeeEstimateSats = (route.FeeMsat + 999) / 1000
+		if !route.Found {
+			p.Reasons = append(p.Reasons, "No route found: "+route.FailureReason)
+		}
+		// The first hop is our own channel, already counted above
+		for i, h := range route.Hops {
+			if i == 0 {
+				continue
+			}
+			if p.RemoteHopChannel == "" || positive(h.CapacitySats) < p.RemoteHopCapacitySats {
+				p.RemoteHopChannel = h.ChanID
+				p.RemoteHopCapacitySats = positive(h.CapacitySats)
+			}
+		}
+		if p.RemoteHopChannel != "" && amountSats > p.RemoteHopCapacitySats {
+			p.Reasons = append(p.Reasons, fmt.Sprintf("Amount exceeds the %d sat capacity of remote channel %s on the route.",
+				p.RemoteHopCapacitySats, p.RemoteHopChannel))
+		}
+	} else {
+		p.Reasons = append(p.Reasons, "Backend cannot estimate routes; judged on local liquidity only.")
+	}
+
+	p.Payable = !p.Expired && amountSats > 0 && fits && (route == nil || route.Found)
+	return p
+}
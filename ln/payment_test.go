@@ -0,0 +1,211 @@
+package ln
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	specCoffee    = "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp"
+	specRouteHint = "lnbc20m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqsfpp3qjmp7lwpagxun9pygexvgpjdc4jdj85frzjq20q82gphp2nflc7jtzrcazrra7wwgzxqc8u7754cdlpfrmccae92qgzqvzq2ps8pqqqqqqqqqqqq9qqqvncsk57n4v9ehw86wq8fzvjejhv9z3w3q5zh6qkql005x9xl240ch23jk79ujzvr4hsmmafyxghpqe79psktnjl668ntaf4ne7ucs5csqh5mnnk"
+)
+
+// fakeLND serves the REST calls the payment probe makes.
+type fakeLND struct {
+	routes      string // /v1/graph/routes response body
+	estimate    string // /v2/router/route/estimatefee response body
+	gotEstimate estimateFeeRequest
+	paths       []string
+}
+
+func (f *fakeLND) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Grpc-Metadata-macaroon") != "0102" {
+		http.Error(w, `{"message":"verification failed"}`, http.StatusUnauthorized)
+		return
+	}
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	switch {
+	case r.URL.Path == "/v1/channels":
+		w.Write([]byte(`{"channels":[
+			{"active":true,"chan_id":"100","capacity":"1000000","local_balance":"400000","local_chan_reserve_sat":"10000"},
+			{"active":true,"chan_id":"200","capacity":"500000","local_balance":"150000","local_chan_reserve_sat":"5000"},
+			{"active":false,"chan_id":"300","capacity":"900000","local_balance":"800000"}]}`))
+	case r.URL.Path == "/v1/graph/node/03peer" && r.URL.Query().Get("include_channels") == "true":
+		w.Write([]byte(`{"channels":[{"node1_pub":"03peer","node2_pub":"03far"},{"node1_pub":"02self","node2_pub":"03peer"}]}`))
+	case strings.HasPrefix(r.URL.Path, "/v1/graph/routes/"):
+		if f.routes == "" {
+			http.Error(w, `{"message":"unable to find a path to destination"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(f.routes))
+	case r.URL.Path == "/v2/router/route/estimatefee" && r.Method == "POST":
+		if err := json.NewDecoder(r.Body).Decode(&f.gotEstimate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(f.estimate))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func probe(t *testing.T, f *fakeLND, payReq string) PaymentProbe {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c, err := NewLNDClientFromMacaroon(srv.URL, []byte{1, 2}, srv.Client(), TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := DecodeInvoice(payReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	channels, err := c.ListChannels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := (inv.AmountMsat + 999) / 1000
+	est, err := c.EstimateRoute(ctx, inv, payReq, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ComputePaymentCapability(inv, amount, channels, &est, inv.Timestamp)
+}
+
+func TestPaymentProbeQueryRoutes(t *testing.T) {
+	f := &fakeLND{routes: `{"routes":[{"total_time_lock":800100,"total_fees_msat":"1500",
+		"hops":[{"chan_id":"200","chan_capacity":"500000","pub_key":"03aa"},{"chan_id":"900","chan_capacity":"300000","pub_key":"03e7"}]}],
+		"success_prob":0.8}`}
+	p := probe(t, f, specCoffee)
+
+	if !p.Payable || p.Expired {
+		t.Fatalf("payable=%v expired=%v reasons=%v", p.Payable, p.Expired, p.Reasons)
+	}
+	if p.AmountSats != 250_000 {
+		t.Errorf("amount = %d sats, want 250000", p.AmountSats)
+	}
+	if p.Route.Source != "queryroutes" || p.FeeEstimateSats != 2 || p.Route.TotalTimeLock != 800100 {
+		t.Errorf("route = %+v, fee %d sats", p.Route, p.FeeEstimateSats)
+	}
+	if p.RemoteHopChannel != "900" || p.RemoteHopCapacitySats != 300_000 {
+		t.Errorf("remote hop = %s (%d sats), want 900 (300000)", p.RemoteHopChannel, p.RemoteHopCapacitySats)
+	}
+	if p.TotalOutboundSats != 535_000 || p.MaxChannelOutbound != 390_000 || p.MaxOutboundChannel != "100" {
+		t.Errorf("outbound total/max = %d/%d (%s), want 535000/390000 (100)", p.TotalOutboundSats, p.MaxChannelOutbound, p.MaxOutboundChannel)
+	}
+	if want := mustDecode(t, specCoffee).Timestamp.Add(time.Minute); !p.ExpiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", p.ExpiresAt, want)
+	}
+	for _, path := range f.paths {
+		if strings.Contains(path, "estimatefee") {
+			t.Errorf("estimatefee called although queryroutes found a route")
+		}
+	}
+}
+
+func TestPaymentProbeEstimateFee(t *testing.T) {
+	t.Run("route hints use the payment request", func(t *testing.T) {
+		f := &fakeLND{estimate: `{"routing_fee_msat":"2000","time_lock_delay":"144","failure_reason":"FAILURE_REASON_NONE"}`}
+		p := probe(t, f, specRouteHint)
+		if f.gotEstimate.PaymentRequest != specRouteHint || f.gotEstimate.Dest != "" {
+			t.Errorf("estimatefee request = %+v, want the payment request", f.gotEstimate)
+		}
+		if p.Route.Source != "estimatefee" || !p.Route.Found || p.FeeEstimateSats != 2 {
+			t.Errorf("route = %+v", p.Route)
+		}
+		// 2,000,000 sats is more than the channels hold.
+		if p.Payable || !strings.Contains(strings.Join(p.Reasons, " "), "exceeds total outbound") {
+			t.Errorf("payable=%v reasons=%v", p.Payable, p.Reasons)
+		}
+	})
+
+	t.Run("no route from queryroutes falls back and reports failure", func(t *testing.T) {
+		f := &fakeLND{estimate: `{"failure_reason":"FAILURE_REASON_NO_ROUTE"}`}
+		p := probe(t, f, specCoffee)
+		if p.Route.Source != "estimatefee" || p.Route.Found || p.Route.FailureReason != "FAILURE_REASON_NO_ROUTE" {
+			t.Errorf("route = %+v", p.Route)
+		}
+		if p.Payable {
+			t.Errorf("payable without a route")
+		}
+	})
+}
+
+func TestLNDNodeNeighbors(t *testing.T) {
+	srv := httptest.NewServer(&fakeLND{})
+	defer srv.Close()
+	c, _ := NewLNDClientFromMacaroon(srv.URL, []byte{1, 2}, srv.Client(), TLSConfig{})
+	got, err := c.NodeNeighbors(context.Background(), "03peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "03far" || got[1] != "02self" {
+		t.Errorf("neighbors = %v, want [03far 02self]", got)
+	}
+}
+
+func TestLNDClientHTTPError(t *testing.T) {
+	srv := httptest.NewServer(&fakeLND{})
+	defer srv.Close()
+	c, _ := NewLNDClientFromMacaroon(srv.URL, []byte{9}, srv.Client(), TLSConfig{})
+	_, err := c.ListChannels(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "lnd http 401") {
+		t.Errorf("got %v, want lnd http 401", err)
+	}
+}
+
+func mustDecode(t *testing.T, payReq string) Invoice {
+	t.Helper()
+	i, err := DecodeInvoice(payReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func TestComputePaymentCapabilityLimits(t *testing.T) {
+	inv := mustDecode(t, specCoffee)
+	channels := []Channel{
+		{ChanID: "a", Active: true, LocalBalanceSats: 200_000, LocalChanReserve: 10_000},
+		{ChanID: "b", Active: true, LocalBalanceSats: 150_000},
+	}
+	hop := func(id string, capacity int64) RouteHop { return RouteHop{ChanID: id, CapacitySats: capacity} }
+	tests := []struct {
+		name       string
+		amount     uint64
+		hops       []RouteHop
+		payable    bool
+		remoteChan string
+		reason     string
+	}{
+		{"direct peer has no remote hop", 100_000, []RouteHop{hop("a", 5_000)}, true, "", ""},
+		{"needs MPP over local channels", 300_000, []RouteHop{hop("a", 1_000_000), hop("r1", 2_000_000)}, true, "r1", "needs MPP"},
+		{"above local total", 400_000, []RouteHop{hop("a", 1_000_000)}, false, "", "exceeds total outbound"},
+		{"smallest remote capacity", 100_000, []RouteHop{hop("a", 1_000_000), hop("r1", 80_000), hop("r2", 500_000)}, true, "r1", "capacity of remote channel r1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &RouteEstimate{Found: true, Hops: tt.hops}
+			p := ComputePaymentCapability(inv, tt.amount, channels, route, inv.Timestamp)
+			if p.Payable != tt.payable {
+				t.Errorf("payable = %v, reasons %v", p.Payable, p.Reasons)
+			}
+			if p.TotalOutboundSats != 340_000 || p.MaxChannelOutbound != 190_000 || p.MaxOutboundChannel != "a" {
+				t.Errorf("local limits = %d total, %d in %s", p.TotalOutboundSats, p.MaxChannelOutbound, p.MaxOutboundChannel)
+			}
+			if p.RemoteHopChannel != tt.remoteChan {
+				t.Errorf("remote hop = %q, want %q", p.RemoteHopChannel, tt.remoteChan)
+			}
+			if tt.reason != "" && !strings.Contains(strings.Join(p.Reasons, " "), tt.reason) {
+				t.Errorf("reasons %v lack %q", p.Reasons, tt.reason)
+			}
+		})
+	}
+}
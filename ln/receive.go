@@ -0,0 +1,130 @@
+package ln
+
+import "fmt"
+
+// defaultMaxAcceptedHTLCs is the BOLT 2 ceiling used when a backend does not
+// report the negotiated limit.
+const defaultMaxAcceptedHTLCs = 483
+
+type ReceiveReadiness struct {
+	Ready                bool     `json:"ready"`
+	Score                int      `json:"score"`
+	Reasons              []string `json:"reasons"`
+	ActiveChannels       int      `json:"active_channels"`
+	PublicChannels       int      `json:"public_channels"`
+	PrivateChannels      int      `json:"private_channels"`
+	NeedsRouteHints      bool     `json:"needs_route_hints"`
+	TotalInboundSats     uint64   `json:"total_inbound_sats"`
+	MaxChannelInbound    uint64   `json:"max_channel_inbound_sats"`
+	HTLCSlotsTotal       int      `json:"htlc_slots_total"`
+	HTLCSlotsFree        int      `json:"htlc_slots_free"`
+	AmountSats           uint64   `json:"amount_sats,omitempty"`
+	ReceivableSinglePath *bool    `json:"receivable_single_path,omitempty"`
+	ReceivableMPP        *bool    `json:"receivable_mpp,omitempty"`
+}
+
+// ChannelInbound is what the peer can still push to us over an active channel.
+func ChannelInbound(ch Channel) uint64 {
+	if !ch.Active {
+		return 0
+	}
+	return positive(ch.RemoteBalanceSats - ch.RemoteChanReserve)
+}
+
+// ComputeReceiveReadiness scores whether the node can be paid, optionally for
+// a specific amountSats (0 skips the amount check).
+func ComputeReceiveReadiness(info GetInfoResponse, channels []Channel, amountSats uint64) ReceiveReadiness {
+	rr := ReceiveReadiness{Reasons: []string{}, AmountSats: amountSats}
+	score := 50
+
+	for _, ch := range channels {
+		if !ch.Active {
+			continue
+		}
+		rr.ActiveChannels++
+		if ch.Private {
+			rr.PrivateChannels++
+		} else {
+			rr.PublicChannels++
+		}
+		in := ChannelInbound(ch)
+		rr.TotalInboundSats += in
+		if in > rr.MaxChannelInbound {
+			rr.MaxChannelInbound = in
+		}
+
+		slots := int(ch.LocalConstraints.MaxAcceptedHTLCs)
+		if slots == 0 {
+			slots = defaultMaxAcceptedHTLCs
+		}
+		used := 0
+		for _, h := range ch.PendingHTLCs {
+			if h.Incoming {
+				used++
+			}
+		}
+		rr.HTLCSlotsTotal += slots
+		if used < slots {
+			rr.HTLCSlotsFree += slots - used
+		}
+	}
+	rr.NeedsRouteHints = rr.ActiveChannels > 0 && rr.PublicChannels == 0
+
+	if info.SyncedToChain {
+		score += 10
+	} else {
+		score -= 20
+		rr.Reasons = append(rr.Reasons, "Node not synced to chain")
+	}
+
+	switch {
+	case rr.ActiveChannels == 0:
+		score -= 30
+		rr.Reasons = append(rr.Reasons, "No active channels to receive over")
+	case rr.TotalInboundSats == 0:
+		score -= 30
+		rr.Reasons = append(rr.Reasons, "No inbound liquidity; peers cannot push payments to this node")
+	default:
+		score += 20
+	}
+
+	if rr.NeedsRouteHints {
+		rr.Reasons = append(rr.Reasons, "Only private channels; invoices must carry route hints")
+	} else if rr.PublicChannels > 0 {
+		score += 10
+	}
+
+	switch {
+	case rr.ActiveChannels > 0 && rr.HTLCSlotsFree == 0:
+		score -= 20
+		rr.Reasons = append(rr.Reasons, "All incoming HTLC slots are in use")
+	case rr.ActiveChannels > 0:
+		score += 10
+	}
+
+	if amountSats > 0 {
+		single := amountSats <= rr.MaxChannelInbound
+		mpp := amountSats <= rr.TotalInboundSats
+		rr.ReceivableSinglePath = &single
+		rr.ReceivableMPP = &mpp
+		switch {
+		case !mpp:
+			score -= 20
+			rr.Reasons = append(rr.Reasons, fmt.Sprintf("Amount %d sats exceeds total inbound liquidity %d sats", amountSats, rr.TotalInboundSats))
+		case !single:
+			rr.Reasons = append(rr.Reasons, fmt.Sprintf("Amount exceeds the largest channel's inbound (%d sats); sender must support MPP", rr.MaxChannelInbound))
+		}
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	if score > 100 {
+		score = 100
+	}
+
+	rr.Score = score
+	rr.Ready = info.SyncedToChain && rr.TotalInboundSats > 0 && rr.HTLCSlotsFree > 0 &&
+		(rr.ReceivableMPP == nil || *rr.ReceivableMPP)
+	return rr
+}
@@ -0,0 +1,68 @@
+package ln
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeReceiveReadiness(t *testing.T) {
+	public := Channel{Active: true, RemoteBalanceSats: 300_000, RemoteChanReserve: 3_000}
+	full := Channel{Active: true, RemoteBalanceSats: 100_000, RemoteChanReserve: 1_000,
+		LocalConstraints: ChannelConstraints{MaxAcceptedHTLCs: 2},
+		PendingHTLCs:     []PendingHTLC{{Incoming: true}, {Incoming: true}, {Incoming: false}}}
+	offline := Channel{RemoteBalanceSats: 1_000_000}
+	private := Channel{Active: true, Private: true, RemoteBalanceSats: 50_000}
+	drained := Channel{Active: true, RemoteBalanceSats: 1_000, RemoteChanReserve: 1_000}
+	synced := GetInfoResponse{SyncedToChain: true}
+
+	tests := []struct {
+		name       string
+		info       GetInfoResponse
+		channels   []Channel
+		amount     uint64
+		score      int
+		ready      bool
+		inbound    uint64
+		maxInbound uint64
+		slotsFree  int
+		routeHints bool
+		reasons    []string
+	}{
+		{"healthy", synced, []Channel{public, full, offline}, 0, 100, true, 396_000, 297_000, 483, false, nil},
+		{"amount needs mpp", synced, []Channel{public, full}, 350_000, 100, true, 396_000, 297_000, 483, false,
+			[]string{"Amount exceeds the largest channel's inbound (297000 sats); sender must support MPP"}},
+		{"amount exceeds inbound", synced, []Channel{public, full}, 500_000, 80, false, 396_000, 297_000, 483, false,
+			[]string{"Amount 500000 sats exceeds total inbound liquidity 396000 sats"}},
+		{"unsynced private node", GetInfoResponse{}, []Channel{private}, 0, 60, false, 50_000, 50_000, 483, true,
+			[]string{"Node not synced to chain", "Only private channels; invoices must carry route hints"}},
+		{"no channels", synced, []Channel{offline}, 0, 30, false, 0, 0, 0, false,
+			[]string{"No active channels to receive over"}},
+		{"no inbound", synced, []Channel{drained}, 0, 50, false, 0, 0, 483, false,
+			[]string{"No inbound liquidity; peers cannot push payments to this node"}},
+		{"htlc slots exhausted", synced, []Channel{full}, 0, 70, false, 99_000, 99_000, 0, false,
+			[]string{"All incoming HTLC slots are in use"}},
+		{"score floors at zero", GetInfoResponse{}, nil, 1_000, 0, false, 0, 0, 0, false,
+			[]string{"Node not synced to chain", "No active channels to receive over", "Amount 1000 sats exceeds total inbound liquidity 0 sats"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := ComputeReceiveReadiness(tt.info, tt.channels, tt.amount)
+			if rr.Score != tt.score || rr.Ready != tt.ready {
+				t.Errorf("score %d ready %t, want %d %t", rr.Score, rr.Ready, tt.score, tt.ready)
+			}
+			if rr.TotalInboundSats != tt.inbound || rr.MaxChannelInbound != tt.maxInbound || rr.HTLCSlotsFree != tt.slotsFree {
+				t.Errorf("inbound %d max %d free slots %d, want %d %d %d",
+					rr.TotalInboundSats, rr.MaxChannelInbound, rr.HTLCSlotsFree, tt.inbound, tt.maxInbound, tt.slotsFree)
+			}
+			if rr.NeedsRouteHints != tt.routeHints {
+				t.Errorf("needs route hints = %t", rr.NeedsRouteHints)
+			}
+			if strings.Join(rr.Reasons, "|") != strings.Join(tt.reasons, "|") {
+				t.Errorf("reasons = %q, want %q", rr.Reasons, tt.reasons)
+			}
+			if (rr.ReceivableMPP != nil) != (tt.amount > 0) {
+				t.Errorf("receivable set = %v for amount %d", rr.ReceivableMPP, tt.amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package ln
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecoveryChecker is implemented by backends that expose channel backups and
+// a watchtower client (currently LND).
+type RecoveryChecker interface {
+	ChannelBackup(ctx context.Context) (ChannelBackup, error)
+	VerifyChannelBackup(ctx context.Context, multi []byte) ([]string, error)
+	Watchtowers(ctx context.Context) (WatchtowerStatus, error)
+}
+
+var _ RecoveryChecker = (*LNDClient)(nil)
+
+type chanPoint struct {
+	FundingTxIDBytes string `json:"funding_txid_bytes"`
+	FundingTxIDStr   string `json:"funding_txid_str"`
+	OutputIndex      uint32 `json:"output_index"`
+}
+
+// String renders txid:index; funding_txid_bytes is in reversed byte order.
+func (cp chanPoint) String() string {
+	txid := cp.FundingTxIDStr
+	if txid == "" {
+		b, _ := base64.StdEncoding.DecodeString(cp.FundingTxIDBytes)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		txid = hex.EncodeToString(b)
+	}
+	return fmt.Sprintf("%s:%d", txid, cp.OutputIndex)
+}
+
+type multiChanBackup struct {
+	ChanPoints      []chanPoint `json:"chan_points"`
+	MultiChanBackup string      `json:"multi_chan_backup"` // base64
+}
+
+type chanBackupSnapshot struct {
+	MultiChanBackup multiChanBackup `json:"multi_chan_backup"`
+}
+
+type ChannelBackup struct {
+	ChanPoints []string
+	Multi      []byte
+}
+
+func (c *LNDClient) ChannelBackup(ctx context.Context) (ChannelBackup, error) {
+	var res chanBackupSnapshot
+	if err := c.get(ctx, "/v1/channels/backup", &res); err != nil {
+		return ChannelBackup{}, err
+	}
+	multi, err := base64.StdEncoding.DecodeString(res.MultiChanBackup.MultiChanBackup)
+	if err != nil {
+		return ChannelBackup{}, fmt.Errorf("decode multi chan backup: %w", err)
+	}
+	out := ChannelBackup{Multi: multi}
+	for _, cp := range res.MultiChanBackup.ChanPoints {
+		out.ChanPoints = append(out.ChanPoints, cp.String())
+	}
+	return out, nil
+}
+
+// VerifyChannelBackup asks LND to decrypt and validate a multi-channel backup.
+// Newer LND versions also return the channel points it covers.
+func (c *LNDClient) VerifyChannelBackup(ctx context.Context, multi []byte) ([]string, error) {
+	req := chanBackupSnapshot{MultiChanBackup: multiChanBackup{
+		MultiChanBackup: base64.StdEncoding.EncodeToString(multi),
+	}}
+	var res struct {
+		ChanPoints []string `json:"chan_points"`
+	}
+	if err := c.post(ctx, "/v1/channels/backup/verify", req, &res); err != nil {
+		return nil, err
+	}
+	return res.ChanPoints, nil
+}
+
+type WatchtowerStatus struct {
+	Towers            int `json:"towers"`
+	ActiveTowers      int `json:"active_towers"`
+	Sessions          int `json:"sessions"`
+	Backups           int `json:"backups"`
+	PendingBackups    int `json:"pending_backups"`
+	FailedBackups     int `json:"failed_backups"`
+	SessionsExhausted int `json:"sessions_exhausted"`
+}
+
+type towerList struct {
+	Towers []struct {
+		ActiveSessionCandidate bool   `json:"active_session_candidate"`
+		NumSessions            uint32 `json:"num_sessions"`
+	} `json:"towers"`
+}
+
+type towerStats struct {
+	NumBackups           uint32 `json:"num_backups"`
+	NumPendingBackups    uint32 `json:"num_pending_backups"`
+	NumFailedBackups     uint32 `json:"num_failed_backups"`
+	NumSessionsAcquired  uint32 `json:"num_sessions_acquired"`
+	NumSessionsExhausted uint32 `json:"num_sessions_exhausted"`
+}
+
+func (c *LNDClient) Watchtowers(ctx context.Context) (WatchtowerStatus, error) {
+	var towers towerList
+	if err := c.get(ctx, "/v2/watchtower/client", &towers); err != nil {
+		return WatchtowerStatus{}, err
+	}
+	var stats towerStats
+	if err := c.get(ctx, "/v2/watchtower/client/stats", &stats); err != nil {
+		return WatchtowerStatus{}, err
+	}
+
+	st := WatchtowerStatus{
+		Towers:            len(towers.Towers),
+		Backups:           int(stats.NumBackups),
+		PendingBackups:    int(stats.NumPendingBackups),
+		FailedBackups:     int(stats.NumFailedBackups),
+		SessionsExhausted: int(stats.NumSessionsExhausted),
+	}
+	for _, t := range towers.Towers {
+		if t.ActiveSessionCandidate {
+			st.ActiveTowers++
+		}
+		st.Sessions += int(t.NumSessions)
+	}
+	return st, nil
+}
+
+type BackupFileStatus struct {
+	Path            string   `json:"path"`
+	SHA256          string   `json:"sha256"`
+	AgeSeconds      int64    `json:"age_seconds"`
+	MatchesNode     bool     `json:"matches_node"`
+	VerifiedByNode  bool     `json:"verified_by_node"`
+	MissingChannels []string `json:"missing_channels,omitempty"`
+}
+
+type Recovery struct {
+	OpenChannels   int               `json:"open_channels"`
+	SCBChannels    int               `json:"scb_channels"`
+	SCBSHA256      string            `json:"scb_sha256"`
+	MissingFromSCB []string          `json:"missing_from_scb,omitempty"`
+	BackupFile     *BackupFileStatus `json:"backup_file,omitempty"`
+	Watchtower     *WatchtowerStatus `json:"watchtower,omitempty"`
+	Warnings       []Warning         `json:"warnings"`
+}
+
+type RecoveryOptions struct {
+	BackupFilePath string        // optional off-node copy of channel.backup
+	MaxBackupAge   time.Duration // 0 disables the age check
+}
+
+func missingChannels(channels []Channel, covered []string) []string {
+	set := map[string]bool{}
+	for _, cp := range covered {
+		set[cp] = true
+	}
+	var missing []string
+	for _, ch := range channels {
+		if !set[ch.ChannelPoint] {
+			missing = append(missing, ch.ChannelPoint)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// CheckRecovery confirms the node's SCB covers every open channel, compares
+// it with an on-disk copy, and checks watchtower client health.
+func CheckRecovery(ctx context.Context, rc RecoveryChecker, channels []Channel, opts RecoveryOptions) Recovery {
+	rec := Recovery{OpenChannels: len(channels), Warnings: []Warning{}}
+	warn := func(sev, format string, args ...interface{}) {
+		rec.Warnings = append(rec.Warnings, Warning{Severity: sev, Message: fmt.Sprintf(format, args...)})
+	}
+
+	scb, err := rc.ChannelBackup(ctx)
+	if err != nil {
+		warn(SeverityMedium, "Could not export the static channel backup: %v", err)
+	} else {
+		sum := sha256.Sum256(scb.Multi)
+		rec.SCBChannels = len(scb.ChanPoints)
+		rec.SCBSHA256 = hex.EncodeToString(sum[:])
+		rec.MissingFromSCB = missingChannels(channels, scb.ChanPoints)
+		if len(rec.MissingFromSCB) > 0 {
+			warn(SeverityHigh, "Static channel backup is missing %d open channel(s): %s",
+				len(rec.MissingFromSCB), strings.Join(rec.MissingFromSCB, ", "))
+		}
+	}
+
+	if opts.BackupFilePath != "" {
+		rec.BackupFile = checkBackupFile(ctx, rc, channels, scb, opts, warn)
+	}
+
+	wt, err := rc.Watchtowers(ctx)
+	switch {
+	case err != nil:
+		warn(SeverityMedium, "Watchtower client unavailable (%v); channels are unprotected while offline.", err)
+	case wt.Towers == 0:
+		rec.Watchtower = &wt
+		warn(SeverityMedium, "No watchtowers configured; channels are unprotected while offline.")
+	default:
+		rec.Watchtower = &wt
+		if wt.ActiveTowers == 0 {
+			warn(SeverityMedium, "None of %d watchtower(s) is an active session candidate.", wt.Towers)
+		}
+		if wt.FailedBackups > 0 {
+			warn(SeverityLow, "%d watchtower backup(s) failed.", wt.FailedBackups)
+		}
+		if wt.SessionsExhausted > 0 && wt.SessionsExhausted >= wt.Sessions {
+			warn(SeverityLow, "All watchtower sessions are exhausted.")
+		}
+	}
+
+	return rec
+}
+
+func checkBackupFile(ctx context.Context, rc RecoveryChecker, channels []Channel, scb ChannelBackup, opts RecoveryOptions,
+	warn func(sev, format string, args ...interface{}),
+) *BackupFileStatus {
+	st := &BackupFileStatus{Path: opts.BackupFilePath}
+
+	fi, err := os.Stat(opts.BackupFilePath)
+	if err != nil {
+		warn(SeverityHigh, "Channel backup file %s is not readable: %v", opts.BackupFilePath, err)
+		return st
+	}
+	data, err := os.ReadFile(opts.BackupFilePath)
+	if err != nil {
+		warn(SeverityHigh, "Channel backup file %s is not readable: %v", opts.BackupFilePath, err)
+		return st
+	}
+
+	sum := sha256.Sum256(data)
+	st.SHA256 = hex.EncodeToString(sum[:])
+	st.AgeSeconds = int64(time.Since(fi.ModTime()).Seconds())
+	nodeSum := sha256.Sum256(scb.Multi)
+	st.MatchesNode = len(scb.Multi) > 0 && sum == nodeSum
+
+	// The node re-encrypts on every export, so a hash mismatch alone is not
+	// proof of staleness: ask the node to decrypt the file and list its channels.
+	if !st.MatchesNode {
+		covered, err := rc.VerifyChannelBackup(ctx, data)
+		if err != nil {
+			warn(SeverityHigh, "Channel backup file %s failed node verification: %v", opts.BackupFilePath, err)
+		} else {
+			st.VerifiedByNode = true
+			if covered != nil {
+				st.MissingChannels = missingChannels(channels, covered)
+			}
+		}
+	}
+	if len(st.MissingChannels) > 0 {
+		warn(SeverityHigh, "Channel backup file %s is missing %d open channel(s).", opts.BackupFilePath, len(st.MissingChannels))
+	}
+	if opts.MaxBackupAge > 0 && time.Duration(st.AgeSeconds)*time.Second > opts.MaxBackupAge {
+		warn(SeverityMedium, "Channel backup file %s is %s old (max %s).", opts.BackupFilePath,
+			(time.Duration(st.AgeSeconds) * time.Second).String(), opts.MaxBackupAge)
+	}
+	return st
+}
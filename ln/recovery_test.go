@@ -0,0 +1,131 @@
+package ln
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRecovery struct {
+	scb       ChannelBackup
+	scbErr    error
+	verified  []string
+	verifyErr error
+	towers    WatchtowerStatus
+	towersErr error
+}
+
+func (f fakeRecovery) ChannelBackup(context.Context) (ChannelBackup, error) { return f.scb, f.scbErr }
+
+func (f fakeRecovery) VerifyChannelBackup(context.Context, []byte) ([]string, error) {
+	return f.verified, f.verifyErr
+}
+
+func (f fakeRecovery) Watchtowers(context.Context) (WatchtowerStatus, error) {
+	return f.towers, f.towersErr
+}
+
+func TestChanPointString(t *testing.T) {
+	// funding_txid_bytes is base64 of the txid in internal (reversed) byte order.
+	cp := chanPoint{FundingTxIDBytes: "AQIDBA==", OutputIndex: 1}
+	if got := cp.String(); got != "04030201:1" {
+		t.Errorf("String() = %q", got)
+	}
+	cp.FundingTxIDStr = "abcd"
+	if got := cp.String(); got != "abcd:1" {
+		t.Errorf("String() with txid_str = %q", got)
+	}
+}
+
+func TestCheckRecovery(t *testing.T) {
+	channels := []Channel{{ChannelPoint: "b:0"}, {ChannelPoint: "a:1"}, {ChannelPoint: "c:2"}}
+	all := ChannelBackup{ChanPoints: []string{"a:1", "b:0", "c:2"}, Multi: []byte("node scb")}
+	towers := WatchtowerStatus{Towers: 2, ActiveTowers: 2, Sessions: 3}
+
+	dir := t.TempDir()
+	same := filepath.Join(dir, "same.backup")
+	other := filepath.Join(dir, "other.backup")
+	old := filepath.Join(dir, "old.backup")
+	for _, f := range []struct {
+		path string
+		data string
+	}{{same, "node scb"}, {other, "older export"}, {old, "node scb"}} {
+		if err := os.WriteFile(f.path, []byte(f.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		rc       fakeRecovery
+		opts     RecoveryOptions
+		missing  []string
+		warnings []string // "severity: substring"
+	}{
+		{"covered and watched", fakeRecovery{scb: all, towers: towers}, RecoveryOptions{}, nil, nil},
+		{"scb missing channels", fakeRecovery{scb: ChannelBackup{ChanPoints: []string{"b:0"}}, towers: towers}, RecoveryOptions{},
+			[]string{"a:1", "c:2"}, []string{"high: missing 2 open channel(s): a:1, c:2"}},
+		{"scb export fails", fakeRecovery{scbErr: errors.New("locked"), towers: towers}, RecoveryOptions{},
+			nil, []string{"medium: Could not export"}},
+		{"no watchtower client", fakeRecovery{scb: all, towersErr: errors.New("404")}, RecoveryOptions{},
+			nil, []string{"medium: Watchtower client unavailable"}},
+		{"no towers", fakeRecovery{scb: all}, RecoveryOptions{}, nil, []string{"medium: No watchtowers configured"}},
+		{"unhealthy towers", fakeRecovery{scb: all, towers: WatchtowerStatus{Towers: 1, Sessions: 2, FailedBackups: 3, SessionsExhausted: 2}},
+			RecoveryOptions{}, nil, []string{
+				"medium: None of 1 watchtower(s) is an active session candidate",
+				"low: 3 watchtower backup(s) failed",
+				"low: All watchtower sessions are exhausted",
+			}},
+		{"backup file matches node", fakeRecovery{scb: all, towers: towers}, RecoveryOptions{BackupFilePath: same, MaxBackupAge: time.Hour},
+			nil, nil},
+		{"backup file is stale", fakeRecovery{scb: all, towers: towers, verified: []string{"a:1", "b:0"}},
+			RecoveryOptions{BackupFilePath: other}, nil, []string{"high: is missing 1 open channel(s)"}},
+		{"backup file fails verification", fakeRecovery{scb: all, towers: towers, verifyErr: errors.New("bad mac")},
+			RecoveryOptions{BackupFilePath: other}, nil, []string{"high: failed node verification: bad mac"}},
+		{"backup file is missing", fakeRecovery{scb: all, towers: towers}, RecoveryOptions{BackupFilePath: filepath.Join(dir, "nope")},
+			nil, []string{"high: is not readable"}},
+		{"backup file is old", fakeRecovery{scb: all, towers: towers}, RecoveryOptions{BackupFilePath: old, MaxBackupAge: 24 * time.Hour},
+			nil, []string{"medium: old (max 24h0m0s)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := CheckRecovery(context.Background(), tt.rc, channels, tt.opts)
+			if strings.Join(rec.MissingFromSCB, ",") != strings.Join(tt.missing, ",") {
+				t.Errorf("missing from scb = %v, want %v", rec.MissingFromSCB, tt.missing)
+			}
+			if len(rec.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %+v, want %d", rec.Warnings, len(tt.warnings))
+			}
+			for i, w := range tt.warnings {
+				sev, msg, _ := strings.Cut(w, ": ")
+				if rec.Warnings[i].Severity != sev || !strings.Contains(rec.Warnings[i].Message, msg) {
+					t.Errorf("warning %d = %+v, want %s %q", i, rec.Warnings[i], sev, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRecoveryBackupFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channel.backup")
+	if err := os.WriteFile(path, []byte("older export"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rc := fakeRecovery{scb: ChannelBackup{ChanPoints: []string{"a:1"}, Multi: []byte("node scb")}, verified: []string{}}
+	st := CheckRecovery(context.Background(), rc, []Channel{{ChannelPoint: "a:1"}}, RecoveryOptions{BackupFilePath: path}).BackupFile
+	if st == nil || st.MatchesNode || !st.VerifiedByNode || st.SHA256 == "" {
+		t.Errorf("re-encrypted backup = %+v, want verified by the node", st)
+	}
+	// An empty list from the node is a backup with no channels at all.
+	if len(st.MissingChannels) != 1 {
+		t.Errorf("missing = %v, want a:1", st.MissingChannels)
+	}
+}
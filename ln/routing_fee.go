@@ -0,0 +1,119 @@
+package ln
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// NeighborLister is implemented by backends that can list a node's channel
+// counterparties from the graph (currently LND).
+type NeighborLister interface {
+	NodeNeighbors(ctx context.Context, pubkey string) ([]string, error)
+}
+
+var _ NeighborLister = (*LNDClient)(nil)
+
+type nodeInfoResponse struct {
+	Channels []ChannelEdge `json:"channels"`
+}
+
+func (c *LNDClient) NodeNeighbors(ctx context.Context, pubkey string) ([]string, error) {
+	var res nodeInfoResponse
+	if err := c.get(ctx, "/v1/graph/node/"+url.PathEscape(pubkey)+"?include_channels=true", &res); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(res.Channels))
+	for _, e := range res.Channels {
+		if e.Node1Pub == pubkey {
+			out = append(out, e.Node2Pub)
+		} else {
+			out = append(out, e.Node1Pub)
+		}
+	}
+	return out, nil
+}
+
+// FeeProbeDestinations picks up to max nodes one hop past our peers, one
+// per peer starting with the peers we can send the most through, so a fee
+// estimate to them pays at least one real forwarding fee.
+func FeeProbeDestinations(ctx context.Context, nl NeighborLister, self string, channels []Channel, max int) []string {
+	chans := append([]Channel(nil), channels...)
+	sort.SliceStable(chans, func(i, j int) bool { return ChannelOutbound(chans[i]) > ChannelOutbound(chans[j]) })
+	direct := map[string]bool{self: true}
+	for _, ch := range chans {
+		direct[ch.RemotePubkey] = true
+	}
+
+	var dests []string
+	tried := map[string]bool{}
+	for _, ch := range chans {
+		if len(dests) >= max || ctx.Err() != nil {
+			break
+		}
+		if ChannelOutbound(ch) == 0 || tried[ch.RemotePubkey] {
+			continue
+		}
+		tried[ch.RemotePubkey] = true
+		neighbors, err := nl.NodeNeighbors(ctx, ch.RemotePubkey)
+		if err != nil {
+			continue
+		}
+		var far []string
+		for _, n := range neighbors {
+			if !direct[n] && !tried[n] {
+				far = append(far, n)
+			}
+		}
+		if len(far) > 0 {
+			d := far[rand.Intn(len(far))]
+			tried[d] = true
+			dests = append(dests, d)
+		}
+	}
+	return dests
+}
+
+// RoutingFeeEstimate is the node's fee estimate for one payment of
+// AmountSats: the median over the destinations it found a route to.
+type RoutingFeeEstimate struct {
+	AmountSats   uint64 `json:"amount_sats"`
+	FeeMsat      uint64 `json:"fee_msat"`
+	Destinations int    `json:"destinations"`
+	Routed       int    `json:"routed"`
+	Source       string `json:"source"` // "queryroutes", "estimatefee" or both joined by "+"
+}
+
+// EstimateRoutingFee asks pp for a route of amountSats to each destination.
+// Routed is 0 when no route was found; the fee is then meaningless.
+func EstimateRoutingFee(ctx context.Context, pp PaymentProber, dests []string, amountSats uint64) RoutingFeeEstimate {
+	est := RoutingFeeEstimate{AmountSats: amountSats, Destinations: len(dests)}
+	var fees []uint64
+	sources := map[string]bool{}
+	for _, d := range dests {
+		if ctx.Err() != nil {
+			break
+		}
+		r, err := pp.EstimateRoute(ctx, Invoice{Payee: d, MinFinalCLTV: 18}, "", amountSats)
+		if err != nil || !r.Found {
+			continue
+		}
+		fees = append(fees, r.FeeMsat)
+		sources[r.Source] = true
+	}
+	est.Routed = len(fees)
+	if len(fees) == 0 {
+		return est
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+	est.FeeMsat = fees[len(fees)/2]
+	var names []string
+	for s := range sources {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	est.Source = strings.Join(names, "+")
+	return est
+}
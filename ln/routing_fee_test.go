@@ -0,0 +1,67 @@
+package ln
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fakeNeighbors map[string][]string
+
+func (f fakeNeighbors) NodeNeighbors(_ context.Context, pub string) ([]string, error) {
+	n, ok := f[pub]
+	if !ok {
+		return nil, errors.New("node not found")
+	}
+	return n, nil
+}
+
+type fakeProber map[string]RouteEstimate
+
+func (f fakeProber) EstimateRoute(_ context.Context, inv Invoice, _ string, _ uint64) (RouteEstimate, error) {
+	r, ok := f[inv.Payee]
+	if !ok {
+		return RouteEstimate{}, errors.New("lnd http 500: unable to find a path")
+	}
+	return r, nil
+}
+
+func TestFeeProbeDestinations(t *testing.T) {
+	channels := []Channel{
+		{Active: true, RemotePubkey: "small", LocalBalanceSats: 10_000},
+		{Active: true, RemotePubkey: "big", LocalBalanceSats: 900_000},
+		{Active: false, RemotePubkey: "offline", LocalBalanceSats: 500_000},
+		{Active: true, RemotePubkey: "unknown", LocalBalanceSats: 400_000},
+	}
+	nl := fakeNeighbors{
+		"big":     {"self", "small", "far1"},
+		"small":   {"big", "far1", "far2"},
+		"offline": {"far3"},
+	}
+	got := FeeProbeDestinations(context.Background(), nl, "self", channels, 3)
+	if want := []string{"far1", "far2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("destinations = %v, want %v", got, want)
+	}
+	if got := FeeProbeDestinations(context.Background(), nl, "self", channels, 1); !reflect.DeepEqual(got, []string{"far1"}) {
+		t.Errorf("max 1: destinations = %v", got)
+	}
+}
+
+func TestEstimateRoutingFee(t *testing.T) {
+	pp := fakeProber{
+		"a": {Source: "queryroutes", Found: true, FeeMsat: 3000},
+		"b": {Source: "queryroutes", Found: true, FeeMsat: 1000},
+		"c": {Source: "estimatefee", Found: true, FeeMsat: 9000},
+		"d": {Source: "estimatefee", Found: false, FailureReason: "FAILURE_REASON_NO_ROUTE"},
+	}
+	got := EstimateRoutingFee(context.Background(), pp, []string{"a", "b", "c", "d", "e"}, 50_000)
+	want := RoutingFeeEstimate{AmountSats: 50_000, FeeMsat: 3000, Destinations: 5, Routed: 3, Source: "estimatefee+queryroutes"}
+	if got != want {
+		t.Errorf("estimate = %+v, want %+v", got, want)
+	}
+
+	if got := EstimateRoutingFee(context.Background(), pp, []string{"d", "e"}, 50_000); got.Routed != 0 || got.Source != "" {
+		t.Errorf("no routes: estimate = %+v", got)
+	}
+}
@@ -0,0 +1,124 @@
+package ln
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TLSConfig describes how to authenticate a node's REST endpoint. CertPath
+// (or CertPEM) verifies the chain and hostname against the node's own
+// tls.cert; PinSHA256 pins the leaf certificate's SHA-256 fingerprint.
+type TLSConfig struct {
+	CertPath  string
+	CertPEM   []byte
+	PinSHA256 string
+	Insecure  bool // dev only
+	// The caller supplied its own http.Client, whose TLS settings we
+	// neither set nor know; the fields above are not applied
+	CustomClient bool
+}
+
+// Verified reports whether the connection is authenticated by a cert or pin.
+func (t TLSConfig) Verified() bool {
+	return !t.CustomClient && (len(t.CertPEM) > 0 || t.CertPath != "" || t.PinSHA256 != "")
+}
+
+func (t TLSConfig) Build() (*tls.Config, error) {
+	certPEM := t.CertPEM
+	if len(certPEM) == 0 && t.CertPath != "" {
+		b, err := os.ReadFile(t.CertPath)
+		if err != nil {
+			return nil, fmt.Errorf("read tls cert: %w", err)
+		}
+		certPEM = b
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(certPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certPEM) {
+			return nil, errors.New("tls cert: no PEM certificates found")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.PinSHA256 != "" {
+		pin, err := hex.DecodeString(strings.ReplaceAll(strings.ToLower(t.PinSHA256), ":", ""))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, errors.New("tls pin: expected a hex SHA-256 fingerprint")
+		}
+		// With only a pin, the pin replaces chain verification entirely.
+		if len(certPEM) == 0 {
+			cfg.InsecureSkipVerify = true
+		}
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("tls pin: no peer certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return fmt.Errorf("tls pin mismatch: got %x", sum)
+			}
+			return nil
+		}
+		return cfg, nil
+	}
+
+	if len(certPEM) == 0 && t.Insecure {
+		cfg.InsecureSkipVerify = true // dev only
+	}
+	return cfg, nil
+}
+
+// LNDConnect holds the parts of an lndconnect:// URI.
+type LNDConnect struct {
+	BaseURL  string
+	Macaroon []byte
+	CertPEM  []byte
+}
+
+// ParseLNDConnect decodes lndconnect://host:port?cert=...&macaroon=... where
+// cert is base64url DER and macaroon is base64url bytes. The host:port must be
+// LND's REST listener.
+func ParseLNDConnect(uri string) (LNDConnect, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return LNDConnect{}, err
+	}
+	if u.Scheme != "lndconnect" || u.Host == "" {
+		return LNDConnect{}, errors.New("lndconnect: expected lndconnect://host:port")
+	}
+
+	out := LNDConnect{BaseURL: "https://" + u.Host}
+	q := u.Query()
+
+	mac := q.Get("macaroon")
+	if mac == "" {
+		return LNDConnect{}, errors.New("lndconnect: missing macaroon")
+	}
+	if out.Macaroon, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(mac, "=")); err != nil {
+		return LNDConnect{}, fmt.Errorf("lndconnect macaroon: %w", err)
+	}
+
+	if cert := q.Get("cert"); cert != "" {
+		der, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cert, "="))
+		if err != nil {
+			return LNDConnect{}, fmt.Errorf("lndconnect cert: %w", err)
+		}
+		if _, err := x509.ParseCertificate(der); err != nil {
+			return LNDConnect{}, fmt.Errorf("lndconnect cert: %w", err)
+		}
+		out.CertPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	return out, nil
+}
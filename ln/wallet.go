@@ -0,0 +1,135 @@
+package ln
+
+import (
+	"context"
+	"fmt"
+
+	"sovereign-checker/btc"
+)
+
+// LND reserves this much per anchor channel for fee bumping, capped at lndMaxAnchorReserveSats.
+const (
+	lndAnchorReservePerChanSats = 10_000
+	lndMaxAnchorReserveSats     = 100_000
+)
+
+type WalletBalance struct {
+	TotalBalanceSats       int64 `json:"total_balance,string"`
+	ConfirmedBalanceSats   int64 `json:"confirmed_balance,string"`
+	UnconfirmedBalanceSats int64 `json:"unconfirmed_balance,string"`
+	LockedBalanceSats      int64 `json:"locked_balance,string"`
+	ReservedAnchorSats     int64 `json:"reserved_balance_anchor_chan,string"`
+}
+
+func (c *LNDClient) WalletBalance(ctx context.Context) (WalletBalance, error) {
+	var res WalletBalance
+	err := c.get(ctx, "/v1/balance/blockchain", &res)
+	return res, err
+}
+
+type WalletUTXO struct {
+	AddressType   string `json:"address_type"`
+	Address       string `json:"address"`
+	AmountSats    int64  `json:"amount_sat,string"`
+	Confirmations int64  `json:"confirmations,string"`
+	Outpoint      struct {
+		TxID        string `json:"txid_str"`
+		OutputIndex int    `json:"output_index"`
+	} `json:"outpoint"`
+}
+
+type listUnspentRequest struct {
+	MinConfs int32 `json:"min_confs"`
+	MaxConfs int32 `json:"max_confs"`
+}
+
+type listUnspentResponse struct {
+	UTXOs []WalletUTXO `json:"utxos"`
+}
+
+// ListUnspent returns LND's on-chain wallet UTXOs, including unconfirmed ones.
+func (c *LNDClient) ListUnspent(ctx context.Context) ([]btc.UTXO, error) {
+	var res listUnspentResponse
+	if err := c.post(ctx, "/v2/wallet/utxos", listUnspentRequest{MinConfs: 0, MaxConfs: 9999999}, &res); err != nil {
+		return nil, err
+	}
+	out := make([]btc.UTXO, 0, len(res.UTXOs))
+	for _, u := range res.UTXOs {
+		out = append(out, btc.UTXO{
+			TxID:      u.Outpoint.TxID,
+			Vout:      u.Outpoint.OutputIndex,
+			ValueSats: positive(u.AmountSats),
+			Confirmed: u.Confirmations > 0,
+			Source:    "lnd",
+		})
+	}
+	return out, nil
+}
+
+type AnchorReserve struct {
+	NumAnchorChannels    int      `json:"num_anchor_channels"`
+	ConfirmedSats        uint64   `json:"confirmed_sats"`
+	ConfirmedUTXOs       int      `json:"confirmed_utxos"`
+	LNDReserveSats       uint64   `json:"lnd_reserve_sats"`
+	RequiredSats         uint64   `json:"required_sats"`
+	StressedRequiredSats uint64   `json:"stressed_required_sats"`
+	CanCPFP              bool     `json:"can_cpfp"`
+	CanCPFPStressed      bool     `json:"can_cpfp_stressed"`
+	Warnings             []string `json:"warnings"`
+}
+
+// ComputeAnchorReserve checks whether LND's confirmed wallet coins can pay the
+// CPFP children needed to force close every anchor channel in plan.
+func ComputeAnchorReserve(walletUTXOs []btc.UTXO, plan ExitPlan) AnchorReserve {
+	res := AnchorReserve{
+		NumAnchorChannels: plan.NumAnchorChannels,
+		Warnings:          []string{},
+	}
+
+	for _, u := range walletUTXOs {
+		if u.Confirmed {
+			res.ConfirmedSats += u.ValueSats
+			res.ConfirmedUTXOs++
+		}
+	}
+	for _, ch := range plan.Channels {
+		if ch.Anchor {
+			res.RequiredSats += ch.CPFPFeeSats
+			res.StressedRequiredSats += ch.StressedCPFPSats
+		}
+	}
+
+	res.LNDReserveSats = uint64(plan.NumAnchorChannels) * lndAnchorReservePerChanSats
+	if res.LNDReserveSats > lndMaxAnchorReserveSats {
+		res.LNDReserveSats = lndMaxAnchorReserveSats
+	}
+
+	if plan.NumAnchorChannels == 0 {
+		res.CanCPFP = true
+		res.CanCPFPStressed = true
+		return res
+	}
+
+	res.CanCPFP = res.ConfirmedUTXOs > 0 && res.ConfirmedSats >= res.RequiredSats
+	res.CanCPFPStressed = res.ConfirmedUTXOs > 0 && res.ConfirmedSats >= res.StressedRequiredSats
+
+	switch {
+	case res.ConfirmedUTXOs == 0:
+		res.Warnings = append(res.Warnings,
+			"Node wallet has no confirmed UTXOs; anchor channels cannot be fee-bumped in a force close.")
+	case !res.CanCPFP:
+		res.Warnings = append(res.Warnings, fmt.Sprintf(
+			"Node wallet holds %d confirmed sats but needs %d to CPFP all anchor commitments at the current fee rate.",
+			res.ConfirmedSats, res.RequiredSats))
+	case !res.CanCPFPStressed:
+		res.Warnings = append(res.Warnings, fmt.Sprintf(
+			"Node wallet holds %d confirmed sats but needs %d to CPFP all anchor commitments at %d sat/vB.",
+			res.ConfirmedSats, res.StressedRequiredSats, plan.StressedFeeRateSatVB))
+	}
+	if res.ConfirmedSats < res.LNDReserveSats {
+		res.Warnings = append(res.Warnings, fmt.Sprintf(
+			"Confirmed wallet balance is below LND's own anchor reserve (%d sats).", res.LNDReserveSats))
+	}
+
+	return res
+}
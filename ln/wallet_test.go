@@ -0,0 +1,70 @@
+package ln
+
+import (
+	"strings"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+func TestComputeAnchorReserve(t *testing.T) {
+	anchors := func(n int) ExitPlan {
+		p := ExitPlan{NumAnchorChannels: n, StressedFeeRateSatVB: 50}
+		for i := 0; i < n; i++ {
+			p.Channels = append(p.Channels, ChannelExit{Anchor: true, CPFPFeeSats: 2000, StressedCPFPSats: 20_000})
+		}
+		// Legacy channels need no CPFP child and add nothing to the reserve.
+		p.Channels = append(p.Channels, ChannelExit{CPFPFeeSats: 999, StressedCPFPSats: 9999})
+		return p
+	}
+	coins := func(values ...uint64) []btc.UTXO {
+		var us []btc.UTXO
+		for _, v := range values {
+			us = append(us, btc.UTXO{ValueSats: v, Confirmed: true})
+		}
+		return us
+	}
+	unconfirmed := btc.UTXO{ValueSats: 1_000_000}
+
+	tests := []struct {
+		name               string
+		utxos              []btc.UTXO
+		plan               ExitPlan
+		required, stressed uint64
+		lndReserve         uint64
+		canCPFP, canStress bool
+		warnings           []string
+	}{
+		{"no anchor channels", nil, anchors(0), 0, 0, 0, true, true, nil},
+		{"only unconfirmed coins", []btc.UTXO{unconfirmed}, anchors(2), 4000, 40_000, 20_000, false, false,
+			[]string{"no confirmed UTXOs", "below LND's own anchor reserve (20000 sats)"}},
+		{"short at current fees", coins(1500, 1500), anchors(2), 4000, 40_000, 20_000, false, false,
+			[]string{"holds 3000 confirmed sats but needs 4000 to CPFP all anchor commitments at the current fee rate",
+				"below LND's own anchor reserve"}},
+		{"short at stressed fees", coins(30_000), anchors(2), 4000, 40_000, 20_000, true, false,
+			[]string{"holds 30000 confirmed sats but needs 40000 to CPFP all anchor commitments at 50 sat/vB"}},
+		{"covered", append(coins(25_000, 25_000), unconfirmed), anchors(2), 4000, 40_000, 20_000, true, true, nil},
+		{"lnd reserve is capped", coins(100_000), anchors(12), 24_000, 240_000, 100_000, true, false,
+			[]string{"needs 240000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ComputeAnchorReserve(tt.utxos, tt.plan)
+			if r.RequiredSats != tt.required || r.StressedRequiredSats != tt.stressed || r.LNDReserveSats != tt.lndReserve {
+				t.Errorf("required %d stressed %d lnd %d, want %d %d %d",
+					r.RequiredSats, r.StressedRequiredSats, r.LNDReserveSats, tt.required, tt.stressed, tt.lndReserve)
+			}
+			if r.CanCPFP != tt.canCPFP || r.CanCPFPStressed != tt.canStress {
+				t.Errorf("can cpfp %t stressed %t, want %t %t", r.CanCPFP, r.CanCPFPStressed, tt.canCPFP, tt.canStress)
+			}
+			if len(r.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d", r.Warnings, len(tt.warnings))
+			}
+			for i, w := range tt.warnings {
+				if !strings.Contains(r.Warnings[i], w) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, r.Warnings[i], w)
+				}
+			}
+		})
+	}
+}
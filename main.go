@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"sovereign-checker/api"
@@ -18,16 +29,29 @@ import (
 )
 
 func main() {
-	mode := flag.String("mode", "cli", "cli or server")
+	mode := flag.String("mode", "cli", "cli, server, keys (manage API keys), or lnperms (print the minimal LND macaroon permissions)")
 
 	// Common
 	address := flag.String("address", "", "bitcoin address to check (cli mode)")
 	networkStr := flag.String("network", "testnet", "mainnet or testnet")
 	feeFallback := flag.Uint64("feerate", 2, "fallback feerate in sats/vB (used if no node estimate)")
 	feeLow := flag.Uint64("feelow", 2, "low-fee threshold (sats/vB) for consolidation planning")
+	feeStress := flag.Uint64("feestress", 5, "multiplier on the current feerate for stressed LN exit costs")
+
+	// LN vs on-chain cost comparison
+	payCount := flag.Int("paycount", 0, "expected payments per month (enables LN vs on-chain comparison)")
+	paySize := flag.Uint64("paysize", 0, "expected average payment size in sats")
+	horizon := flag.Int("horizon", 12, "months to project LN vs on-chain costs over")
+	lnFeeBase := flag.Uint64("lnfeebase", 1000, "fallback LN routing base fee in msat per payment, used when the node gives no route estimate")
+	lnFeePPM := flag.Uint64("lnfeeppm", 500, "fallback LN routing fee rate in ppm")
+	lnFeeDest := flag.String("lnfeedest", "", "comma-separated node pubkeys to estimate routing fees to (default: nodes one hop past our peers, LND)")
 
 	// Tor
 	torSocks := flag.String("tor", "", "tor SOCKS5 addr (e.g. 127.0.0.1:9050). Routes outbound HTTP through Tor")
+	torIsolation := flag.String("torisolation", "wallet", "tor stream isolation: none, session, wallet (per address) or request")
+	torStrict := flag.Bool("torstrict", false, "fail closed: verify the tor proxy at startup and refuse any non-Tor connection (loopback excepted)")
+	torControl := flag.String("torcontrol", "", "tor control port (e.g. 127.0.0.1:9051) used to verify the proxy is Tor")
+	torOnion := flag.Bool("toronion", true, "use the explorer's onion service when -tor is set")
 	insecureTLS := flag.Bool("insecuretls", false, "skip TLS verification for outbound HTTP (dev only)")
 
 	// Node-only
@@ -36,15 +60,71 @@ func main() {
 	rpcUser := flag.String("rpcuser", "", "bitcoind RPC username")
 	rpcPass := flag.String("rpcpass", "", "bitcoind RPC password")
 
-	// LND readiness (CLI + server)
-	lnCheck := flag.Bool("lncheck", false, "also check LND readiness (cli mode)")
+	// Lightning readiness (CLI + server)
+	lnCheck := flag.Bool("lncheck", false, "also check Lightning readiness (cli mode)")
+	invoice := flag.String("invoice", "", "BOLT11 invoice to probe payability for (with -lncheck)")
+	receiveAmt := flag.Uint64("receiveamt", 0, "check whether this many sats can be received over LN (with -lncheck)")
+	invoiceAmt := flag.Uint64("invoiceamt", 0, "amount in sats for -invoice when the invoice has none")
 	lndEnabled := flag.Bool("lndenabled", false, "enable /lnready and LN in /report (server mode)")
+	lnBackend := flag.String("lnbackend", "lnd", "lightning backend: lnd or cln")
 	lndURL := flag.String("lndurl", "https://127.0.0.1:8080", "LND REST base URL")
 	macaroonPath := flag.String("macaroon", "", "path to LND macaroon file (admin or readonly)")
-	lndTLSInsecure := flag.Bool("lndinsecure", true, "skip TLS verify for LND (dev/self-signed)")
+	lndTLSInsecure := flag.Bool("lndinsecure", false, "skip TLS verify for LND/clnrest when no cert or pin is given (dev only)")
+	lndTLSCert := flag.String("lndtlscert", "", "path to LND tls.cert; verifies the chain and hostname")
+	lndCertPin := flag.String("lndcertpin", "", "hex SHA-256 fingerprint of LND's TLS certificate to pin")
+	lndConnect := flag.String("lndconnect", "", "lndconnect:// URI bundling REST host, cert and macaroon")
+	clnSocket := flag.String("clnsocket", "", "path to Core Lightning lightning-rpc unix socket")
+	clnRestURL := flag.String("clnresturl", "", "Core Lightning clnrest base URL (e.g. https://127.0.0.1:3010)")
+	clnRune := flag.String("clnrune", "", "path to a file holding the clnrest rune")
+	scbFile := flag.String("scbfile", "", "path to an off-node copy of LND's channel.backup to verify")
+	scbMaxAge := flag.Duration("scbmaxage", 24*time.Hour, "warn when -scbfile is older than this")
+	lnHealth := flag.Bool("lnhealth", false, "report routing channel health: policies, peers, forwarding revenue (LND)")
+	healthWindow := flag.Int("healthwindow", 30, "forwarding revenue window in days (with -lnhealth)")
+	idleDays := flag.Int("idledays", 30, "flag channels with no forwards in this many days as close candidates")
+	clnTLSCert := flag.String("clntlscert", "", "path to the clnrest TLS certificate (or its CA)")
+
+	// Per-backend deadlines and retries
+	explorerTimeout := flag.Duration("explorertimeout", 15*time.Second, "deadline per block explorer call, retries included")
+	explorerRetries := flag.Int("explorerretries", 2, "retries per block explorer request on transient failures")
+	rpcTimeout := flag.Duration("rpctimeout", 15*time.Second, "deadline per bitcoind RPC call, retries included")
+	rpcRetries := flag.Int("rpcretries", 1, "retries per bitcoind RPC request on transient failures")
+	scanTimeout := flag.Duration("scantimeout", 5*time.Minute, "deadline for a bitcoind scantxoutset (descriptors, reconciliation, explorer fallback)")
+	lnTimeout := flag.Duration("lntimeout", 10*time.Second, "deadline per Lightning node call, retries included; a node that times out on getinfo is skipped")
+	lnRetries := flag.Int("lnretries", 1, "retries per Lightning node request on transient failures")
+	httpRetries := flag.Int("httpretries", 3, "retries per HTTP request on 429, 502-504 and connection errors, with backoff (per-backend -*retries override it)")
+	hostRate := flag.Float64("hostrate", 5, "max requests per second to each remote host (0 = unlimited)")
+	breakerAfter := flag.Int("breakerafter", 5, "consecutive failures that open a host's circuit breaker for a minute")
+	decoys := flag.Int("decoys", 0, "query this many decoy addresses from recent blocks alongside the real one (explorer only)")
+	decoyJitter := flag.Duration("decoyjitter", 2*time.Second, "spread decoy and real queries randomly over this window")
+	explorerFallback := flag.Bool("explorerfallback", true, "fall back to mempool.space when the explorer's breaker is open")
+
+	// Cache
+	utxoTTL := flag.Duration("cachettl", 5*time.Minute, "reuse an address's UTXO set this long unless a new block arrives (0 = off)")
+	feeTTL := flag.Duration("feettl", time.Minute, "reuse bitcoind fee estimates this long (0 = off)")
+	lnTTL := flag.Duration("lnttl", time.Minute, "reuse Lightning node reports this long (0 = off)")
+	cacheFile := flag.String("cachefile", "", "persist the cache in this file between runs (holds addresses and balances)")
+	fresh := flag.Bool("fresh", false, "ignore cached data for this run (cli mode)")
+
+	// Batch
+	batchFile := flag.String("batchfile", "", "check every address or descriptor in this file (one per line, optional network after it, or a JSON list)")
+	batchConcurrency := flag.Int("batchconcurrency", 4, "reports built at once for -batchfile and /report/batch")
 
 	// Server
 	port := flag.String("port", "8080", "server port")
+	keyFile := flag.String("keyfile", "", "API key store; when set, server endpoints need a key (manage with -mode=keys)")
+	tlsCert := flag.String("tlscert", "", "serve HTTPS with this certificate (server mode)")
+	tlsKey := flag.String("tlskey", "", "private key for -tlscert")
+	clientCA := flag.String("clientca", "", "verify mTLS client certificates against this CA (with -tlscert)")
+	tlsSelfSigned := flag.Bool("tlsselfsigned", false, "serve HTTPS with a generated self-signed certificate, saved to -tlscert/-tlskey when given and missing")
+	tlsHosts := flag.String("tlshosts", "localhost,127.0.0.1,::1", "comma-separated names and IPs for the self-signed certificate")
+	readHeaderTimeout := flag.Duration("readheadertimeout", 10*time.Second, "time allowed to read request headers")
+	writeTimeout := flag.Duration("writetimeout", 2*time.Minute, "time allowed to build and write a response; responses that may scan with bitcoind get -scantimeout more (batch streams are exempt)")
+	idleTimeout := flag.Duration("idletimeout", 2*time.Minute, "keep-alive connection idle timeout")
+	shutdownTimeout := flag.Duration("shutdowntimeout", 30*time.Second, "on SIGINT/SIGTERM, wait this long for in-flight reports before closing")
+	unixSocket := flag.String("unixsocket", "", "also serve on this unix socket (owner-only); replaces the TCP listener unless -port is given")
+	onion := flag.Bool("onion", false, "also serve as a Tor onion service via -torcontrol; replaces the TCP listener unless -port is given")
+	onionKey := flag.String("onionkey", "", "file holding the onion service key, created on first use so the address stays stable")
+	torControlPass := flag.String("torcontrolpass", "", "tor control port password (cookie auth is used when available)")
 
 	flag.Parse()
 
@@ -53,151 +133,575 @@ func main() {
 		network = btc.Mainnet
 	}
 
+	retry := &netx.RetryPolicy{
+		MaxRetries:       *httpRetries,
+		RatePerHost:      *hostRate,
+		BreakerThreshold: *breakerAfter,
+	}
+	if *explorerFallback {
+		retry.Fallbacks = btc.ExplorerFallbacks()
+	}
+
 	// Shared outbound HTTP client (optionally Tor-routed)
-	httpClient, err := netx.NewHTTPClient(netx.ClientConfig{
+	netCfg := netx.ClientConfig{
 		Timeout:       15 * time.Second,
 		TorSocks5Addr: *torSocks,
+		TorIsolation:  *torIsolation,
+		StrictTor:     *torStrict,
 		InsecureTLS:   *insecureTLS,
-	})
+		Retry:         retry,
+	}
+	httpClient, err := netx.NewHTTPClient(netCfg)
 	if err != nil {
 		log.Fatalf("failed to build http client: %v", err)
 	}
 
+	var torStatus *netx.TorStatus
+	if *torSocks != "" {
+		st := netx.VerifyTor(context.Background(), netCfg, *torControl, httpClient)
+		st.ExplorerOnion = *torOnion
+		if !st.IsTor {
+			if *torStrict {
+				log.Fatalf("strict tor: proxy verification failed: %s", st.Error)
+			}
+			log.Printf("warning: tor proxy not verified: %s", st.Error)
+		}
+		torStatus = &st
+	}
+
+	cfg := api.Config{
+		NodeOnly:        *nodeOnly,
+		Network:         network,
+		FeeRateFallback: *feeFallback,
+		FeeLowSatVB:     *feeLow,
+		HTTPClient:      httpClient,
+		Net:             netCfg,
+		Tor:             torStatus,
+		ExplorerOnion:   *torSocks != "" && *torOnion,
+		Decoys:          *decoys,
+		DecoyJitter:     *decoyJitter,
+
+		Cache:   api.NewCache(*cacheFile),
+		UTXOTTL: *utxoTTL,
+		FeeTTL:  *feeTTL,
+		LNTTL:   *lnTTL,
+		Fresh:   *fresh,
+
+		BatchConcurrency: *batchConcurrency,
+
+		ExplorerBudget: netx.Budget{Timeout: *explorerTimeout, Retries: *explorerRetries},
+		BitcoindBudget: netx.Budget{Timeout: *rpcTimeout, Retries: *rpcRetries},
+		ScanBudget:     netx.Budget{Timeout: *scanTimeout},
+		WriteTimeout:   *writeTimeout,
+		LNBudget:       netx.Budget{Timeout: *lnTimeout, Retries: *lnRetries},
+
+		StressFeeMultiplier: *feeStress,
+		ReceiveAmountSats:   *receiveAmt,
+
+		PaymentsPerMonth:   *payCount,
+		PaymentSizeSats:    *paySize,
+		HorizonMonths:      *horizon,
+		RoutingFeeBaseMsat: *lnFeeBase,
+		RoutingFeePPM:      *lnFeePPM,
+		RoutingFeeDests:    splitList(*lnFeeDest),
+
+		RPCURL:  *rpcURL,
+		RPCUser: *rpcUser,
+		RPCPass: *rpcPass,
+
+		LNDEnabled: *lndEnabled,
+		LNBackend:  *lnBackend,
+
+		LNDBaseURL:     *lndURL,
+		MacaroonPath:   *macaroonPath,
+		LNDTLSInsecure: *lndTLSInsecure,
+		LNDTLSCertPath: *lndTLSCert,
+		LNDCertPin:     *lndCertPin,
+		LNDConnectURI:  *lndConnect,
+
+		CLNSocketPath:  *clnSocket,
+		CLNRestURL:     *clnRestURL,
+		CLNRunePath:    *clnRune,
+		CLNTLSCertPath: *clnTLSCert,
+
+		SCBFilePath: *scbFile,
+		SCBMaxAge:   *scbMaxAge,
+
+		ChannelHealth:    *lnHealth,
+		HealthWindowDays: *healthWindow,
+		HealthIdleDays:   *idleDays,
+	}
+
 	switch *mode {
 	case "cli":
+		if *batchFile != "" {
+			runBatch(cfg, *batchFile)
+			return
+		}
 		if *address == "" {
 			fmt.Println("Usage:")
 			fmt.Println("  go run . -mode=cli -address=<addr> -network=testnet")
+			fmt.Println("  go run . -mode=cli -batchfile=wallets.txt [-batchconcurrency=4]")
 			fmt.Println("Options:")
 			fmt.Println("  -nodeonly=true -rpcurl=... -rpcuser=... -rpcpass=...")
-			fmt.Println("  -tor=127.0.0.1:9050")
-			fmt.Println("  -lncheck=true -lndurl=... -macaroon=/path/to.macaroon")
+			fmt.Println("  -tor=127.0.0.1:9050 [-torstrict=true -torcontrol=127.0.0.1:9051 -torisolation=wallet]")
+			fmt.Println("  -decoys=5 [-decoyjitter=2s]")
+			fmt.Println("  -cachefile=/path/to/cache.json [-cachettl=5m -fresh=true]")
+			fmt.Println("  -lncheck=true -lndurl=... -macaroon=/path/to.macaroon -lndtlscert=/path/to/tls.cert")
+			fmt.Println("  -lncheck=true -lndconnect=lndconnect://host:8080?cert=...&macaroon=...")
+			fmt.Println("  -lncheck=true -invoice=lnbc... [-invoiceamt=sats]")
+			fmt.Println("  -lncheck=true -lnhealth=true [-healthwindow=30 -idledays=30]")
+			fmt.Println("  -lncheck=true -lnbackend=cln -clnsocket=/path/to/lightning-rpc")
 			os.Exit(1)
 		}
-		runCLI(httpClient, network, *address, *feeFallback, *feeLow, *nodeOnly, *rpcURL, *rpcUser, *rpcPass,
-			*lnCheck, *lndURL, *macaroonPath, *lndTLSInsecure)
+		runCLI(cfg, *address, *lnCheck, *invoice, *invoiceAmt)
 	case "server":
-		runServer(httpClient, network, *feeFallback, *feeLow, *nodeOnly, *rpcURL, *rpcUser, *rpcPass,
-			*port, *lndEnabled, *lndURL, *macaroonPath, *lndTLSInsecure)
+		if *keyFile != "" {
+			ks, err := api.OpenKeyStore(*keyFile)
+			if err != nil {
+				log.Fatalf("open key store: %v", err)
+			}
+			cfg.Auth = ks
+		}
+		tlsCfg, err := serverTLS(*tlsCert, *tlsKey, *clientCA, *tlsSelfSigned, strings.Split(*tlsHosts, ","))
+		if err != nil {
+			log.Fatalf("server tls: %v", err)
+		}
+		opts := serverOpts{
+			port:              *port,
+			tls:               tlsCfg,
+			unixSocket:        *unixSocket,
+			onion:             *onion,
+			onionKey:          *onionKey,
+			torControl:        *torControl,
+			torControlPass:    *torControlPass,
+			readHeaderTimeout: *readHeaderTimeout,
+			writeTimeout:      *writeTimeout,
+			idleTimeout:       *idleTimeout,
+			shutdownTimeout:   *shutdownTimeout,
+		}
+		portSet := false
+		flag.Visit(func(f *flag.Flag) { portSet = portSet || f.Name == "port" })
+		if (*unixSocket != "" || *onion) && !portSet {
+			opts.port = ""
+		}
+		if err := runServer(cfg, opts); err != nil {
+			log.Fatalf("server: %v", err)
+		}
+	case "keys":
+		if *keyFile == "" {
+			log.Fatalf("-mode=keys needs -keyfile")
+		}
+		runKeys(*keyFile, flag.Args())
+	case "lnperms":
+		for _, p := range ln.RequiredPermissions {
+			fmt.Println(p.String())
+		}
+		fmt.Println(ln.BakeMacaroonCommand())
 	default:
 		log.Fatalf("unknown mode: %s", *mode)
 	}
 }
 
-func fetchOnChain(client *http.Client, network btc.Network, address string, feeFallback uint64,
-	nodeOnly bool, rpcURL, rpcUser, rpcPass string,
-) (score.Result, uint64, error) {
-	feeRate := feeFallback
-	mode := "explorer"
-
-	var utxos []btc.UTXO
-	var err error
-
-	if nodeOnly {
-		mode = "nodeonly"
-		rpc := btc.NewBitcoindRPC(rpcURL, rpcUser, rpcPass, client)
-		utxos, err = rpc.UTXOsForAddress(address)
-		if err != nil {
-			return score.Result{}, 0, err
-		}
-		if est, err := rpc.EstimateSmartFee(6); err == nil && est.FeeRateBTCPerKB > 0 {
-			feeRate = planner.BTCPerKBToSatsPerVB(est.FeeRateBTCPerKB)
-		}
-	} else {
-		utxos, err = btc.FetchUTXOsExplorer(client, address, network)
-		if err != nil {
-			return score.Result{}, 0, err
-		}
+func fetchOnChain(ctx context.Context, cfg api.Config, address string) (score.Result, api.Fetched, *api.ExplorerVerification, error) {
+	fetched, err := api.FetchUTXOs(ctx, cfg, address, cfg.Network)
+	if err != nil {
+		return score.Result{}, fetched, nil, err
 	}
+	utxos := fetched.UTXOs
+
+	verification := api.VerifyExplorerUTXOs(ctx, cfg, cfg.Network, utxos)
 
 	res := score.Compute(score.Input{
 		Address:      address,
-		Network:      network,
-		Mode:         mode,
+		Network:      cfg.Network,
+		Mode:         fetched.Mode,
 		UTXOs:        utxos,
-		FeeRateSatVB: feeRate,
+		FeeRateSatVB: fetched.FeeRate,
 	})
-	return res, feeRate, nil
+	return res, fetched, verification, nil
 }
 
-func runCLI(client *http.Client, network btc.Network, address string, feeFallback, feeLow uint64,
-	nodeOnly bool, rpcURL, rpcUser, rpcPass string,
-	lnCheck bool, lndURL, macaroonPath string, lndTLSInsecure bool,
-) {
-	onchain, feeRate, err := fetchOnChain(client, network, address, feeFallback, nodeOnly, rpcURL, rpcUser, rpcPass)
+func runCLI(cfg api.Config, address string, lnCheck bool, invoice string, invoiceAmt uint64) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg.Ledger = &netx.Ledger{}
+	cfg.HTTPClient = netx.WithLedger(netx.Isolate(cfg.HTTPClient, address), cfg.Ledger)
+	onchain, fetched, verification, err := fetchOnChain(ctx, cfg, address)
 	if err != nil {
 		log.Fatalf("onchain fetch failed: %v", err)
 	}
+	feeRate := fetched.FeeRate
 
 	plan := planner.Decide(planner.Inputs{
 		NumUTXOs:    onchain.NumUTXOs,
 		DustCount:   onchain.DustUTXOs,
 		FeeNowSatVB: feeRate,
-		FeeLowSatVB: feeLow,
+		FeeLowSatVB: cfg.FeeLowSatVB,
 	})
 
 	type Output struct {
-		OnChain score.Result              `json:"onchain"`
-		Plan    planner.ConsolidationPlan `json:"consolidation_plan"`
-		LN      *ln.Readiness             `json:"ln_readiness,omitempty"`
+		OnChain     score.Result              `json:"onchain"`
+		Confidence  api.DataConfidence        `json:"data_confidence"`
+		ChainTips   btc.TipConsistency        `json:"chain_tips"`
+		NodeHealth  *score.NodeHealth         `json:"node_health,omitempty"`
+		Verified    *api.ExplorerVerification `json:"explorer_verification,omitempty"`
+		Reconciled  *api.Reconciliation       `json:"reconciliation,omitempty"`
+		Tor         *netx.TorStatus           `json:"tor,omitempty"`
+		Cache       api.CacheReport           `json:"cache"`
+		Decoys      *api.DecoyReport          `json:"decoys,omitempty"`
+		Ledger      netx.PrivacyLedger        `json:"privacy_ledger"`
+		Requests    netx.RequestStats         `json:"request_stats"`
+		Plan        planner.ConsolidationPlan `json:"consolidation_plan"`
+		ExitCosts   ln.ExitCosts              `json:"exit_costs"`
+		LN          *ln.Readiness             `json:"ln_readiness,omitempty"`
+		LNWallet    *api.LNWallet             `json:"ln_wallet,omitempty"`
+		LNRecovery  *ln.Recovery              `json:"ln_recovery,omitempty"`
+		LNReceive   *ln.ReceiveReadiness      `json:"ln_receive_readiness,omitempty"`
+		LNHealth    *ln.ChannelHealthReport   `json:"ln_channel_health,omitempty"`
+		LNVsOnChain *planner.CostComparison   `json:"ln_vs_onchain,omitempty"`
 	}
 
-	out := Output{OnChain: onchain, Plan: plan}
+	out := Output{OnChain: onchain, Plan: plan, ExitCosts: ln.NewExitCosts(onchain.EstimatedSweepFee, nil), Tor: cfg.Tor, Decoys: fetched.Decoys}
 
+	var lnr api.LNReport
 	if lnCheck {
-		if macaroonPath == "" {
-			log.Println("lncheck requested but -macaroon is empty")
-		} else {
-			lndClient := &http.Client{Timeout: 10 * time.Second}
-			c, err := ln.NewLNDClient(lndURL, macaroonPath, lndClient, lndTLSInsecure)
-			if err != nil {
-				log.Printf("lnd init error: %v", err)
-			} else {
-				info, err := c.GetInfo()
+		b, err := api.NewLNBackend(cfg)
+		switch {
+		case err != nil:
+			log.Printf("ln init error: %v", err)
+		case b == nil:
+			log.Println("lncheck requested but no lightning credentials (-macaroon, -lndconnect, -clnsocket or -clnresturl/-clnrune) given")
+		default:
+			lnr = api.CollectLN(ctx, b, cfg, cfg.Network, feeRate)
+			out.LN = lnr.Readiness
+			out.LNWallet = lnr.Wallet
+			out.LNRecovery = lnr.Recovery
+			out.LNReceive = lnr.Receive
+			out.LNHealth = lnr.Health
+			if invoice != "" && lnr.Readiness != nil {
+				probe, err := api.ProbePayment(ctx, cfg.LNBudget, b, invoice, invoiceAmt)
 				if err != nil {
-					log.Printf("lnd getinfo error: %v", err)
+					log.Printf("invoice probe error: %v", err)
 				} else {
-					ready := ln.ComputeReadiness(info)
-					out.LN = &ready
+					api.ApplyPaymentProbe(lnr.Readiness, probe)
 				}
 			}
+			out.ExitCosts = ln.NewExitCosts(onchain.EstimatedSweepFee, lnr.ExitPlan)
 		}
 	}
 
+	out.LNVsOnChain = api.CompareCosts(cfg, onchain.UTXOs, feeRate, lnr)
+
+	out.ChainTips = api.CheckChainTips(ctx, cfg, cfg.Network, lnr.Readiness)
+	out.Confidence = api.NewDataConfidence()
+	api.ApplyTipConsistency(&out.Confidence, out.ChainTips)
+	api.ApplyExplorerVerification(&out.Confidence, verification)
+	out.Verified = verification
+	if onchain.Mode == "explorer" {
+		out.Reconciled = api.ReconcileUTXOs(ctx, cfg, address, onchain.UTXOs)
+	}
+	api.ApplyReconciliation(&out.Confidence, out.Reconciled)
+	out.NodeHealth = api.CheckNode(ctx, cfg)
+
+	out.Cache = api.CacheReport{UTXOs: fetched.UTXOCache, Fee: fetched.FeeCache, LN: lnr.Cache}
+	out.Ledger = cfg.Ledger.Report()
+	out.Requests = cfg.Ledger.Stats()
+
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	_ = enc.Encode(out)
 }
 
-func runServer(client *http.Client, network btc.Network, feeFallback, feeLow uint64,
-	nodeOnly bool, rpcURL, rpcUser, rpcPass, port string,
-	lndEnabled bool, lndURL, macaroonPath string, lndTLSInsecure bool,
-) {
-	cfg := api.Config{
-		NodeOnly:        nodeOnly,
-		Network:         network,
-		FeeRateFallback: feeFallback,
-		FeeLowSatVB:     feeLow,
-		HTTPClient:      client,
+// readBatchFile parses a JSON list of batch items, or lines of
+// "<address or descriptor> [network]" with # comments.
+func readBatchFile(path string) ([]api.BatchItem, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []api.BatchItem
+	if t := bytes.TrimSpace(b); len(t) > 0 && t[0] == '[' {
+		err := json.Unmarshal(t, &items)
+		return items, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var it api.BatchItem
+		if err := json.Unmarshal([]byte(strconv.Quote(fields[0])), &it); err != nil {
+			return nil, err
+		}
+		if len(fields) > 1 {
+			it.Network = fields[1]
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
 
-		RPCURL:  rpcURL,
-		RPCUser: rpcUser,
-		RPCPass: rpcPass,
+// runBatch prints one JSON result per line as reports finish, then the
+// portfolio summary.
+func runBatch(cfg api.Config, path string) {
+	items, err := readBatchFile(path)
+	if err != nil {
+		log.Fatalf("read batch file: %v", err)
+	}
+	if len(items) == 0 {
+		log.Fatalf("batch file %s has no addresses", path)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-		LNDEnabled:     lndEnabled,
-		LNDBaseURL:     lndURL,
-		MacaroonPath:   macaroonPath,
-		LNDClient:      &http.Client{Timeout: 10 * time.Second},
-		LNDTLSInsecure: lndTLSInsecure,
+	enc := json.NewEncoder(os.Stdout)
+	sum := api.NewServer(cfg).RunBatch(ctx, items, cfg.BatchConcurrency, func(res api.BatchResult) {
+		_ = enc.Encode(res)
+	})
+	_ = enc.Encode(map[string]api.BatchSummary{"summary": sum})
+}
+
+// serverTLS returns nil for plain HTTP. With a certificate (loaded, or
+// generated with selfSigned), client certificates are requested so keys
+// bound to one can authenticate by mTLS; with clientCA they must also chain
+// to it.
+func serverTLS(certFile, keyFile, clientCA string, selfSigned bool, hosts []string) (*tls.Config, error) {
+	if certFile == "" && !selfSigned {
+		if clientCA != "" {
+			return nil, fmt.Errorf("-clientca needs -tlscert and -tlskey or -tlsselfsigned")
+		}
+		return nil, nil
+	}
+	var cert tls.Certificate
+	_, statErr := os.Stat(certFile)
+	if selfSigned && (certFile == "" || os.IsNotExist(statErr)) {
+		certPEM, keyPEM, err := api.SelfSignedCert(hosts)
+		if err != nil {
+			return nil, err
+		}
+		if certFile != "" && keyFile != "" {
+			if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+				return nil, err
+			}
+			log.Printf("wrote self-signed certificate to %s", certFile)
+		}
+		if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(cert.Certificate[0])
+		log.Printf("self-signed certificate sha256 fingerprint (pin this): %x", sum)
+	} else {
+		var err error
+		if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, err
+		}
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	if clientCA != "" {
+		b, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("client ca: no PEM certificates found")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
 	}
+	return cfg, nil
+}
+
+// runKeys manages the API key store: add, list and revoke.
+func runKeys(path string, args []string) {
+	ks, err := api.OpenKeyStore(path)
+	if err != nil {
+		log.Fatalf("open key store: %v", err)
+	}
+	usage := func() {
+		fmt.Println("Usage:")
+		fmt.Println("  go run . -mode=keys -keyfile=keys.json add -name=treasury -scopes=onchain:read,ln:read [-addresses=bc1q...,bc1q...] [-cert=client.pem [-nosecret]]")
+		fmt.Println("  go run . -mode=keys -keyfile=keys.json list")
+		fmt.Println("  go run . -mode=keys -keyfile=keys.json revoke <id>")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("add", flag.ExitOnError)
+		name := fs.String("name", "", "label for the key")
+		scopes := fs.String("scopes", api.ScopeOnchainRead, "comma-separated: onchain:read, ln:read, admin")
+		addrs := fs.String("addresses", "", "comma-separated addresses or descriptors the key may query (empty = any)")
+		certFile := fs.String("cert", "", "PEM client certificate that authenticates as this key over mTLS")
+		noSecret := fs.Bool("nosecret", false, "mTLS only: do not issue a bearer secret")
+		_ = fs.Parse(args[1:])
+		var fp string
+		if *certFile != "" {
+			b, err := os.ReadFile(*certFile)
+			if err != nil {
+				log.Fatalf("read client cert: %v", err)
+			}
+			if fp, err = api.CertFingerprint(b); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		k, secret, err := ks.Add(*name, splitList(*scopes), splitList(*addrs), fp, *noSecret)
+		if err != nil {
+			log.Fatalf("add key: %v", err)
+		}
+		fmt.Printf("added key %s (%s) scopes=%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","))
+		if secret != "" {
+			fmt.Println("secret (shown once, only its hash is stored):")
+			fmt.Println(secret)
+		}
+	case "list":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(ks.Keys())
+	case "revoke":
+		if len(args) != 2 {
+			usage()
+		}
+		if err := ks.Revoke(args[1]); err != nil {
+			log.Fatalf("revoke key: %v", err)
+		}
+		fmt.Printf("revoked key %s\n", args[1])
+	default:
+		usage()
+	}
+}
+
+type serverOpts struct {
+	port       string // "" = no TCP listener
+	tls        *tls.Config
+	unixSocket string
+	onion      bool
+	onionKey   string
+
+	torControl, torControlPass string
+
+	readHeaderTimeout, writeTimeout, idleTimeout, shutdownTimeout time.Duration
+}
+
+// runServer serves on TCP (TLS when configured), a unix socket and an onion
+// service until SIGINT/SIGTERM, then lets in-flight reports finish for up to
+// shutdownTimeout. Setup errors are returned after the listeners and onion
+// service are torn down. The unix socket and onion service speak plain HTTP: the
+// socket is owner-only and onion traffic is already end-to-end encrypted.
+func runServer(cfg api.Config, opts serverOpts) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	s := api.NewServer(cfg)
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: opts.readHeaderTimeout,
+		WriteTimeout:      opts.writeTimeout,
+		IdleTimeout:       opts.idleTimeout,
+	}
+
+	// Listeners are closed on every return; after Shutdown this is a no-op
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	if opts.port != "" {
+		l, err := net.Listen("tcp", ":"+opts.port)
+		if err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+		if opts.tls != nil {
+			l = tls.NewListener(l, opts.tls)
+		}
+		log.Printf("server listening on %s (tls=%v)", l.Addr(), opts.tls != nil)
+		listeners = append(listeners, l)
+	}
+	if opts.unixSocket != "" {
+		// A leftover socket from an unclean exit would block the bind
+		if fi, err := os.Lstat(opts.unixSocket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(opts.unixSocket)
+		}
+		l, err := net.Listen("unix", opts.unixSocket)
+		if err != nil {
+			return fmt.Errorf("listen on unix socket: %w", err)
+		}
+		listeners = append(listeners, l)
+		if err := os.Chmod(opts.unixSocket, 0o600); err != nil {
+			return fmt.Errorf("unix socket permissions: %w", err)
+		}
+		log.Printf("server listening on unix socket %s", opts.unixSocket)
+	}
+	var svc *netx.OnionService
+	if opts.onion {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("listen for onion service: %w", err)
+		}
+		listeners = append(listeners, l)
+		svc, err = netx.AddOnion(ctx, opts.torControl, opts.torControlPass, opts.onionKey, 80, l.Addr().String())
+		if err != nil {
+			return fmt.Errorf("onion service: %w", err)
+		}
+		defer svc.Close()
+		log.Printf("server listening on http://%s (via %s)", svc.Address(), l.Addr())
+	}
+	if len(listeners) == 0 {
+		return errors.New("no listener: set -port, -unixsocket or -onion")
+	}
 
-	addr := ":" + port
-	log.Printf("server listening on %s", addr)
-	log.Printf("endpoints: /health, /check, /report, /lnready")
+	log.Printf("endpoints: /health, /check, /report, /report/batch (POST), /lnready, /lnreceive")
 	log.Printf("example: /report?address=...&network=mainnet|testnet")
-	if err := http.ListenAndServe(addr, s.Handler()); err != nil {
-		log.Fatalf("server error: %v", err)
+	if cfg.Auth == nil {
+		log.Printf("warning: no -keyfile; the API, including UTXO lists and LN node data, is open to anyone who can reach it")
+	}
+
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) { errc <- srv.Serve(l) }(l)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("server error: %v", err)
+		}
+	case <-ctx.Done():
+		log.Printf("shutting down; waiting up to %s for in-flight requests", opts.shutdownTimeout)
+	}
+	stop()
+
+	sctx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		log.Printf("shutdown timed out, closing remaining connections: %v", err)
+		srv.Close()
+	}
+	log.Printf("server stopped")
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
 	}
+	return out
 }
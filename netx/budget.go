@@ -0,0 +1,44 @@
+package netx
+
+import (
+	"context"
+	"time"
+)
+
+// Budget bounds the calls made to one backend. Timeout is a single deadline
+// for the whole call, retries and backoff included (0 = only the caller's
+// deadline). Retries replaces the retry policy's MaxRetries for HTTP
+// requests made under the budget: retrying and backing off happen only in
+// the client's retry layer, which also decides what is transient, so other
+// errors (4xx, RPC errors, bad responses) come back at once.
+type Budget struct {
+	Timeout time.Duration
+	Retries int
+}
+
+type retriesKey struct{}
+
+// WithTimeout returns ctx bounded by the budget's deadline and carrying its
+// retry count.
+func (b Budget) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, retriesKey{}, b.Retries)
+	if b.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, b.Timeout)
+}
+
+// Run calls fn once within the budget.
+func (b Budget) Run(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := b.WithTimeout(ctx)
+	defer cancel()
+	return fn(ctx)
+}
+
+// retriesFrom returns the retry count of the budget ctx runs under, or def.
+func retriesFrom(ctx context.Context, def int) int {
+	if n, ok := ctx.Value(retriesKey{}).(int); ok && n >= 0 {
+		return n
+	}
+	return def
+}
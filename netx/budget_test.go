@@ -0,0 +1,96 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBudgetRetriesInOneLayer(t *testing.T) {
+	var hits atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	c := &http.Client{Transport: newResilientTransport(http.DefaultTransport, RetryPolicy{
+		MaxRetries: 5, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BreakerThreshold: 1000,
+	})}
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		run    func() error
+		want   int32
+	}{
+		{"policy retries outside a budget", 503, func() error { return get(context.Background()) }, 6},
+		{"budget retry count replaces the policy's", 503, func() error {
+			return Budget{Retries: 1}.Run(context.Background(), get)
+		}, 2},
+		{"no retries", 503, func() error { return Budget{}.Run(context.Background(), get) }, 1},
+		{"non-transient errors return at once", 404, func() error {
+			return Budget{Retries: 3}.Run(context.Background(), get)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			status = tt.status
+			if err := tt.run(); err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := hits.Load(); got != tt.want {
+				t.Errorf("server hit %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetOverallDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := &http.Client{Transport: newResilientTransport(http.DefaultTransport, RetryPolicy{
+		BaseBackoff: 50 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, BreakerThreshold: 1000,
+	})}
+
+	start := time.Now()
+	calls := 0
+	err := Budget{Timeout: 200 * time.Millisecond, Retries: 1000}.Run(context.Background(), func(ctx context.Context) error {
+		calls++
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := c.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			err = errors.New(resp.Status)
+		}
+		return err
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %v; the deadline should cover every retry", d)
+	}
+}
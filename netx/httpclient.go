@@ -1,19 +1,29 @@
 package netx
 
 import (
-	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"time"
-
-	"golang.org/x/net/proxy"
 )
 
+const dialTimeout = 10 * time.Second
+
 type ClientConfig struct {
 	Timeout       time.Duration
 	TorSocks5Addr string // e.g. 127.0.0.1:9050 or 127.0.0.1:9150
 	InsecureTLS   bool   // dev only
+	// Tor stream isolation: none, session, wallet (default) or request
+	TorIsolation string
+	// StrictTor refuses every connection that would not go through Tor
+	// (loopback excepted) instead of falling back to clearnet.
+	StrictTor bool
+	// TLSConfig overrides InsecureTLS, e.g. for a node's self-signed cert.
+	TLSConfig *tls.Config
+	// Retry enables backoff, per-host rate limiting and circuit breaking.
+	Retry *RetryPolicy
 }
 
 func NewHTTPClient(cfg ClientConfig) (*http.Client, error) {
@@ -21,25 +31,41 @@ func NewHTTPClient(cfg ClientConfig) (*http.Client, error) {
 	if timeout == 0 {
 		timeout = 15 * time.Second
 	}
-
-	baseDialer := &net.Dialer{Timeout: 10 * time.Second}
-	var dialer proxy.Dialer = baseDialer
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureTLS} // dev only
+	}
 
 	if cfg.TorSocks5Addr != "" {
-		socks, err := proxy.SOCKS5("tcp", cfg.TorSocks5Addr, nil, baseDialer)
+		isolation := cfg.TorIsolation
+		if isolation == "" {
+			isolation = IsolateWallet
+		}
+		if !ValidIsolation(isolation) {
+			return nil, fmt.Errorf("unknown tor isolation %q", isolation)
+		}
+		tr, err := newIsolatingTransport(cfg.TorSocks5Addr, isolation, tlsConfig, cfg.StrictTor)
 		if err != nil {
 			return nil, err
 		}
-		dialer = socks
+		return &http.Client{Timeout: timeout, Transport: withRetry(tr, cfg.Retry)}, nil
+	}
+	if cfg.StrictTor {
+		return nil, errors.New("strict tor mode needs a tor proxy address")
 	}
 
+	d := routedDialer{direct: &net.Dialer{Timeout: dialTimeout}}
 	tr := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			_ = ctx // SOCKS5 dialer has no context support
-			return dialer.Dial(network, addr)
-		},
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.InsecureTLS}, // dev only
+		DialContext:     d.DialContext,
+		TLSClientConfig: tlsConfig,
 	}
 
-	return &http.Client{Timeout: timeout, Transport: tr}, nil
+	return &http.Client{Timeout: timeout, Transport: withRetry(tr, cfg.Retry)}, nil
+}
+
+func withRetry(rt http.RoundTripper, p *RetryPolicy) http.RoundTripper {
+	if p == nil {
+		return rt
+	}
+	return newResilientTransport(rt, *p)
 }
@@ -0,0 +1,190 @@
+package netx
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/proxy"
+)
+
+// Tor stream isolation granularity. Tor (IsolateSOCKSAuth, on by default)
+// puts streams with different SOCKS5 credentials on different circuits.
+const (
+	IsolateNone    = "none"    // no credentials; Tor may share circuits with anything
+	IsolateSession = "session" // one random credential per process
+	IsolateWallet  = "wallet"  // one per address/wallet key, see Isolate
+	IsolateRequest = "request" // fresh credential and connection per request
+)
+
+func ValidIsolation(s string) bool {
+	switch s {
+	case IsolateNone, IsolateSession, IsolateWallet, IsolateRequest:
+		return true
+	}
+	return false
+}
+
+// maxIsolatedTransports bounds the per-key transports kept for a long-running
+// server; past it idle connections are closed and the set starts over.
+const maxIsolatedTransports = 64
+
+func randomAuth() *proxy.Auth {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return &proxy.Auth{User: hex.EncodeToString(b[:8]), Password: hex.EncodeToString(b[8:])}
+}
+
+// isolatingTransport routes requests through the Tor SOCKS5 proxy with
+// credentials chosen by the isolation granularity.
+type isolatingTransport struct {
+	socksAddr   string
+	tlsConfig   *tls.Config
+	granularity string
+	strict      bool
+	key         string // set on per-key views returned by forKey
+
+	mu     *sync.Mutex
+	byKey  map[string]*http.Transport
+	shared *http.Transport
+}
+
+func newTorTransport(socksAddr string, auth *proxy.Auth, tlsConfig *tls.Config, keepAlive, strict bool) (*http.Transport, error) {
+	base := &net.Dialer{Timeout: dialTimeout}
+	socks, err := proxy.SOCKS5("tcp", socksAddr, auth, base)
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := socks.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("socks5 dialer has no context support")
+	}
+	d := routedDialer{direct: base, tor: cd, strict: strict}
+	return &http.Transport{
+		DialContext:       d.DialContext,
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: !keepAlive,
+	}, nil
+}
+
+func newIsolatingTransport(socksAddr, granularity string, tlsConfig *tls.Config, strict bool) (*isolatingTransport, error) {
+	var auth *proxy.Auth
+	if granularity != IsolateNone {
+		auth = randomAuth()
+	}
+	shared, err := newTorTransport(socksAddr, auth, tlsConfig, true, strict)
+	if err != nil {
+		return nil, err
+	}
+	return &isolatingTransport{
+		socksAddr:   socksAddr,
+		tlsConfig:   tlsConfig,
+		granularity: granularity,
+		strict:      strict,
+		mu:          &sync.Mutex{},
+		byKey:       map[string]*http.Transport{},
+		shared:      shared,
+	}, nil
+}
+
+func (t *isolatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case t.granularity == IsolateRequest:
+		tr, err := newTorTransport(t.socksAddr, randomAuth(), t.tlsConfig, false, t.strict)
+		if err != nil {
+			return nil, err
+		}
+		return tr.RoundTrip(req)
+	case t.granularity == IsolateWallet && t.key != "":
+		tr, err := t.transportFor(t.key)
+		if err != nil {
+			return nil, err
+		}
+		return tr.RoundTrip(req)
+	default:
+		return t.shared.RoundTrip(req)
+	}
+}
+
+func (t *isolatingTransport) transportFor(key string) (*http.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr, ok := t.byKey[key]; ok {
+		return tr, nil
+	}
+	if len(t.byKey) >= maxIsolatedTransports {
+		for _, tr := range t.byKey {
+			tr.CloseIdleConnections()
+		}
+		t.byKey = map[string]*http.Transport{}
+	}
+	tr, err := newTorTransport(t.socksAddr, randomAuth(), t.tlsConfig, true, t.strict)
+	if err != nil {
+		return nil, err
+	}
+	t.byKey[key] = tr
+	return tr, nil
+}
+
+// Isolate returns a client whose Tor streams are isolated under key (an
+// address or wallet identifier) when the client was built with wallet
+// granularity. Otherwise c is returned unchanged.
+func Isolate(c *http.Client, key string) *http.Client {
+	return isolateClient(c, key, false)
+}
+
+// IsolateQuery gives key its own circuit under any granularity except
+// request (already stricter), for queries that must not be linked to each
+// other, such as decoys.
+func IsolateQuery(c *http.Client, key string) *http.Client {
+	return isolateClient(c, key, true)
+}
+
+func isolateClient(c *http.Client, key string, force bool) *http.Client {
+	if c == nil {
+		return nil
+	}
+	rt := isolate(c.Transport, key, force)
+	if rt == c.Transport {
+		return c
+	}
+	cc := *c
+	cc.Transport = rt
+	return &cc
+}
+
+// isolate returns a view of rt keyed for isolation, unwrapping the retry and
+// ledger layers, or rt itself when nothing changes.
+func isolate(rt http.RoundTripper, key string, force bool) http.RoundTripper {
+	switch t := rt.(type) {
+	case *isolatingTransport:
+		if t.granularity == IsolateRequest || (t.granularity != IsolateWallet && !force) {
+			return rt
+		}
+		view := *t
+		view.granularity = IsolateWallet
+		view.key = key
+		return &view
+	case *resilientTransport:
+		next := isolate(t.next, key, force)
+		if next == t.next {
+			return rt
+		}
+		view := *t
+		view.next = next
+		return &view
+	case *ledgerTransport:
+		next := isolate(t.next, key, force)
+		if next == t.next {
+			return rt
+		}
+		view := *t
+		view.next = next
+		return &view
+	}
+	return rt
+}
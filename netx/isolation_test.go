@@ -0,0 +1,243 @@
+package netx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSOCKS is a SOCKS5 proxy that answers every CONNECT itself with a tiny
+// HTTP server and records the username each HTTP request arrived under. A
+// path containing "flaky" fails with 503 the first time it is seen.
+type fakeSOCKS struct {
+	l    net.Listener
+	mu   sync.Mutex
+	seen map[string]bool
+	hits []socksHit
+}
+
+type socksHit struct {
+	user, target, path string
+}
+
+func newFakeSOCKS(t *testing.T) *fakeSOCKS {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSOCKS{l: l, seen: map[string]bool{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSOCKS) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	user, target, err := socksServerHandshake(r, c)
+	if err != nil {
+		return
+	}
+	for {
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		s.mu.Lock()
+		s.hits = append(s.hits, socksHit{user: user, target: target, path: req.URL.Path})
+		status := "200 OK"
+		if strings.Contains(req.URL.Path, "flaky") && !s.seen[req.URL.Path] {
+			status = "503 Service Unavailable"
+		}
+		s.seen[req.URL.Path] = true
+		s.mu.Unlock()
+		fmt.Fprintf(c, "HTTP/1.1 %s\r\nContent-Length: 2\r\n\r\nok", status)
+	}
+}
+
+// socksServerHandshake speaks the server side of RFC 1928/1929 and returns the
+// username ("" without auth) and the requested host:port.
+func socksServerHandshake(r *bufio.Reader, w io.Writer) (user, target string, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(r, head); err != nil {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err = io.ReadFull(r, methods); err != nil {
+		return
+	}
+	method := byte(0)
+	if strings.IndexByte(string(methods), 2) >= 0 {
+		method = 2
+	}
+	w.Write([]byte{5, method})
+	if method == 2 {
+		var u []byte
+		if u, err = readField(r, 1); err != nil {
+			return
+		}
+		if _, err = readField(r, 0); err != nil { // password
+			return
+		}
+		user = string(u)
+		w.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err = io.ReadFull(r, req); err != nil {
+		return
+	}
+	var host []byte
+	switch req[3] {
+	case 1:
+		host = make([]byte, 4)
+		_, err = io.ReadFull(r, host)
+		host = []byte(net.IP(host).String())
+	case 3:
+		host, err = readField(r, 0)
+	default:
+		err = fmt.Errorf("address type %d", req[3])
+	}
+	if err != nil {
+		return
+	}
+	port := make([]byte, 2)
+	if _, err = io.ReadFull(r, port); err != nil {
+		return
+	}
+	w.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return user, net.JoinHostPort(string(host), fmt.Sprint(binary.BigEndian.Uint16(port))), nil
+}
+
+// readField reads a length-prefixed field after skipping skip bytes.
+func readField(r *bufio.Reader, skip int) ([]byte, error) {
+	if _, err := r.Discard(skip); err != nil {
+		return nil, err
+	}
+	n, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// users returns the username each request for path was sent under.
+func (s *fakeSOCKS) users(path string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, h := range s.hits {
+		if h.path == "/"+path {
+			out = append(out, h.user)
+		}
+	}
+	return out
+}
+
+func TestIsolationCredentials(t *testing.T) {
+	get := func(t *testing.T, c *http.Client, path string) string {
+		t.Helper()
+		resp, err := c.Get("http://explorer.example/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", path, resp.StatusCode)
+		}
+		return path
+	}
+
+	for _, g := range []string{IsolateNone, IsolateSession, IsolateWallet, IsolateRequest} {
+		t.Run(g, func(t *testing.T) {
+			s := newFakeSOCKS(t)
+			c, err := NewHTTPClient(ClientConfig{
+				TorSocks5Addr: s.l.Addr().String(),
+				TorIsolation:  g,
+				Retry:         &RetryPolicy{MaxRetries: 2, BaseBackoff: time.Millisecond},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ledger := &Ledger{}
+			// Both layerings occur in the tree: isolate then log, and the
+			// other way round; isolation must see through either.
+			walletA := WithLedger(Isolate(c, "addrA"), ledger)
+			walletB := Isolate(WithLedger(c, ledger), "addrB")
+			decoy := IsolateQuery(WithLedger(c, ledger), "decoy1")
+
+			plain := s.users(get(t, c, "plain"))
+			a1 := s.users(get(t, walletA, "a1"))
+			a2 := s.users(get(t, walletA, "a2-flaky")) // 503 then retried
+			b := s.users(get(t, walletB, "b"))
+			d := s.users(get(t, decoy, "decoy"))
+
+			if len(a2) != 2 {
+				t.Fatalf("flaky request seen %d times, want a retry", len(a2))
+			}
+			if len(ledger.Entries()) != 4 {
+				t.Errorf("ledger has %d entries, want 4", len(ledger.Entries()))
+			}
+			for _, h := range s.hits {
+				if h.target != "explorer.example:80" {
+					t.Errorf("CONNECT target %q, want the hostname unresolved", h.target)
+				}
+			}
+
+			same := func(x, y string) bool { return x == y }
+			switch g {
+			case IsolateNone:
+				for _, u := range append(append(append(plain, a1...), a2...), b...) {
+					if u != "" {
+						t.Errorf("credential %q sent without isolation", u)
+					}
+				}
+				if d[0] == "" {
+					t.Errorf("none: decoy query not isolated")
+				}
+			case IsolateSession:
+				if plain[0] == "" || !same(plain[0], a1[0]) || !same(a1[0], b[0]) {
+					t.Errorf("session: plain %v, wallet A %v, wallet B %v should share one credential", plain, a1, b)
+				}
+				if same(d[0], plain[0]) {
+					t.Errorf("session: decoy query shares the session credential")
+				}
+			case IsolateWallet:
+				if !same(a1[0], a2[0]) || !same(a2[0], a2[1]) {
+					t.Errorf("wallet: wallet A used %v and %v, want one credential incl. retries", a1, a2)
+				}
+				if same(a1[0], b[0]) || same(a1[0], plain[0]) || same(b[0], plain[0]) || same(d[0], plain[0]) {
+					t.Errorf("wallet: credentials not distinct: plain %v A %v B %v decoy %v", plain, a1, b, d)
+				}
+			case IsolateRequest:
+				all := append(append(append(append(plain, a1...), a2...), b...), d...)
+				seen := map[string]bool{}
+				for _, u := range all {
+					if u == "" || seen[u] {
+						t.Errorf("request: credential %q empty or reused in %v", u, all)
+					}
+					seen[u] = true
+				}
+			}
+		})
+	}
+}
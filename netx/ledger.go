@@ -0,0 +1,418 @@
+package netx
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LedgerEntry records one outbound request and what identifying data it carried.
+type LedgerEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Host   string    `json:"host"` // the host that answered, a fallback if one was used
+	// Host the request was addressed to, when a fallback served it instead
+	RequestedHost string   `json:"requested_host,omitempty"`
+	ViaTor        bool     `json:"via_tor"`
+	Local         bool     `json:"local"` // loopback: never left the machine
+	Addresses     []string `json:"addresses,omitempty"`
+	TxIDs         []string `json:"txids,omitempty"`
+	XPubs         []string `json:"xpubs,omitempty"`
+	Status        int      `json:"status,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	// Tries on the wire, recorded when retries are enabled
+	Attempts []Attempt `json:"attempts,omitempty"`
+	NotSent  bool      `json:"not_sent,omitempty"` // refused locally: strict Tor or an open breaker
+}
+
+// contacted lists the hosts that actually received the request.
+func (e LedgerEntry) contacted() []string {
+	if e.NotSent {
+		return nil
+	}
+	if len(e.Attempts) == 0 {
+		return []string{e.Host}
+	}
+	var hosts []string
+	for _, a := range e.Attempts {
+		hosts = append(hosts, a.Host)
+	}
+	return uniq(hosts)
+}
+
+// Ledger collects the outbound requests of one report run.
+type Ledger struct {
+	mu        sync.Mutex
+	entries   []LedgerEntry
+	fallbacks []string
+	decoys    map[string]bool
+}
+
+// Decoys marks addresses queried only as cover; they are listed in the
+// ledger but do not count against the exposure score.
+func (l *Ledger) Decoys(addrs ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.decoys == nil {
+		l.decoys = map[string]bool{}
+	}
+	for _, a := range addrs {
+		l.decoys[a] = true
+	}
+	l.mu.Unlock()
+}
+
+// Record adds e; like the other methods it is a no-op on a nil ledger.
+func (l *Ledger) Record(e LedgerEntry) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.entries = append(l.entries, e)
+	l.mu.Unlock()
+}
+
+// Fallback records that a source was replaced by another for this run.
+func (l *Ledger) Fallback(from, to, reason string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.fallbacks = append(l.fallbacks, fmt.Sprintf("%s -> %s: %s", from, to, reason))
+	l.mu.Unlock()
+}
+
+func (l *Ledger) Entries() []LedgerEntry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+var (
+	reBech32 = regexp.MustCompile(`\b(?:bc|tb|bcrt)1[02-9ac-hj-np-z]{6,87}\b`)
+	reBase58 = regexp.MustCompile(`\b[123mn][1-9A-HJ-NP-Za-km-z]{25,34}\b`)
+	reXPub   = regexp.MustCompile(`\b[xtyzuvYZUV]pub[1-9A-HJ-NP-Za-km-z]{100,112}\b`)
+	reHash   = regexp.MustCompile(`(block/|block-height/)?\b([0-9a-f]{64})\b`)
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Check reports whether s decodes with a valid double-SHA256 checksum,
+// which filters out words that merely look like legacy addresses.
+func base58Check(s string) bool {
+	n := new(big.Int)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return false
+		}
+		n.Mul(n, big.NewInt(58)).Add(n, big.NewInt(int64(i)))
+	}
+	b := n.Bytes()
+	for _, c := range s {
+		if c != '1' {
+			break
+		}
+		b = append([]byte{0}, b...)
+	}
+	if len(b) < 5 {
+		return false
+	}
+	h := sha256.Sum256(b[:len(b)-4])
+	h = sha256.Sum256(h[:])
+	return string(h[:4]) == string(b[len(b)-4:])
+}
+
+func uniq(v []string) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	sort.Strings(v)
+	out := v[:1]
+	for _, s := range v[1:] {
+		if s != out[len(out)-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// scanIdentifiers finds addresses, txids and xpubs in s. 64-hex strings after
+// block/ are block hashes and are not counted.
+func scanIdentifiers(s string) (addrs, txids, xpubs []string) {
+	xpubs = reXPub.FindAllString(s, -1)
+	for _, x := range xpubs {
+		s = strings.ReplaceAll(s, x, "")
+	}
+	addrs = reBech32.FindAllString(strings.ToLower(s), -1)
+	for _, a := range reBase58.FindAllString(s, -1) {
+		if base58Check(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	for _, m := range reHash.FindAllStringSubmatch(s, -1) {
+		if m[1] == "" {
+			txids = append(txids, m[2])
+		}
+	}
+	return uniq(addrs), uniq(txids), uniq(xpubs)
+}
+
+type ledgerTransport struct {
+	next   http.RoundTripper
+	ledger *Ledger
+}
+
+func (t *ledgerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := "443"
+		if req.URL.Scheme == "http" {
+			port = "80"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	text := req.URL.Path + "?" + req.URL.RawQuery
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+			body.Close()
+			text += " " + string(b)
+		}
+	}
+	e := LedgerEntry{
+		Time:   time.Now().UTC(),
+		Method: req.Method,
+		Host:   req.URL.Hostname(),
+		Local:  isLoopback(host),
+		ViaTor: routesViaTor(t.next, host),
+	}
+	e.Addresses, e.TxIDs, e.XPubs = scanIdentifiers(text)
+
+	attempts := &attemptLog{}
+	resp, err := t.next.RoundTrip(req.WithContext(withAttemptLog(req.Context(), attempts)))
+	e.Attempts = attempts.attempts
+	if n := len(e.Attempts); n > 0 && e.Attempts[n-1].Host != e.Host {
+		e.RequestedHost, e.Host = e.Host, e.Attempts[n-1].Host
+	}
+	if err != nil {
+		e.Error = err.Error()
+		e.NotSent = errors.Is(err, ErrNotTor) || (errors.Is(err, ErrCircuitOpen) && len(e.Attempts) == 0)
+	} else {
+		e.Status = resp.StatusCode
+	}
+	t.ledger.Record(e)
+	return resp, err
+}
+
+func routesViaTor(rt http.RoundTripper, hostport string) bool {
+	switch t := rt.(type) {
+	case *resilientTransport:
+		return routesViaTor(t.next, hostport)
+	case *ledgerTransport:
+		return routesViaTor(t.next, hostport)
+	}
+	it, ok := rt.(*isolatingTransport)
+	if !ok || isLoopback(hostport) {
+		return false
+	}
+	return !isPrivate(hostport) || it.strict
+}
+
+// WithLedger returns a copy of c that records every request in l.
+func WithLedger(c *http.Client, l *Ledger) *http.Client {
+	if c == nil || l == nil {
+		return c
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	cc := *c
+	cc.Transport = &ledgerTransport{next: next, ledger: l}
+	return &cc
+}
+
+type HostExposure struct {
+	Host      string `json:"host"`
+	ViaTor    bool   `json:"via_tor"`
+	Requests  int    `json:"requests"`
+	Addresses int    `json:"addresses"`
+	Decoys    int    `json:"decoy_addresses,omitempty"`
+	TxIDs     int    `json:"txids"`
+	XPubs     int    `json:"xpubs"`
+}
+
+// Exposure summarizes a ledger. Score starts at 100 and loses points per
+// distinct identifier revealed to a remote host, far more over clearnet.
+type Exposure struct {
+	Score      int            `json:"score"`
+	Requests   int            `json:"requests"`
+	Remote     int            `json:"remote_requests"`
+	Hosts      []HostExposure `json:"hosts"`
+	Statements []string       `json:"statements"`
+}
+
+type PrivacyLedger struct {
+	Entries  []LedgerEntry `json:"entries"`
+	Exposure Exposure      `json:"exposure"`
+}
+
+// Report lists the entries and scores their exposure; a nil ledger reports
+// as an empty one.
+func (l *Ledger) Report() PrivacyLedger {
+	if l == nil {
+		l = &Ledger{}
+	}
+	entries := l.Entries()
+	exp := Exposure{Score: 100, Requests: len(entries), Hosts: []HostExposure{}, Statements: []string{}}
+
+	type key struct {
+		host string
+		tor  bool
+	}
+	type seen struct {
+		requests                    int
+		addrs, decoys, txids, xpubs map[string]bool
+	}
+	l.mu.Lock()
+	decoys := l.decoys
+	l.mu.Unlock()
+	byHost := map[key]*seen{}
+	var order []key
+	for _, e := range entries {
+		hosts := e.contacted()
+		if e.Local || len(hosts) == 0 {
+			continue
+		}
+		exp.Remote++
+		for _, host := range hosts {
+			k := key{host, e.ViaTor}
+			s := byHost[k]
+			if s == nil {
+				s = &seen{addrs: map[string]bool{}, decoys: map[string]bool{}, txids: map[string]bool{}, xpubs: map[string]bool{}}
+				byHost[k] = s
+				order = append(order, k)
+			}
+			s.requests++
+			for _, a := range e.Addresses {
+				if decoys[a] {
+					s.decoys[a] = true
+				} else {
+					s.addrs[a] = true
+				}
+			}
+			for _, t := range e.TxIDs {
+				s.txids[t] = true
+			}
+			for _, x := range e.XPubs {
+				s.xpubs[x] = true
+			}
+		}
+	}
+
+	for _, k := range order {
+		s := byHost[k]
+		h := HostExposure{Host: k.host, ViaTor: k.tor, Requests: s.requests,
+			Addresses: len(s.addrs), Decoys: len(s.decoys), TxIDs: len(s.txids), XPubs: len(s.xpubs)}
+		exp.Hosts = append(exp.Hosts, h)
+
+		addrCost, txCost, xpubCost, route := 10, 5, 30, "clearnet"
+		if k.tor {
+			addrCost, txCost, xpubCost, route = 3, 1, 10, "Tor"
+		}
+		exp.Score -= h.Addresses*addrCost + h.TxIDs*txCost + h.XPubs*xpubCost
+
+		var parts []string
+		if h.XPubs > 0 {
+			parts = append(parts, fmt.Sprintf("%d xpubs", h.XPubs))
+		}
+		if h.Addresses > 0 {
+			parts = append(parts, fmt.Sprintf("%d addresses", h.Addresses))
+		}
+		if h.TxIDs > 0 {
+			parts = append(parts, fmt.Sprintf("%d txids", h.TxIDs))
+		}
+		if h.Decoys > 0 {
+			parts = append(parts, fmt.Sprintf("%d decoy addresses", h.Decoys))
+		}
+		if len(parts) > 0 {
+			exp.Statements = append(exp.Statements, fmt.Sprintf("%s revealed to %s over %s", strings.Join(parts, ", "), k.host, route))
+		}
+	}
+	if exp.Score < 0 {
+		exp.Score = 0
+	}
+	if entries == nil {
+		entries = []LedgerEntry{}
+	}
+	return PrivacyLedger{Entries: entries, Exposure: exp}
+}
+
+// RequestStats summarizes retries, rate limiting and fallbacks in a ledger.
+type RequestStats struct {
+	Requests     int      `json:"requests"`
+	Attempts     int      `json:"attempts"`
+	Retries      int      `json:"retries"`
+	RateLimited  int      `json:"rate_limited"`  // 429 responses
+	ServerErrors int      `json:"server_errors"` // 502-504 responses
+	CircuitOpen  int      `json:"circuit_open"`  // requests failed by an open breaker
+	WaitedMs     int64    `json:"waited_ms"`
+	Fallbacks    []string `json:"fallbacks"`
+}
+
+func (l *Ledger) Stats() RequestStats {
+	if l == nil {
+		l = &Ledger{}
+	}
+	entries := l.Entries()
+	st := RequestStats{Requests: len(entries)}
+	l.mu.Lock()
+	fallbacks := append([]string(nil), l.fallbacks...)
+	l.mu.Unlock()
+	for _, e := range entries {
+		n := len(e.Attempts)
+		if n == 0 && !e.NotSent {
+			n = 1
+		}
+		st.Attempts += n
+		if n > 1 {
+			st.Retries += n - 1
+		}
+		if strings.Contains(e.Error, ErrCircuitOpen.Error()) {
+			st.CircuitOpen++
+		}
+		for _, a := range e.Attempts {
+			st.WaitedMs += a.WaitMs
+			switch {
+			case a.Status == http.StatusTooManyRequests:
+				st.RateLimited++
+			case retryableStatus(a.Status):
+				st.ServerErrors++
+			}
+			if a.Fallback {
+				fallbacks = append(fallbacks, fmt.Sprintf("%s -> %s: circuit breaker open", e.RequestedHost, a.Host))
+			}
+		}
+	}
+	st.Fallbacks = uniq(fallbacks)
+	if st.Fallbacks == nil {
+		st.Fallbacks = []string{}
+	}
+	return st
+}
@@ -0,0 +1,51 @@
+package netx
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNilLedger(t *testing.T) {
+	var l *Ledger
+	l.Record(LedgerEntry{Host: "example.com"})
+	l.Decoys("bc1q")
+	l.Fallback("explorer", "bitcoind", "breaker open")
+	if e := l.Entries(); len(e) != 0 {
+		t.Errorf("entries = %v", e)
+	}
+	if r := l.Report(); r.Exposure.Score != 100 || r.Entries == nil || r.Exposure.Hosts == nil {
+		t.Errorf("report = %+v", r)
+	}
+	if st := l.Stats(); st.Requests != 0 || st.Fallbacks == nil {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestRoutesViaTorThroughLayers(t *testing.T) {
+	c, err := NewHTTPClient(ClientConfig{TorSocks5Addr: "127.0.0.1:9050", Retry: &RetryPolicy{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clear, err := NewHTTPClient(ClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Ledger{}
+	tests := []struct {
+		name string
+		rt   http.RoundTripper
+		host string
+		want bool
+	}{
+		{"tor client", c.Transport, "blockstream.info:443", true},
+		{"one ledger", WithLedger(c, l).Transport, "blockstream.info:443", true},
+		{"ledger over ledger", WithLedger(WithLedger(Isolate(c, "a"), l), l).Transport, "blockstream.info:443", true},
+		{"loopback", WithLedger(WithLedger(c, l), l).Transport, "127.0.0.1:8332", false},
+		{"clearnet", WithLedger(WithLedger(clear, l), l).Transport, "blockstream.info:443", false},
+	}
+	for _, tt := range tests {
+		if got := routesViaTor(tt.rt, tt.host); got != tt.want {
+			t.Errorf("%s: routesViaTor = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
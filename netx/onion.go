@@ -0,0 +1,158 @@
+package netx
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// OnionService is an onion service created over the Tor control port. Tor
+// removes it when the control connection closes.
+type OnionService struct {
+	ServiceID string
+	conn      net.Conn
+	r         *bufio.Reader
+}
+
+// Address returns the service's .onion hostname.
+func (o *OnionService) Address() string { return o.ServiceID + ".onion" }
+
+// Close removes the service from Tor.
+func (o *OnionService) Close() error {
+	_ = o.conn.SetDeadline(time.Now().Add(dialTimeout))
+	_, _ = o.command("DEL_ONION " + o.ServiceID)
+	return o.conn.Close()
+}
+
+// command sends one control port command and returns its reply lines
+// without status codes, failing on a non-250 reply.
+func (o *OnionService) command(cmd string) ([]string, error) {
+	if _, err := o.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := o.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return nil, fmt.Errorf("tor control: malformed reply %q", line)
+		}
+		if line[:3] != "250" {
+			return nil, fmt.Errorf("tor control: %s", line)
+		}
+		lines = append(lines, line[4:])
+		if line[3] == ' ' {
+			return lines, nil
+		}
+	}
+}
+
+// authenticate picks the first method the control port offers that we can
+// use: none, cookie file or password.
+func (o *OnionService) authenticate(password string) error {
+	lines, err := o.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	var methods, cookieFile string
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "AUTH ") {
+			continue
+		}
+		for _, f := range strings.Fields(l[len("AUTH "):]) {
+			switch {
+			case strings.HasPrefix(f, "METHODS="):
+				methods = strings.TrimPrefix(f, "METHODS=")
+			case strings.HasPrefix(f, "COOKIEFILE="):
+				cookieFile = strings.Trim(strings.TrimPrefix(f, "COOKIEFILE="), `"`)
+			}
+		}
+	}
+	has := func(m string) bool {
+		for _, x := range strings.Split(methods, ",") {
+			if x == m {
+				return true
+			}
+		}
+		return false
+	}
+
+	var auth string
+	switch {
+	case has("NULL"):
+		auth = "AUTHENTICATE"
+	case password != "" && has("HASHEDPASSWORD"):
+		auth = `AUTHENTICATE "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password) + `"`
+	case has("COOKIE") && cookieFile != "":
+		cookie, err := os.ReadFile(cookieFile)
+		if err != nil {
+			return fmt.Errorf("tor control cookie: %w", err)
+		}
+		auth = "AUTHENTICATE " + hex.EncodeToString(cookie)
+	default:
+		return fmt.Errorf("tor control: no usable auth method (offered %s)", methods)
+	}
+	_, err = o.command(auth)
+	return err
+}
+
+// AddOnion creates an onion service forwarding virtPort to target (a local
+// host:port). The service key is read from keyFile, or generated and saved
+// there (owner-only) so the address survives restarts; with no keyFile the
+// address is new each time.
+func AddOnion(ctx context.Context, controlAddr, password, keyFile string, virtPort int, target string) (*OnionService, error) {
+	if controlAddr == "" {
+		return nil, errors.New("onion service needs the tor control port (-torcontrol)")
+	}
+	c, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", controlAddr)
+	if err != nil {
+		return nil, err
+	}
+	o := &OnionService{conn: c, r: bufio.NewReader(c)}
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	if err := o.authenticate(password); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	key := "NEW:ED25519-V3"
+	if keyFile != "" {
+		if b, err := os.ReadFile(keyFile); err == nil {
+			key = strings.TrimSpace(string(b))
+		} else if !os.IsNotExist(err) {
+			c.Close()
+			return nil, fmt.Errorf("onion key: %w", err)
+		}
+	}
+	lines, err := o.command(fmt.Sprintf("ADD_ONION %s Port=%d,%s", key, virtPort, target))
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "ServiceID="):
+			o.ServiceID = strings.TrimPrefix(l, "ServiceID=")
+		case strings.HasPrefix(l, "PrivateKey=") && keyFile != "":
+			if err := os.WriteFile(keyFile, []byte(strings.TrimPrefix(l, "PrivateKey=")+"\n"), 0o600); err != nil {
+				o.Close()
+				return nil, fmt.Errorf("save onion key: %w", err)
+			}
+		}
+	}
+	if o.ServiceID == "" {
+		o.Close()
+		return nil, errors.New("tor control: ADD_ONION returned no service id")
+	}
+	_ = c.SetDeadline(time.Time{})
+	return o, nil
+}
@@ -0,0 +1,326 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a host's circuit breaker is open and no
+// fallback host is available.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// RetryPolicy configures retries, per-host rate limiting and circuit breaking
+// for outbound requests. Zero fields take the defaults in withDefaults.
+type RetryPolicy struct {
+	MaxRetries    int           // per request, on 429, 502-504 and transport errors
+	BaseBackoff   time.Duration // doubled per retry, with jitter
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration // longer Retry-After values are not waited out
+	RatePerHost   float64       // token bucket refill in requests/second; 0 = unlimited; loopback exempt
+	Burst         int
+	// Consecutive failures that open a host's breaker, and how long it stays open.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Fallbacks maps a host to an equivalent host (same paths) used while
+	// the first one's breaker is open.
+	Fallbacks map[string]string
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.BaseBackoff <= 0 {
+		p.BaseBackoff = 500 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 8 * time.Second
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = 30 * time.Second
+	}
+	if p.Burst <= 0 {
+		p.Burst = 5
+	}
+	if p.BreakerThreshold <= 0 {
+		p.BreakerThreshold = 5
+	}
+	if p.BreakerCooldown <= 0 {
+		p.BreakerCooldown = time.Minute
+	}
+	return p
+}
+
+// Attempt is one try of a request on the wire.
+type Attempt struct {
+	Host     string `json:"host"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+	WaitMs   int64  `json:"wait_ms,omitempty"` // rate limit and backoff before this attempt
+	Fallback bool   `json:"fallback,omitempty"`
+}
+
+type attemptLogKey struct{}
+
+type attemptLog struct {
+	mu       sync.Mutex
+	attempts []Attempt
+}
+
+func (l *attemptLog) add(a Attempt) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.attempts = append(l.attempts, a)
+	l.mu.Unlock()
+}
+
+func withAttemptLog(ctx context.Context, l *attemptLog) context.Context {
+	return context.WithValue(ctx, attemptLogKey{}, l)
+}
+
+func attemptLogFrom(ctx context.Context) *attemptLog {
+	l, _ := ctx.Value(attemptLogKey{}).(*attemptLog)
+	return l
+}
+
+type hostState struct {
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	failures  int
+	openUntil time.Time
+}
+
+// reserve takes a token from the bucket and returns how long to wait for it.
+func (h *hostState) reserve(rate float64, burst int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if h.last.IsZero() {
+		h.tokens = float64(burst)
+	} else {
+		h.tokens += now.Sub(h.last).Seconds() * rate
+		if h.tokens > float64(burst) {
+			h.tokens = float64(burst)
+		}
+	}
+	h.last = now
+	h.tokens--
+	if h.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-h.tokens / rate * float64(time.Second))
+}
+
+func (h *hostState) open() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Now().Before(h.openUntil)
+}
+
+func (h *hostState) success() {
+	h.mu.Lock()
+	h.failures = 0
+	h.mu.Unlock()
+}
+
+// failure counts a failed attempt and reports whether it opened the breaker.
+// Once open, a failure after the cooldown (half-open) reopens it at once.
+func (h *hostState) failure(threshold int, cooldown time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	if h.failures < threshold {
+		return false
+	}
+	h.openUntil = time.Now().Add(cooldown)
+	return true
+}
+
+type hostStates struct {
+	mu     sync.Mutex
+	byHost map[string]*hostState
+}
+
+func (s *hostStates) get(host string) *hostState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.byHost[host]
+	if !ok {
+		h = &hostState{}
+		s.byHost[host] = h
+	}
+	return h
+}
+
+// resilientTransport retries transient failures with backoff, paces requests
+// per host and stops sending to hosts that keep failing.
+type resilientTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+	hosts  *hostStates
+}
+
+func newResilientTransport(next http.RoundTripper, p RetryPolicy) *resilientTransport {
+	return &resilientTransport{next: next, policy: p.withDefaults(), hosts: &hostStates{byHost: map[string]*hostState{}}}
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// retryAfter parses Retry-After as seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func (t *resilientTransport) backoff(retry int) time.Duration {
+	d := t.policy.BaseBackoff << retry
+	if d > t.policy.MaxBackoff || d <= 0 {
+		d = t.policy.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.try(req, false)
+	if errors.Is(err, ErrCircuitOpen) {
+		if alt, ok := t.policy.Fallbacks[req.URL.Hostname()]; ok && !t.hosts.get(alt).open() {
+			r := req.Clone(req.Context())
+			r.URL.Host, r.Host = alt, ""
+			if req.GetBody != nil {
+				if r.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			return t.try(r, true)
+		}
+	}
+	return resp, err
+}
+
+// try sends req to its host, retrying within the policy. It returns
+// ErrCircuitOpen when the host's breaker is or becomes open.
+func (t *resilientTransport) try(req *http.Request, fallback bool) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Hostname()
+	h := t.hosts.get(host)
+	attempts := attemptLogFrom(ctx)
+	if h.open() {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	maxRetries := retriesFrom(ctx, t.policy.MaxRetries)
+
+	rate := t.policy.RatePerHost
+	if isLoopback(req.URL.Host) {
+		rate = 0 // the local node is not rate limited
+	}
+
+	var wait time.Duration
+	for retry := 0; ; retry++ {
+		wait += h.reserve(rate, t.policy.Burst)
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, err
+		}
+		r := req
+		if retry > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		resp, err := t.next.RoundTrip(r)
+
+		a := Attempt{Host: host, WaitMs: wait.Milliseconds(), Fallback: fallback && retry == 0}
+		failed := false
+		switch {
+		case err != nil:
+			a.Error = err.Error()
+			failed = ctx.Err() == nil && !errors.Is(err, ErrNotTor)
+		case retryableStatus(resp.StatusCode):
+			a.Status = resp.StatusCode
+			failed = true
+		default:
+			a.Status = resp.StatusCode
+		}
+		attempts.add(a)
+		if !failed {
+			if err == nil {
+				h.success()
+			}
+			return resp, err
+		}
+
+		if h.failure(t.policy.BreakerThreshold, t.policy.BreakerCooldown) {
+			if resp != nil {
+				drain(resp)
+			}
+			return nil, fmt.Errorf("%w: %s after %d attempt(s)", ErrCircuitOpen, host, retry+1)
+		}
+
+		wait = t.backoff(retry)
+		if resp != nil {
+			if ra, ok := retryAfter(resp); ok {
+				if ra > t.policy.MaxRetryAfter {
+					return resp, nil
+				}
+				if ra > wait {
+					wait = ra
+				}
+			}
+		}
+		deadline, hasDeadline := ctx.Deadline()
+		if retry >= maxRetries || !replayable || (hasDeadline && time.Now().Add(wait).After(deadline)) {
+			return resp, err
+		}
+		if resp != nil {
+			drain(resp)
+		}
+	}
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,212 @@
+package netx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// hostRouter sends each request to the test server registered for its host
+// name, so a fallback host can be told apart from the primary.
+type hostRouter map[string]*httptest.Server
+
+func (h hostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	srv, ok := h[req.URL.Hostname()]
+	if !ok {
+		return nil, fmt.Errorf("no route to %s", req.URL.Host)
+	}
+	r := req.Clone(req.Context())
+	r.URL.Host = srv.Listener.Addr().String()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// statusServer answers with statuses in turn, repeating the last one, and
+// sets Retry-After when retryAfter is not empty.
+func statusServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(hits.Add(1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		if retryAfter != "" && statuses[i] != http.StatusOK {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(statuses[i])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func ledgeredGet(t *testing.T, rt http.RoundTripper, url string) (*Ledger, *http.Response, error) {
+	t.Helper()
+	l := &Ledger{}
+	req, _ := http.NewRequest("GET", url, nil)
+	resp, err := (&http.Client{Transport: &ledgerTransport{next: rt, ledger: l}}).Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return l, resp, err
+}
+
+func TestResilientRetries(t *testing.T) {
+	fast := RetryPolicy{MaxRetries: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetryAfter: 2 * time.Second}
+	tests := []struct {
+		name       string
+		retryAfter string
+		statuses   []int
+		want       []int // status of each attempt
+		minWait    time.Duration
+	}{
+		{"503 is retried", "", []int{503, 502, 200}, []int{503, 502, 200}, 0},
+		{"429 waits out Retry-After", "1", []int{429, 200}, []int{429, 200}, time.Second},
+		{"Retry-After beyond the cap is returned", "3600", []int{429, 200}, []int{429}, 0},
+		{"404 is not retried", "", []int{404, 200}, []int{404}, 0},
+		{"retries run out", "", []int{504}, []int{504, 504, 504, 504}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := statusServer(t, tt.retryAfter, tt.statuses...)
+			rt := newResilientTransport(hostRouter{"explorer.test": srv}, fast)
+			l, resp, err := ledgeredGet(t, rt, "http://explorer.test/api/blocks/tip/height")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last := tt.want[len(tt.want)-1]; resp.StatusCode != last {
+				t.Errorf("status = %d, want %d", resp.StatusCode, last)
+			}
+			e := l.Entries()[0]
+			if len(e.Attempts) != len(tt.want) {
+				t.Fatalf("attempts = %+v, want statuses %v", e.Attempts, tt.want)
+			}
+			for i, a := range e.Attempts {
+				if a.Status != tt.want[i] || a.Host != "explorer.test" {
+					t.Errorf("attempt %d = %+v, want status %d", i, a, tt.want[i])
+				}
+			}
+			if wait := time.Duration(e.Attempts[len(e.Attempts)-1].WaitMs) * time.Millisecond; wait < tt.minWait {
+				t.Errorf("waited %s before the last attempt, want at least %s", wait, tt.minWait)
+			}
+			st := l.Stats()
+			if st.Retries != len(tt.want)-1 {
+				t.Errorf("stats retries = %d, want %d", st.Retries, len(tt.want)-1)
+			}
+		})
+	}
+}
+
+func TestResilientBreakerAndFallback(t *testing.T) {
+	primary, primaryHits := statusServer(t, "", 503)
+	fallback, fallbackHits := statusServer(t, "", 200)
+	rt := newResilientTransport(hostRouter{"primary.test": primary, "fallback.test": fallback, "other.test": primary}, RetryPolicy{
+		MaxRetries: 5, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond,
+		BreakerThreshold: 2, BreakerCooldown: time.Minute,
+		Fallbacks: map[string]string{"primary.test": "fallback.test"},
+	})
+
+	// Two failures open the breaker; the fallback answers the same request.
+	l, resp, err := ledgeredGet(t, rt, "http://primary.test/api/address/bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq/utxo")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("fallback request: %v %v", resp, err)
+	}
+	e := l.Entries()[0]
+	if e.Host != "fallback.test" || e.RequestedHost != "primary.test" {
+		t.Errorf("ledger host = %q (requested %q), want the fallback that answered", e.Host, e.RequestedHost)
+	}
+	if n := len(e.Attempts); n != 3 || !e.Attempts[2].Fallback || e.Attempts[2].Host != "fallback.test" {
+		t.Errorf("attempts = %+v", e.Attempts)
+	}
+	if st := l.Stats(); len(st.Fallbacks) != 1 || st.Fallbacks[0] != "primary.test -> fallback.test: circuit breaker open" {
+		t.Errorf("stats fallbacks = %v", st.Fallbacks)
+	}
+	hosts := map[string]int{}
+	for _, h := range l.Report().Exposure.Hosts {
+		hosts[h.Host] = h.Addresses
+	}
+	if hosts["primary.test"] != 1 || hosts["fallback.test"] != 1 {
+		t.Errorf("exposure hosts = %v, want the address revealed to both", hosts)
+	}
+
+	// While open, the primary is skipped entirely.
+	l, resp, err = ledgeredGet(t, rt, "http://primary.test/api/blocks/tip/height")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("second request: %v %v", resp, err)
+	}
+	if primaryHits.Load() != 2 || fallbackHits.Load() != 2 {
+		t.Errorf("hits primary %d fallback %d, want 2 and 2", primaryHits.Load(), fallbackHits.Load())
+	}
+	if e := l.Entries()[0]; e.Host != "fallback.test" || len(e.Attempts) != 1 {
+		t.Errorf("second entry = %+v", e)
+	}
+
+	// A host without a fallback fails fast and is recorded as not sent.
+	rt.hosts.get("other.test").failure(1, time.Minute)
+	l, _, err = ledgeredGet(t, rt, "http://other.test/x")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if e := l.Entries()[0]; !e.NotSent || e.Host != "other.test" || len(l.Report().Exposure.Hosts) != 0 {
+		t.Errorf("open breaker entry = %+v", e)
+	}
+}
+
+func TestHostStateReserve(t *testing.T) {
+	h := &hostState{}
+	if d := h.reserve(0, 1); d != 0 {
+		t.Errorf("unlimited rate waited %s", d)
+	}
+	for i := 0; i < 2; i++ {
+		if d := h.reserve(10, 2); d != 0 {
+			t.Errorf("burst token %d waited %s", i, d)
+		}
+	}
+	if d := h.reserve(10, 2); d < 90*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("third request at 10/s waited %s, want ~100ms", d)
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	rt := newResilientTransport(http.DefaultTransport, RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: 400 * time.Millisecond})
+	for _, tt := range []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{5, 200 * time.Millisecond, 400 * time.Millisecond},
+		{70, 200 * time.Millisecond, 400 * time.Millisecond}, // shift overflow
+	} {
+		for i := 0; i < 20; i++ {
+			if d := rt.backoff(tt.retry); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want %s-%s", tt.retry, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		if d, ok := retryAfter(resp); d != tt.want || ok != tt.ok {
+			t.Errorf("Retry-After %q = %s %t, want %s %t", tt.header, d, ok, tt.want, tt.ok)
+		}
+	}
+}
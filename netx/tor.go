@@ -0,0 +1,190 @@
+package netx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ErrNotTor is returned when strict Tor mode refuses a connection that would
+// leave the machine without going through the Tor proxy.
+var ErrNotTor = errors.New("strict tor: refusing non-Tor connection")
+
+// isLoopback reports whether addr (host:port) stays on this machine. Hostnames
+// other than localhost are not resolved, so nothing leaks to DNS.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isPrivate reports whether addr is a LAN address Tor cannot reach.
+func isPrivate(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// routedDialer sends loopback traffic (the local node) directly and
+// everything else through Tor. LAN addresses go direct unless strict.
+type routedDialer struct {
+	direct *net.Dialer
+	tor    proxy.ContextDialer // nil without Tor
+	strict bool
+}
+
+func (d routedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch {
+	case isLoopback(addr):
+		return d.direct.DialContext(ctx, network, addr)
+	case d.tor != nil && isPrivate(addr) && !d.strict:
+		return d.direct.DialContext(ctx, network, addr)
+	case d.tor != nil && !isPrivate(addr):
+		return d.tor.DialContext(ctx, network, addr)
+	case d.strict:
+		return nil, fmt.Errorf("%w: %s", ErrNotTor, addr)
+	default:
+		return d.direct.DialContext(ctx, network, addr)
+	}
+}
+
+// NewLocalClient returns a client that only reaches loopback addresses. It is
+// the safe default for local node RPC when no client is supplied.
+func NewLocalClient(timeout time.Duration) *http.Client {
+	d := &net.Dialer{Timeout: dialTimeout}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !isLoopback(addr) {
+			return nil, fmt.Errorf("local client: refusing non-loopback address %s", addr)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return &http.Client{Timeout: timeout, Transport: &http.Transport{DialContext: dial}}
+}
+
+type TorStatus struct {
+	Proxy         string `json:"proxy,omitempty"`
+	Strict        bool   `json:"strict"`
+	Isolation     string `json:"isolation,omitempty"`
+	SOCKSOK       bool   `json:"socks_ok"`
+	IsTor         bool   `json:"is_tor"`
+	Method        string `json:"method,omitempty"` // "control-port" or "check.torproject"
+	ExitIP        string `json:"exit_ip,omitempty"`
+	ExplorerOnion bool   `json:"explorer_onion"`
+	Enforced      bool   `json:"enforced"` // strict and verified: nothing leaves except via Tor
+	Error         string `json:"error,omitempty"`
+}
+
+// socksHandshake checks that addr speaks SOCKS5 and accepts an auth method
+// we offer (none or username/password).
+func socksHandshake(ctx context.Context, addr string) error {
+	c, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := c.Write([]byte{5, 2, 0, 2}); err != nil {
+		return err
+	}
+	resp := make([]byte, 2)
+	if _, err := c.Read(resp); err != nil {
+		return err
+	}
+	if resp[0] != 5 || (resp[1] != 0 && resp[1] != 2) {
+		return fmt.Errorf("not a SOCKS5 proxy (reply %x)", resp)
+	}
+	return nil
+}
+
+// torControlProtocolInfo asks the Tor control port for PROTOCOLINFO, which
+// Tor answers before authentication.
+func torControlProtocolInfo(ctx context.Context, addr string) error {
+	c, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := c.Write([]byte("PROTOCOLINFO 1\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "250-PROTOCOLINFO") {
+		return fmt.Errorf("unexpected control port reply %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+const torCheckURL = "https://check.torproject.org/api/ip"
+
+// VerifyTor checks that cfg's SOCKS proxy is a working Tor instance: a SOCKS5
+// handshake, then either the Tor control port (when controlAddr is set) or a
+// request through client to check.torproject.org.
+func VerifyTor(ctx context.Context, cfg ClientConfig, controlAddr string, client *http.Client) TorStatus {
+	st := TorStatus{Proxy: cfg.TorSocks5Addr, Strict: cfg.StrictTor, Isolation: cfg.TorIsolation}
+	if cfg.TorSocks5Addr == "" {
+		st.Error = "no tor proxy configured"
+		return st
+	}
+	if err := socksHandshake(ctx, cfg.TorSocks5Addr); err != nil {
+		st.Error = "socks5 handshake: " + err.Error()
+		return st
+	}
+	st.SOCKSOK = true
+
+	if controlAddr != "" {
+		st.Method = "control-port"
+		if err := torControlProtocolInfo(ctx, controlAddr); err != nil {
+			st.Error = "tor control port: " + err.Error()
+			return st
+		}
+		st.IsTor = true
+	} else {
+		st.Method = "check.torproject"
+		req, err := http.NewRequestWithContext(ctx, "GET", torCheckURL, nil)
+		if err != nil {
+			st.Error = "tor check: " + err.Error()
+			return st
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			st.Error = "tor check: " + err.Error()
+			return st
+		}
+		defer resp.Body.Close()
+		var res struct {
+			IsTor bool   `json:"IsTor"`
+			IP    string `json:"IP"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			st.Error = "tor check: " + err.Error()
+			return st
+		}
+		st.IsTor, st.ExitIP = res.IsTor, res.IP
+		if !res.IsTor {
+			st.Error = "proxy works but traffic does not exit through Tor"
+		}
+	}
+	st.Enforced = st.Strict && st.IsTor
+	return st
+}
@@ -0,0 +1,147 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var errViaTor = errors.New("dialed via tor")
+
+type torDialer struct{}
+
+func (torDialer) DialContext(context.Context, string, string) (net.Conn, error) {
+	return nil, errViaTor
+}
+
+func TestRoutedDialer(t *testing.T) {
+	// A cancelled context makes direct dials fail at once with context.Canceled,
+	// so each route is told apart by its error without touching the network.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	direct := &net.Dialer{}
+	tests := []struct {
+		addr        string
+		tor, strict bool
+		want        error
+	}{
+		{"127.0.0.1:8332", true, true, context.Canceled},
+		{"localhost:8080", true, true, context.Canceled},
+		{"[::1]:10009", false, true, context.Canceled},
+		{"192.168.1.5:8080", true, false, context.Canceled},
+		{"192.168.1.5:8080", true, true, ErrNotTor},
+		{"169.254.0.1:80", true, true, ErrNotTor},
+		{"blockstream.info:443", true, false, errViaTor},
+		{"blockstream.info:443", true, true, errViaTor},
+		{"203.0.113.7:443", true, true, errViaTor},
+		{"blockstream.info:443", false, true, ErrNotTor},
+		{"blockstream.info:443", false, false, context.Canceled},
+	}
+	for _, tt := range tests {
+		d := routedDialer{direct: direct, strict: tt.strict}
+		if tt.tor {
+			d.tor = torDialer{}
+		}
+		if _, err := d.DialContext(ctx, "tcp", tt.addr); !errors.Is(err, tt.want) {
+			t.Errorf("%s (tor %t, strict %t): err %v, want %v", tt.addr, tt.tor, tt.strict, err, tt.want)
+		}
+	}
+}
+
+func TestStrictTorClient(t *testing.T) {
+	if _, err := NewHTTPClient(ClientConfig{StrictTor: true}); err == nil {
+		t.Error("strict tor without a proxy built a client")
+	}
+
+	s := newFakeSOCKS(t)
+	c, err := NewHTTPClient(ClientConfig{TorSocks5Addr: s.l.Addr().String(), StrictTor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("http://192.168.1.5/api"); !errors.Is(err, ErrNotTor) {
+		t.Errorf("LAN request under strict tor: %v", err)
+	}
+	resp, err := c.Get("http://explorer.test/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if hits := s.users("api"); len(hits) != 1 {
+		t.Errorf("public request did not go through the proxy: %v", hits)
+	}
+
+	if _, err := NewLocalClient(0).Get("http://192.168.1.5/"); err == nil || !strings.Contains(err.Error(), "refusing non-loopback") {
+		t.Errorf("local client reached a LAN address: %v", err)
+	}
+}
+
+// torCheck answers check.torproject.org's API with body.
+type torCheck string
+
+func (b torCheck) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.String() != torCheckURL {
+		return nil, errors.New("unexpected request " + r.URL.String())
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(b))), Request: r}, nil
+}
+
+// lineServer answers every connection with reply and closes it.
+func lineServer(t *testing.T, reply string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(c, reply)
+			c.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestVerifyTor(t *testing.T) {
+	socks := newFakeSOCKS(t).l.Addr().String()
+	control := lineServer(t, "250-PROTOCOLINFO 1\r\n250 OK\r\n")
+	notControl := lineServer(t, "514 Authentication required.\r\n")
+	notSOCKS := lineServer(t, "HTTP/1.1 400 Bad Request\r\n\r\n")
+	tests := []struct {
+		name     string
+		cfg      ClientConfig
+		control  string
+		check    torCheck
+		isTor    bool
+		enforced bool
+		errHas   string
+	}{
+		{"no proxy", ClientConfig{}, "", "", false, false, "no tor proxy configured"},
+		{"not a socks proxy", ClientConfig{TorSocks5Addr: notSOCKS}, "", "", false, false, "socks5 handshake"},
+		{"control port", ClientConfig{TorSocks5Addr: socks, StrictTor: true}, control, "", true, true, ""},
+		{"control port not tor", ClientConfig{TorSocks5Addr: socks, StrictTor: true}, notControl, "", false, false, "unexpected control port reply"},
+		{"check says tor", ClientConfig{TorSocks5Addr: socks}, "", `{"IsTor":true,"IP":"185.220.101.1"}`, true, false, ""},
+		{"check says tor, strict", ClientConfig{TorSocks5Addr: socks, StrictTor: true}, "", `{"IsTor":true}`, true, true, ""},
+		{"check says clearnet", ClientConfig{TorSocks5Addr: socks, StrictTor: true}, "", `{"IsTor":false,"IP":"198.51.100.2"}`, false, false, "does not exit through Tor"},
+		{"check garbled", ClientConfig{TorSocks5Addr: socks}, "", `<html>`, false, false, "tor check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := VerifyTor(context.Background(), tt.cfg, tt.control, &http.Client{Transport: tt.check})
+			if st.IsTor != tt.isTor || st.Enforced != tt.enforced {
+				t.Errorf("is tor %t enforced %t, want %t %t (%s)", st.IsTor, st.Enforced, tt.isTor, tt.enforced, st.Error)
+			}
+			if (tt.errHas == "") != (st.Error == "") || !strings.Contains(st.Error, tt.errHas) {
+				t.Errorf("error = %q, want %q", st.Error, tt.errHas)
+			}
+		})
+	}
+}
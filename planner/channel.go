@@ -0,0 +1,173 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+
+	"sovereign-checker/btc"
+)
+
+const (
+	coopCloseVBytes    = 170 // 2-of-2 P2WSH input + two outputs
+	p2wpkhInputVBytes  = 148 // same rough per-input size btc.EstimateSweepFee uses
+	minChannelSizeSats = 20_000
+)
+
+type PaymentProfile struct {
+	PaymentsPerMonth int
+	PaymentSizeSats  uint64
+	HorizonMonths    int // defaults to 12
+}
+
+type CostInputs struct {
+	Profile      PaymentProfile
+	FeeRateSatVB uint64
+	UTXOs        []btc.UTXO
+
+	// Lightning side. ExistingOutboundSats is spendable balance in open channels.
+	ExistingOutboundSats uint64
+	// RoutingFeeMsat is the node's fee estimate for one payment, named by
+	// RoutingFeeSource. Without one, base + ppm is used as a fallback.
+	RoutingFeeMsat     *uint64
+	RoutingFeeSource   string
+	RoutingFeeBaseMsat uint64
+	RoutingFeePPM      uint64
+}
+
+type CostComparison struct {
+	PaymentsPerMonth int    `json:"payments_per_month"`
+	PaymentSizeSats  uint64 `json:"payment_size_sats"`
+	HorizonMonths    int    `json:"horizon_months"`
+	FeeRateSatVB     uint64 `json:"fee_rate_sat_vb"`
+
+	OnChainPerPaymentSats uint64 `json:"onchain_per_payment_sats"`
+	OnChainMonthlySats    uint64 `json:"onchain_monthly_sats"`
+	OnChainHorizonSats    uint64 `json:"onchain_horizon_sats"`
+
+	LNPerPaymentSats     uint64 `json:"ln_per_payment_sats"`
+	RoutingFeeSource     string `json:"routing_fee_source"` // the node's estimate, or "fallback" for the assumed base + ppm
+	LNMonthlyRoutingSats uint64 `json:"ln_monthly_routing_sats"`
+	ChannelOpenFeeSats   uint64 `json:"channel_open_fee_sats"`
+	ChannelCloseFeeSats  uint64 `json:"channel_close_fee_sats"`
+	LNHorizonSats        uint64 `json:"ln_horizon_sats"`
+	ExistingOutboundSats uint64 `json:"existing_outbound_sats"`
+
+	BreakEvenMonths        float64    `json:"break_even_months,omitempty"`
+	Recommendation         string     `json:"recommendation"` // "LIGHTNING" or "ONCHAIN"
+	RecommendedChannelSats uint64     `json:"recommended_channel_sats,omitempty"`
+	FundingUTXOs           []btc.UTXO `json:"funding_utxos,omitempty"`
+	FundingShortfallSats   uint64     `json:"funding_shortfall_sats,omitempty"`
+	Notes                  []string   `json:"notes"`
+}
+
+// SelectFundingUTXOs picks consolidation candidates to fund target sats:
+// smallest economically spendable coins first, so the channel open also
+// cleans up fragmentation. It returns the picks and any shortfall.
+func SelectFundingUTXOs(utxos []btc.UTXO, target, feeRate uint64) ([]btc.UTXO, uint64) {
+	inputCost := p2wpkhInputVBytes * feeRate
+	candidates := make([]btc.UTXO, 0, len(utxos))
+	for _, u := range utxos {
+		if u.Confirmed && u.ValueSats > inputCost {
+			candidates = append(candidates, u)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ValueSats < candidates[j].ValueSats })
+
+	var picked []btc.UTXO
+	var total uint64
+	for _, u := range candidates {
+		if total >= target+btc.EstimateSweepFee(len(picked), 2, feeRate) && len(picked) > 0 {
+			break
+		}
+		picked = append(picked, u)
+		total += u.ValueSats
+	}
+	need := target + btc.EstimateSweepFee(len(picked), 2, feeRate)
+	if total >= need {
+		return picked, 0
+	}
+	return picked, need - total
+}
+
+// CompareLNOnChain projects the cost of a payment profile paid on-chain vs
+// over a channel (open + routing fees + eventual cooperative close).
+func CompareLNOnChain(in CostInputs) CostComparison {
+	months := in.Profile.HorizonMonths
+	if months <= 0 {
+		months = 12
+	}
+	n := uint64(in.Profile.PaymentsPerMonth)
+	size := in.Profile.PaymentSizeSats
+
+	c := CostComparison{
+		PaymentsPerMonth:     in.Profile.PaymentsPerMonth,
+		PaymentSizeSats:      size,
+		HorizonMonths:        months,
+		FeeRateSatVB:         in.FeeRateSatVB,
+		ExistingOutboundSats: in.ExistingOutboundSats,
+		Notes:                []string{},
+	}
+
+	c.OnChainPerPaymentSats = btc.EstimateSweepFee(1, 2, in.FeeRateSatVB)
+	c.OnChainMonthlySats = n * c.OnChainPerPaymentSats
+	c.OnChainHorizonSats = uint64(months) * c.OnChainMonthlySats
+
+	feeMsat := in.RoutingFeeBaseMsat + size*1000*in.RoutingFeePPM/1_000_000
+	c.RoutingFeeSource = "fallback"
+	if in.RoutingFeeMsat != nil {
+		feeMsat = *in.RoutingFeeMsat
+		c.RoutingFeeSource = in.RoutingFeeSource
+	}
+	c.LNPerPaymentSats = (feeMsat + 999) / 1000
+	c.LNMonthlyRoutingSats = n * c.LNPerPaymentSats
+
+	spend := uint64(months) * n * size
+	if spend > in.ExistingOutboundSats {
+		c.RecommendedChannelSats = spend - in.ExistingOutboundSats
+		if c.RecommendedChannelSats < minChannelSizeSats {
+			c.RecommendedChannelSats = minChannelSizeSats
+		}
+		var picked []btc.UTXO
+		picked, c.FundingShortfallSats = SelectFundingUTXOs(in.UTXOs, c.RecommendedChannelSats, in.FeeRateSatVB)
+		c.FundingUTXOs = picked
+		inputs := len(picked)
+		if inputs == 0 {
+			inputs = 1
+		}
+		c.ChannelOpenFeeSats = btc.EstimateSweepFee(inputs, 2, in.FeeRateSatVB)
+		c.ChannelCloseFeeSats = coopCloseVBytes * in.FeeRateSatVB
+	} else {
+		c.Notes = append(c.Notes, "Existing channel outbound already covers the projected spend; no new channel needed.")
+	}
+
+	lnFixed := c.ChannelOpenFeeSats + c.ChannelCloseFeeSats
+	c.LNHorizonSats = lnFixed + uint64(months)*c.LNMonthlyRoutingSats
+
+	if c.OnChainMonthlySats > c.LNMonthlyRoutingSats {
+		c.BreakEvenMonths = float64(lnFixed) / float64(c.OnChainMonthlySats-c.LNMonthlyRoutingSats)
+	}
+
+	c.Recommendation = "ONCHAIN"
+	if n > 0 && c.LNHorizonSats < c.OnChainHorizonSats {
+		c.Recommendation = "LIGHTNING"
+	}
+	switch {
+	case c.Recommendation == "ONCHAIN":
+		c.RecommendedChannelSats = 0
+		c.FundingUTXOs = nil
+		c.FundingShortfallSats = 0
+	case c.FundingShortfallSats > 0:
+		c.Notes = append(c.Notes, fmt.Sprintf(
+			"Wallet UTXOs fall %d sats short of the recommended channel size.", c.FundingShortfallSats))
+	case len(c.FundingUTXOs) > 1:
+		c.Notes = append(c.Notes, fmt.Sprintf(
+			"Funding from %d small UTXOs doubles as a consolidation (links those coins on-chain).", len(c.FundingUTXOs)))
+	}
+	if in.RoutingFeeMsat != nil {
+		c.Notes = append(c.Notes, "Routing fee is the node's estimate ("+in.RoutingFeeSource+") for one payment to nodes past its peers; actual fees depend on the payee.")
+	} else {
+		c.Notes = append(c.Notes, fmt.Sprintf("Routing fee is a fallback assumption (%d msat + %d ppm); the node gave no route estimate.", in.RoutingFeeBaseMsat, in.RoutingFeePPM))
+	}
+	c.Notes = append(c.Notes, "On-chain cost assumes one input and a change output per payment.")
+	return c
+}
@@ -0,0 +1,104 @@
+package planner
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+func coins(values ...uint64) []btc.UTXO {
+	var us []btc.UTXO
+	for _, v := range values {
+		us = append(us, btc.UTXO{ValueSats: v, Confirmed: true})
+	}
+	return us
+}
+
+func TestSelectFundingUTXOs(t *testing.T) {
+	// At 10 sat/vB an input costs 1480 sats, so the 1000-sat coin is dust.
+	utxos := append(coins(1_000, 30_000, 10_000, 50_000), btc.UTXO{ValueSats: 5_000})
+	tests := []struct {
+		target    uint64
+		utxos     []btc.UTXO
+		picked    []uint64
+		shortfall uint64
+	}{
+		{5_000, utxos, []uint64{10_000}, 0},
+		{35_000, utxos, []uint64{10_000, 30_000}, 0},
+		{100_000, utxos, []uint64{10_000, 30_000, 50_000}, 15_220},
+		{20_000, nil, nil, 20_000},
+	}
+	for _, tt := range tests {
+		picked, short := SelectFundingUTXOs(tt.utxos, tt.target, 10)
+		var got []uint64
+		for _, u := range picked {
+			got = append(got, u.ValueSats)
+		}
+		if len(got) != len(tt.picked) || short != tt.shortfall {
+			t.Errorf("target %d: picked %v short %d, want %v %d", tt.target, got, short, tt.picked, tt.shortfall)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.picked[i] {
+				t.Errorf("target %d: picked %v, want %v", tt.target, got, tt.picked)
+			}
+		}
+	}
+}
+
+func TestCompareLNOnChain(t *testing.T) {
+	estimate := uint64(1_500)
+	daily := PaymentProfile{PaymentsPerMonth: 30, PaymentSizeSats: 1_000}
+	tests := []struct {
+		name        string
+		in          CostInputs
+		rec         string
+		channelSats uint64
+		funding     int
+		shortfall   uint64
+		openFee     uint64
+		lnHorizon   uint64
+		breakEven   float64
+		note        string // first note
+	}{
+		// 360 payments of 1000 sats: on-chain 2260 each vs 2 sats routing
+		// plus a 2260 open and a 1700 close.
+		{"small frequent payments", CostInputs{Profile: daily, FeeRateSatVB: 10, UTXOs: coins(400_000),
+			RoutingFeeBaseMsat: 1_000, RoutingFeePPM: 1_000},
+			"LIGHTNING", 360_000, 1, 0, 2_260, 4_680, 3960.0 / 67740, "Routing fee is a fallback assumption (1000 msat + 1000 ppm)"},
+		{"node estimate and enough outbound", CostInputs{Profile: daily, FeeRateSatVB: 10, ExistingOutboundSats: 400_000,
+			RoutingFeeMsat: &estimate, RoutingFeeSource: "queryroutes"},
+			"LIGHTNING", 0, 0, 0, 0, 720, 0, "Existing channel outbound already covers"},
+		{"minimum channel size", CostInputs{Profile: daily, FeeRateSatVB: 10, UTXOs: coins(400_000), ExistingOutboundSats: 355_000},
+			"LIGHTNING", minChannelSizeSats, 1, 0, 2_260, 3_960, 3960.0 / 67800, "Routing fee is a fallback"},
+		{"funding doubles as consolidation", CostInputs{Profile: daily, FeeRateSatVB: 10, UTXOs: coins(200_000, 200_000)},
+			"LIGHTNING", 360_000, 2, 0, 3_740, 5_440, 5440.0 / 67800, "Funding from 2 small UTXOs"},
+		{"wallet too small", CostInputs{Profile: daily, FeeRateSatVB: 10, UTXOs: coins(100_000)},
+			"LIGHTNING", 360_000, 1, 262_260, 2_260, 3_960, 3960.0 / 67800, "Wallet UTXOs fall 262260 sats short"},
+		{"one large payment", CostInputs{Profile: PaymentProfile{PaymentsPerMonth: 1, PaymentSizeSats: 10_000_000, HorizonMonths: 1},
+			FeeRateSatVB: 10, RoutingFeeBaseMsat: 1_000, RoutingFeePPM: 5_000},
+			"ONCHAIN", 0, 0, 0, 2_260, 53_961, 0, "Routing fee is a fallback"},
+		{"no payments", CostInputs{FeeRateSatVB: 10}, "ONCHAIN", 0, 0, 0, 0, 0, 0, "Existing channel outbound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CompareLNOnChain(tt.in)
+			if c.Recommendation != tt.rec || c.RecommendedChannelSats != tt.channelSats || len(c.FundingUTXOs) != tt.funding ||
+				c.FundingShortfallSats != tt.shortfall {
+				t.Errorf("%s channel %d funding %d short %d, want %s %d %d %d", c.Recommendation, c.RecommendedChannelSats,
+					len(c.FundingUTXOs), c.FundingShortfallSats, tt.rec, tt.channelSats, tt.funding, tt.shortfall)
+			}
+			if c.ChannelOpenFeeSats != tt.openFee || c.LNHorizonSats != tt.lnHorizon {
+				t.Errorf("open fee %d ln horizon %d, want %d %d", c.ChannelOpenFeeSats, c.LNHorizonSats, tt.openFee, tt.lnHorizon)
+			}
+			if math.Abs(c.BreakEvenMonths-tt.breakEven) > 1e-9 {
+				t.Errorf("break even = %f months, want %f", c.BreakEvenMonths, tt.breakEven)
+			}
+			if len(c.Notes) == 0 || !strings.HasPrefix(c.Notes[0], tt.note) {
+				t.Errorf("notes = %q, want first %q", c.Notes, tt.note)
+			}
+		})
+	}
+}
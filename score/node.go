@@ -0,0 +1,163 @@
+package score
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"sovereign-checker/btc"
+)
+
+type NodeHealth struct {
+	Chain                string         `json:"chain"`
+	Subversion           string         `json:"subversion"`
+	VersionMajor         int            `json:"version_major"`
+	VersionAgeMonths     int            `json:"version_age_months"`
+	Blocks               int            `json:"blocks"`
+	Headers              int            `json:"headers"`
+	InitialBlockDownload bool           `json:"initial_block_download"`
+	VerificationProgress float64        `json:"verification_progress"`
+	Pruned               bool           `json:"pruned"`
+	PeersInbound         int            `json:"peers_inbound"`
+	PeersOutbound        int            `json:"peers_outbound"`
+	PeersByNetwork       map[string]int `json:"peers_by_network"`
+	OnionReachable       bool           `json:"onion_reachable"`
+	I2PReachable         bool           `json:"i2p_reachable"`
+	MempoolLoaded        bool           `json:"mempool_loaded"`
+	MempoolMinFeeSatVB   float64        `json:"mempool_min_fee_sat_vb"`
+	MinRelayFeeSatVB     float64        `json:"min_relay_fee_sat_vb"`
+	FullRBF              *bool          `json:"full_rbf,omitempty"`
+	DefaultMempoolPolicy bool           `json:"default_mempool_policy"`
+	TxIndex              bool           `json:"txindex"`
+	BlockFilterIndex     bool           `json:"blockfilterindex"`
+	Score                int            `json:"score"`
+	Warnings             []string       `json:"warnings"`
+	Notes                []string       `json:"notes"`
+}
+
+// Bitcoin Core ships a major release roughly every six months; v25 came out
+// in May 2023. Used to estimate how old a node's release is.
+var coreV25Release = time.Date(2023, time.May, 26, 0, 0, 0, 0, time.UTC)
+
+func coreReleaseDate(major int) time.Time {
+	return coreV25Release.AddDate(0, (major-25)*6, 0)
+}
+
+func btcPerKBToSatVB(f float64) float64 {
+	return math.Round(f*100_000*1000) / 1000
+}
+
+// ComputeNode scores a bitcoind node on sync state, connectivity, privacy
+// reachability, mempool policy and available indexes.
+func ComputeNode(ni btc.NodeInfo, now time.Time) NodeHealth {
+	bi, net := ni.Blockchain, ni.Network
+	h := NodeHealth{
+		Chain:                bi.Chain,
+		Subversion:           net.Subversion,
+		VersionMajor:         net.Version / 10000,
+		Blocks:               bi.Blocks,
+		Headers:              bi.Headers,
+		InitialBlockDownload: bi.InitialBlockDownload,
+		VerificationProgress: bi.VerificationProgress,
+		Pruned:               bi.Pruned,
+		PeersByNetwork:       map[string]int{},
+		MempoolLoaded:        ni.Mempool.Loaded,
+		MempoolMinFeeSatVB:   btcPerKBToSatVB(ni.Mempool.MempoolMinFeeBTCPerKB),
+		MinRelayFeeSatVB:     btcPerKBToSatVB(ni.Mempool.MinRelayTxFeeBTCPerKB),
+		FullRBF:              ni.Mempool.FullRBF,
+		Score:                100,
+		Warnings:             []string{},
+		Notes:                []string{},
+	}
+	penalize := func(points int, warning string) {
+		h.Score -= points
+		h.Warnings = append(h.Warnings, warning)
+	}
+
+	if h.InitialBlockDownload {
+		penalize(30, fmt.Sprintf("Node is in initial block download (%.1f%% verified); its data is not authoritative yet.", h.VerificationProgress*100))
+	}
+	if h.Pruned {
+		penalize(10, "Node is pruned; it cannot rescan old blocks for wallet history or serve them to peers.")
+	}
+
+	if h.VersionMajor > 0 {
+		age := now.Sub(coreReleaseDate(h.VersionMajor))
+		if age > 0 {
+			h.VersionAgeMonths = int(age.Hours() / 24 / 30)
+		}
+		switch {
+		case h.VersionAgeMonths > 18:
+			penalize(15, fmt.Sprintf("Bitcoin Core v%d is about %d months old and likely out of maintenance; upgrade.", h.VersionMajor, h.VersionAgeMonths))
+		case h.VersionAgeMonths > 12:
+			penalize(5, fmt.Sprintf("Bitcoin Core v%d is about %d months old; plan an upgrade.", h.VersionMajor, h.VersionAgeMonths))
+		}
+	}
+
+	for _, p := range ni.Peers {
+		if p.Inbound {
+			h.PeersInbound++
+		} else {
+			h.PeersOutbound++
+		}
+		if p.Network != "" {
+			h.PeersByNetwork[p.Network]++
+		}
+	}
+	switch {
+	case h.PeersOutbound == 0:
+		penalize(20, "No outbound peers; the node cannot learn about new blocks.")
+	case h.PeersOutbound < 8:
+		penalize(5, fmt.Sprintf("Only %d outbound peers (default is 8 full-relay + 2 block-relay); eclipse risk is higher.", h.PeersOutbound))
+	}
+	if h.PeersInbound == 0 {
+		h.Notes = append(h.Notes, "No inbound peers; the node is not reachable by others.")
+	}
+
+	for _, n := range net.Networks {
+		switch n.Name {
+		case "onion":
+			h.OnionReachable = n.Reachable
+		case "i2p":
+			h.I2PReachable = n.Reachable
+		}
+	}
+	if !h.OnionReachable && !h.I2PReachable {
+		penalize(5, "Neither Tor (onion) nor I2P is reachable; all peer connections reveal the node's IP.")
+	}
+
+	h.DefaultMempoolPolicy = true
+	defaultMinRelay := 1.0
+	if h.VersionMajor >= 30 {
+		defaultMinRelay = 0.1
+	}
+	if h.MinRelayFeeSatVB != defaultMinRelay {
+		h.DefaultMempoolPolicy = false
+		penalize(5, fmt.Sprintf("minrelaytxfee is %.3f sat/vB, not the default %.1f; the node's view of the mempool differs from most peers.", h.MinRelayFeeSatVB, defaultMinRelay))
+	}
+	if h.FullRBF != nil && *h.FullRBF != (h.VersionMajor >= 28) {
+		h.DefaultMempoolPolicy = false
+		penalize(5, fmt.Sprintf("mempoolfullrbf=%t differs from the v%d default.", *h.FullRBF, h.VersionMajor))
+	}
+	if !h.MempoolLoaded {
+		penalize(5, "Mempool has not finished loading; fee estimates may be off.")
+	}
+	if h.MempoolMinFeeSatVB > h.MinRelayFeeSatVB {
+		h.Notes = append(h.Notes, fmt.Sprintf("Mempool is full; transactions below %.2f sat/vB are evicted.", h.MempoolMinFeeSatVB))
+	}
+	h.Notes = append(h.Notes, "The dust relay fee is not exposed over RPC and was not checked.")
+
+	_, h.TxIndex = ni.Indexes["txindex"]
+	_, h.BlockFilterIndex = ni.Indexes["basic block filter index"]
+	if !h.BlockFilterIndex {
+		penalize(5, "No block filter index; wallets cannot rescan quickly or use BIP157 against this node.")
+	}
+	if !h.TxIndex {
+		h.Notes = append(h.Notes, "No txindex; arbitrary transactions cannot be looked up by txid.")
+	}
+
+	if h.Score < 0 {
+		h.Score = 0
+	}
+	return h
+}
@@ -0,0 +1,120 @@
+package score
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"sovereign-checker/btc"
+)
+
+// healthyNode is a synced, well-connected Bitcoin Core node of the given major
+// version with default mempool policy and both indexes.
+func healthyNode(t *testing.T, major int) btc.NodeInfo {
+	t.Helper()
+	var net btc.NetworkInfo
+	if err := json.Unmarshal([]byte(`{"networks":[{"name":"ipv4","reachable":true},{"name":"onion","reachable":true}]}`), &net); err != nil {
+		t.Fatal(err)
+	}
+	net.Version = major*10000 + 100
+	ni := btc.NodeInfo{
+		Blockchain: btc.BlockchainInfo{Chain: "main", Blocks: 860_000, Headers: 860_000, VerificationProgress: 1},
+		Network:    net,
+		Peers:      []btc.PeerInfo{{Inbound: true, Network: "onion"}},
+		Mempool:    btc.MempoolInfo{Loaded: true, MempoolMinFeeBTCPerKB: 0.00001, MinRelayTxFeeBTCPerKB: 0.00001},
+		Indexes:    map[string]btc.IndexInfo{"txindex": {Synced: true}, "basic block filter index": {Synced: true}},
+	}
+	if major >= 30 {
+		ni.Mempool.MempoolMinFeeBTCPerKB, ni.Mempool.MinRelayTxFeeBTCPerKB = 0.000001, 0.000001
+	}
+	for i := 0; i < 8; i++ {
+		ni.Peers = append(ni.Peers, btc.PeerInfo{Network: "ipv4"})
+	}
+	return ni
+}
+
+func TestComputeNode(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		major    int
+		mutate   func(*btc.NodeInfo)
+		score    int
+		warnings []string
+	}{
+		{"healthy v27", 27, func(*btc.NodeInfo) {}, 100, nil},
+		{"healthy v30 with lower relay fee", 30, func(*btc.NodeInfo) {}, 100, nil},
+		{"full rbf is the v28 default", 28, func(ni *btc.NodeInfo) { ni.Mempool.FullRBF = &yes }, 100, nil},
+		{"initial block download", 27, func(ni *btc.NodeInfo) {
+			ni.Blockchain.InitialBlockDownload, ni.Blockchain.VerificationProgress = true, 0.4213
+		}, 70, []string{"initial block download (42.1% verified)"}},
+		{"pruned", 27, func(ni *btc.NodeInfo) { ni.Blockchain.Pruned = true }, 90, []string{"Node is pruned"}},
+		{"release over a year old", 27, func(ni *btc.NodeInfo) { ni.Network.Version = 250000 }, 95,
+			[]string{"v25 is about 13 months old; plan an upgrade"}},
+		{"release out of maintenance", 27, func(ni *btc.NodeInfo) { ni.Network.Version = 240000 }, 85,
+			[]string{"v24 is about 19 months old and likely out of maintenance"}},
+		{"no outbound peers", 27, func(ni *btc.NodeInfo) { ni.Peers = ni.Peers[:1] }, 80, []string{"No outbound peers"}},
+		{"few outbound peers", 27, func(ni *btc.NodeInfo) { ni.Peers = ni.Peers[:4] }, 95, []string{"Only 3 outbound peers"}},
+		{"clearnet only", 27, func(ni *btc.NodeInfo) { ni.Network.Networks = ni.Network.Networks[:1] }, 95,
+			[]string{"Neither Tor (onion) nor I2P is reachable"}},
+		{"custom relay fee", 27, func(ni *btc.NodeInfo) { ni.Mempool.MinRelayTxFeeBTCPerKB = 0.000001 }, 95,
+			[]string{"minrelaytxfee is 0.100 sat/vB, not the default 1.0"}},
+		{"full rbf before v28", 27, func(ni *btc.NodeInfo) { ni.Mempool.FullRBF = &yes }, 95,
+			[]string{"mempoolfullrbf=true differs from the v27 default"}},
+		{"full rbf disabled on v28", 28, func(ni *btc.NodeInfo) { ni.Mempool.FullRBF = &no }, 95,
+			[]string{"mempoolfullrbf=false differs from the v28 default"}},
+		{"mempool loading", 27, func(ni *btc.NodeInfo) { ni.Mempool.Loaded = false }, 95, []string{"Mempool has not finished loading"}},
+		{"no block filters", 27, func(ni *btc.NodeInfo) { delete(ni.Indexes, "basic block filter index") }, 95,
+			[]string{"No block filter index"}},
+		{"score floors at zero", 27, func(ni *btc.NodeInfo) {
+			ni.Network.Version = 200000
+			ni.Network.Networks = nil
+			ni.Blockchain.InitialBlockDownload, ni.Blockchain.Pruned = true, true
+			ni.Peers, ni.Indexes = nil, nil
+			ni.Mempool = btc.MempoolInfo{FullRBF: &yes}
+		}, 0, []string{"initial block download", "pruned", "v20", "No outbound peers", "Neither Tor", "minrelaytxfee", "mempoolfullrbf", "Mempool has not", "No block filter index"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ni := healthyNode(t, tt.major)
+			tt.mutate(&ni)
+			h := ComputeNode(ni, coreReleaseDate(tt.major).AddDate(0, 1, 0))
+			if h.Score != tt.score {
+				t.Errorf("score = %d, want %d (%q)", h.Score, tt.score, h.Warnings)
+			}
+			if len(h.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d", h.Warnings, len(tt.warnings))
+			}
+			for i, w := range tt.warnings {
+				if !strings.Contains(h.Warnings[i], w) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, h.Warnings[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeNodeNotes(t *testing.T) {
+	ni := healthyNode(t, 27)
+	ni.Peers = ni.Peers[1:]
+	ni.Mempool.MempoolMinFeeBTCPerKB = 0.0000321
+	delete(ni.Indexes, "txindex")
+	h := ComputeNode(ni, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC))
+
+	if h.PeersInbound != 0 || h.PeersOutbound != 8 || h.PeersByNetwork["ipv4"] != 8 || !h.OnionReachable {
+		t.Errorf("peers = %d in %d out %v, onion %t", h.PeersInbound, h.PeersOutbound, h.PeersByNetwork, h.OnionReachable)
+	}
+	if h.MempoolMinFeeSatVB != 3.21 || h.MinRelayFeeSatVB != 1 || !h.DefaultMempoolPolicy {
+		t.Errorf("mempool = %.3f min %.3f default %t", h.MempoolMinFeeSatVB, h.MinRelayFeeSatVB, h.DefaultMempoolPolicy)
+	}
+	notes := strings.Join(h.Notes, "\n")
+	for _, want := range []string{"No inbound peers", "transactions below 3.21 sat/vB are evicted", "dust relay fee", "No txindex"} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("notes %q lack %q", h.Notes, want)
+		}
+	}
+	if h.Score != 100 {
+		t.Errorf("notes cost points: score %d, warnings %q", h.Score, h.Warnings)
+	}
+}
@@ -0,0 +1,185 @@
+package score
+
+import (
+	"fmt"
+	"sort"
+
+	"sovereign-checker/btc"
+)
+
+const (
+	// Share of funds in one wallet, or in one legacy-script wallet, that is
+	// flagged as concentration risk.
+	concentrationShare       = 0.5
+	legacyConcentrationShare = 0.25
+	// Input size used when a wallet's script type is unknown; matches
+	// btc.EstimateSweepFee.
+	defaultInputVBytes = 148
+)
+
+// inputVBytes is the approximate spend size of one input per script type
+// (p2sh assumes wrapped p2wpkh, p2wsh a 2-of-3 multisig).
+var inputVBytes = map[string]uint64{
+	"p2pkh":     148,
+	"p2sh":      91,
+	"v0_p2wpkh": 68,
+	"v0_p2wsh":  104,
+	"v1_p2tr":   58,
+}
+
+// Portfolio combines the per-wallet results of several addresses or
+// descriptors.
+type Portfolio struct {
+	Wallets          int    `json:"wallets"`
+	TotalBalanceSats uint64 `json:"total_balance_sats"`
+	TotalUTXOs       int    `json:"total_utxos"`
+	DustUTXOs        int    `json:"dust_utxos"`
+	// Balance-weighted sovereignty score less concentration penalties
+	SovereigntyScore int    `json:"sovereignty_score"`
+	WeakestWallet    string `json:"weakest_wallet,omitempty"`
+	WeakestScore     int    `json:"weakest_score"`
+	// Cheapest first; each wallet is consolidated on its own so wallets are
+	// never linked on-chain
+	ConsolidationOrder   []ConsolidationStep `json:"consolidation_order"`
+	ConsolidationFeeSats uint64              `json:"consolidation_fee_sats"`
+	LargestShare         float64             `json:"largest_wallet_share"`
+	Concentration        []WalletShare       `json:"concentration_risk"`
+	Warnings             []string            `json:"warnings"`
+}
+
+// ConsolidationStep is one wallet's sweep into a single output. Dust worth
+// less than its own input fee is left out.
+type ConsolidationStep struct {
+	Wallet            string  `json:"wallet"`
+	ScriptType        string  `json:"script_type"`
+	Inputs            int     `json:"inputs"`
+	SkippedDust       int     `json:"skipped_uneconomical"`
+	FeeRateSatVB      uint64  `json:"fee_rate_sat_vb"`
+	FeeSats           uint64  `json:"fee_sats"`
+	FeePerUTXORemoved uint64  `json:"fee_per_utxo_removed_sats"`
+	FeePctOfBalance   float64 `json:"fee_pct_of_balance"`
+}
+
+// WalletShare is a wallet holding a risky share of the portfolio.
+type WalletShare struct {
+	Wallet     string  `json:"wallet"`
+	ScriptType string  `json:"script_type"`
+	Share      float64 `json:"share"`
+	Reason     string  `json:"reason"`
+}
+
+func scriptType(wallet string) string {
+	if btc.IsDescriptor(wallet) {
+		return btc.DescriptorType(wallet)
+	}
+	return btc.AddressType(wallet)
+}
+
+func round2(f float64) float64 {
+	return float64(int64(f*100+0.5)) / 100
+}
+
+// ComputePortfolio aggregates wallets' results: totals, a balance-weighted
+// score, the cheapest order to consolidate them in and concentration risk.
+func ComputePortfolio(wallets []Result) Portfolio {
+	p := Portfolio{
+		Wallets:            len(wallets),
+		ConsolidationOrder: []ConsolidationStep{},
+		Concentration:      []WalletShare{},
+		Warnings:           []string{},
+	}
+	if len(wallets) == 0 {
+		return p
+	}
+
+	var weighted float64
+	plain := 0
+	networks := map[string]bool{}
+	for i, w := range wallets {
+		p.TotalBalanceSats += w.TotalBalanceSats
+		p.TotalUTXOs += w.NumUTXOs
+		p.DustUTXOs += w.DustUTXOs
+		weighted += float64(w.SovereigntyScore) * float64(w.TotalBalanceSats)
+		plain += w.SovereigntyScore
+		networks[w.Network] = true
+		if i == 0 || w.SovereigntyScore < p.WeakestScore {
+			p.WeakestWallet, p.WeakestScore = w.Address, w.SovereigntyScore
+		}
+		if step, ok := consolidationStep(w); ok {
+			p.ConsolidationOrder = append(p.ConsolidationOrder, step)
+			p.ConsolidationFeeSats += step.FeeSats
+		}
+	}
+	if len(networks) > 1 {
+		p.Warnings = append(p.Warnings, "Portfolio mixes networks; totals add mainnet and testnet sats together.")
+	}
+	sort.SliceStable(p.ConsolidationOrder, func(i, j int) bool {
+		a, b := p.ConsolidationOrder[i], p.ConsolidationOrder[j]
+		if a.FeePerUTXORemoved != b.FeePerUTXORemoved {
+			return a.FeePerUTXORemoved < b.FeePerUTXORemoved
+		}
+		return a.FeeSats < b.FeeSats
+	})
+
+	score := plain / len(wallets)
+	if p.TotalBalanceSats > 0 {
+		score = int(weighted/float64(p.TotalBalanceSats) + 0.5)
+		largest := 0
+		for i, w := range wallets {
+			if w.TotalBalanceSats > wallets[largest].TotalBalanceSats {
+				largest = i
+			}
+			share := float64(w.TotalBalanceSats) / float64(p.TotalBalanceSats)
+			if typ := scriptType(w.Address); typ == "p2pkh" && share >= legacyConcentrationShare {
+				p.Concentration = append(p.Concentration, WalletShare{
+					Wallet: w.Address, ScriptType: typ, Share: round2(share),
+					Reason: "legacy script: spending costs over twice a segwit input and stands out on-chain",
+				})
+				p.Warnings = append(p.Warnings, fmt.Sprintf("%.0f%% of funds sit in a legacy (P2PKH) wallet; consider migrating to segwit or taproot at low fees.", share*100))
+				score -= 10
+			}
+		}
+		p.LargestShare = round2(float64(wallets[largest].TotalBalanceSats) / float64(p.TotalBalanceSats))
+		if len(wallets) > 1 && p.LargestShare >= concentrationShare {
+			w := wallets[largest]
+			p.Concentration = append(p.Concentration, WalletShare{
+				Wallet: w.Address, ScriptType: scriptType(w.Address), Share: p.LargestShare,
+				Reason: "single wallet holds most funds",
+			})
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%.0f%% of funds are in one wallet; a single key compromise or loss affects most of the portfolio.", p.LargestShare*100))
+			score -= 10
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	p.SovereigntyScore = score
+	return p
+}
+
+// consolidationStep prices sweeping w's economical UTXOs into one output;
+// ok is false when fewer than two are worth spending.
+func consolidationStep(w Result) (ConsolidationStep, bool) {
+	typ := scriptType(w.Address)
+	inVB, known := inputVBytes[typ]
+	if !known {
+		inVB = defaultInputVBytes
+	}
+	step := ConsolidationStep{Wallet: w.Address, ScriptType: typ, FeeRateSatVB: w.FeeRateSatVB}
+	var value uint64
+	for _, u := range w.UTXOs {
+		if u.ValueSats <= inVB*w.FeeRateSatVB {
+			step.SkippedDust++
+			continue
+		}
+		step.Inputs++
+		value += u.ValueSats
+	}
+	if step.Inputs < 2 {
+		return step, false
+	}
+	step.FeeSats = (uint64(step.Inputs)*inVB + 34 + 10) * w.FeeRateSatVB
+	step.FeePerUTXORemoved = step.FeeSats / uint64(step.Inputs-1)
+	step.FeePctOfBalance = round2(float64(step.FeeSats) / float64(value) * 100)
+	return step, true
+}
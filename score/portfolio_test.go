@@ -0,0 +1,124 @@
+package score
+
+import (
+	"strings"
+	"testing"
+
+	"sovereign-checker/btc"
+)
+
+func utxos(values ...uint64) []btc.UTXO {
+	var us []btc.UTXO
+	for _, v := range values {
+		us = append(us, btc.UTXO{ValueSats: v, Confirmed: true})
+	}
+	return us
+}
+
+func TestConsolidationStep(t *testing.T) {
+	tests := []struct {
+		wallet  string
+		feeRate uint64
+		utxos   []btc.UTXO
+		ok      bool
+		want    ConsolidationStep
+	}{
+		// 68 vB segwit inputs: the 500-sat coin costs more than it is worth at 10 sat/vB.
+		{"bc1qwallet", 10, utxos(500, 10_000, 20_000, 30_000), true,
+			ConsolidationStep{ScriptType: "v0_p2wpkh", Inputs: 3, SkippedDust: 1, FeeSats: 2_480, FeePerUTXORemoved: 1_240, FeePctOfBalance: 4.13}},
+		{"bc1pwallet", 2, utxos(1_000, 1_000), true,
+			ConsolidationStep{ScriptType: "v1_p2tr", Inputs: 2, FeeSats: 320, FeePerUTXORemoved: 320, FeePctOfBalance: 16}},
+		{"1LegacyWallet", 5, utxos(1_000, 1_000, 1_000, 700), true,
+			ConsolidationStep{ScriptType: "p2pkh", Inputs: 3, SkippedDust: 1, FeeSats: 2_440, FeePerUTXORemoved: 1_220, FeePctOfBalance: 81.33}},
+		{"wsh(multi(2,xpubA/0/*,xpubB/0/*,xpubC/0/*))", 1, utxos(200, 300), true,
+			ConsolidationStep{ScriptType: "v0_p2wsh", Inputs: 2, FeeSats: 252, FeePerUTXORemoved: 252, FeePctOfBalance: 50.4}},
+		{"unknown", 1, utxos(1_000, 1_000), true,
+			ConsolidationStep{Inputs: 2, FeeSats: 340, FeePerUTXORemoved: 340, FeePctOfBalance: 17}},
+		{"bc1qwallet", 10, utxos(500, 10_000), false, ConsolidationStep{ScriptType: "v0_p2wpkh", Inputs: 1, SkippedDust: 1}},
+	}
+	for _, tt := range tests {
+		step, ok := consolidationStep(Result{Address: tt.wallet, FeeRateSatVB: tt.feeRate, UTXOs: tt.utxos})
+		tt.want.Wallet, tt.want.FeeRateSatVB = tt.wallet, tt.feeRate
+		if ok != tt.ok || step != tt.want {
+			t.Errorf("%s: %+v %t, want %+v %t", tt.wallet, step, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestComputePortfolio(t *testing.T) {
+	wallet := func(addr, network string, balance uint64, score int, feeRate uint64, us []btc.UTXO) Result {
+		return Result{Address: addr, Network: network, TotalBalanceSats: balance, NumUTXOs: len(us),
+			SovereigntyScore: score, FeeRateSatVB: feeRate, UTXOs: us}
+	}
+	tests := []struct {
+		name          string
+		wallets       []Result
+		score         int
+		weakest       string
+		order         []string
+		fee           uint64
+		largest       float64
+		concentration []string
+		warnings      []string
+	}{
+		{"empty", nil, 0, "", nil, 0, 0, nil, nil},
+		// Balance-weighted (80*0.6 + 50*0.4) = 68, less 10 for one wallet holding 60%.
+		{"one dominant wallet", []Result{
+			wallet("bc1qsavings", "mainnet", 600_000, 80, 10, utxos(500, 10_000, 20_000, 30_000)),
+			wallet("bc1pspending", "mainnet", 400_000, 50, 2, utxos(1_000, 1_000)),
+		}, 58, "bc1pspending", []string{"bc1pspending", "bc1qsavings"}, 2_800, 0.6,
+			[]string{"bc1qsavings"}, []string{"60% of funds are in one wallet"}},
+		{"legacy wallet", []Result{
+			wallet("1Legacy", "mainnet", 300_000, 40, 1, nil),
+			wallet("bc1qcold", "mainnet", 350_000, 90, 1, nil),
+			wallet("bc1phot", "mainnet", 350_000, 90, 1, nil),
+		}, 65, "1Legacy", nil, 0, 0.35,
+			[]string{"1Legacy"}, []string{"30% of funds sit in a legacy (P2PKH) wallet"}},
+		{"empty wallets average plainly", []Result{
+			wallet("bc1qa", "mainnet", 0, 40, 1, nil),
+			wallet("bc1qb", "mainnet", 0, 61, 1, nil),
+		}, 50, "bc1qa", nil, 0, 0, nil, nil},
+		{"mixed networks", []Result{
+			wallet("bc1qmain", "mainnet", 500_000, 70, 1, nil),
+			wallet("tb1qtest", "testnet", 500_000, 30, 1, nil),
+		}, 40, "tb1qtest", nil, 0, 0.5,
+			[]string{"bc1qmain"}, []string{"Portfolio mixes networks", "50% of funds are in one wallet"}},
+		{"a single wallet is not concentrated", []Result{
+			wallet("bc1qonly", "mainnet", 500_000, 70, 1, nil),
+		}, 70, "bc1qonly", nil, 0, 1, nil, nil},
+		{"score floors at zero", []Result{
+			wallet("1Legacy", "mainnet", 500_000, 5, 1, nil),
+		}, 0, "1Legacy", nil, 0, 1, []string{"1Legacy"}, []string{"100% of funds sit in a legacy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ComputePortfolio(tt.wallets)
+			if p.Wallets != len(tt.wallets) || p.SovereigntyScore != tt.score || p.WeakestWallet != tt.weakest {
+				t.Errorf("wallets %d score %d weakest %q, want %d %d %q", p.Wallets, p.SovereigntyScore, p.WeakestWallet,
+					len(tt.wallets), tt.score, tt.weakest)
+			}
+			var order []string
+			for _, s := range p.ConsolidationOrder {
+				order = append(order, s.Wallet)
+			}
+			if strings.Join(order, ",") != strings.Join(tt.order, ",") || p.ConsolidationFeeSats != tt.fee {
+				t.Errorf("consolidation %v fee %d, want %v %d", order, p.ConsolidationFeeSats, tt.order, tt.fee)
+			}
+			var risky []string
+			for _, c := range p.Concentration {
+				risky = append(risky, c.Wallet)
+			}
+			if p.LargestShare != tt.largest || strings.Join(risky, ",") != strings.Join(tt.concentration, ",") {
+				t.Errorf("largest %.2f concentration %v, want %.2f %v", p.LargestShare, risky, tt.largest, tt.concentration)
+			}
+			if len(p.Warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %q, want %d", p.Warnings, len(tt.warnings))
+			}
+			for i, w := range tt.warnings {
+				if !strings.HasPrefix(p.Warnings[i], w) {
+					t.Errorf("warning %d = %q, want %q", i, p.Warnings[i], w)
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,7 @@ import "sovereign-checker/btc"
 type Result struct {
 	Address           string     `json:"address"`
 	Network           string     `json:"network"`
-	Mode              string     `json:"mode"` // "explorer" or "nodeonly"
+	Mode              string     `json:"mode"` // "explorer", "nodeonly", "descriptor" or "bitcoind-fallback"
 	TotalBalanceSats  uint64     `json:"total_balance_sats"`
 	NumUTXOs          int        `json:"num_utxos"`
 	DustUTXOs         int        `json:"dust_utxos"`